@@ -3,7 +3,10 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
@@ -15,21 +18,19 @@ import (
 
 func main() {
 
-	var cfg *models.ServerConfig
-	var err error
+	cfg, err := models.GetConfigFile("config.json")
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	simulatorRepository := repo.NewSimulatorRepository()
 	simulatorController := cnt.NewSimulatorController(simulatorRepository)
-	simulatorController.GetIstance()
+	simulatorController.GetIstance(cfg)
 
 	log.Println("LWN Simulator is online...")
 
-	cfg, err = models.GetConfigFile("config.json")
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	go startMetrics(cfg)
+	go closeOnSignal(simulatorController)
 
 	if cfg.AutoStart == true {
 		// start the simulator automatically
@@ -43,9 +44,44 @@ func main() {
 	WebServer.Run()
 }
 
+// closeOnSignal stops the simulator and its webhooks before exiting on SIGINT or SIGTERM
+func closeOnSignal(simulatorController cnt.SimulatorController) {
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	<-signals
+
+	log.Println("LWN Simulator is shutting down...")
+
+	simulatorController.Close()
+
+	os.Exit(0)
+}
+
 func startMetrics(cfg *models.ServerConfig) {
+
 	http.Handle("/metrics", promhttp.Handler())
-	err := http.ListenAndServe(cfg.Address+":"+strconv.Itoa(cfg.MetricsPort), nil)
+
+	server := http.Server{
+		Addr: cfg.Address + ":" + strconv.Itoa(cfg.MetricsPort),
+	}
+
+	var err error
+
+	if cfg.TLS.Enable {
+
+		server.TLSConfig, err = cfg.TLS.Config()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+
+	} else {
+		err = server.ListenAndServe()
+	}
+
 	if err != nil {
 		log.Println("[Metrics] [ERROR]:", err.Error())
 	}
@@ -8,4 +8,8 @@ const (
 	CodeNoBridge
 	CodeErrorGatewayActive
 	CodeSaving
+	CodeErrorNotFound
+	CodeErrorConfiguration
+	CodeErrorDeviceInactive // the device or the gateway is turned off
+	CodeErrorStopped        // the simulator is stopped
 )
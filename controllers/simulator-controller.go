@@ -1,11 +1,20 @@
 package controllers
 
 import (
+	"encoding/json"
+
 	"github.com/arslab/lwnsimulator/models"
 	repo "github.com/arslab/lwnsimulator/repositories"
 
 	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	mup "github.com/arslab/lwnsimulator/simulator/components/device/frames/uplink/models"
+	devm "github.com/arslab/lwnsimulator/simulator/components/device/models"
 	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	ns "github.com/arslab/lwnsimulator/simulator/components/networkserver"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/simulator/integration"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
 	e "github.com/arslab/lwnsimulator/socket"
 	"github.com/brocaar/lorawan"
 	socketio "github.com/googollee/go-socket.io"
@@ -15,25 +24,55 @@ import (
 type SimulatorController interface {
 	Run() bool
 	Stop() bool
+	Close()
 	Status() bool
-	GetIstance()
-	AddWebSocket(*socketio.Conn)
+	GetIstance(*models.ServerConfig)
+	AddWebSocket(*socketio.Conn, bool)
+	RemoveWebSocket(string)
+	SubscribeWebSocket(string, e.Subscription) bool
+	SubscribeEvents(events.Handler) int
+	UnsubscribeEvents(int)
 	SaveBridgeAddress(models.AddressIP) error
 	GetBridgeAddress() models.AddressIP
 	GetGateways() []gw.Gateway
+	GetGatewaysConnection() []e.LinkGw
 	AddGateway(*gw.Gateway) (int, int, error)
 	UpdateGateway(*gw.Gateway) (int, error)
-	DeleteGateway(int) bool
+	DeleteGateway(int) (int, error)
 	AddDevice(*dev.Device) (int, int, error)
 	GetDevices() []dev.Device
 	UpdateDevice(*dev.Device) (int, error)
-	DeleteDevice(int) bool
+	DeleteDevice(int) (int, error)
 	ToggleStateDevice(int)
-	SendMACCommand(lorawan.CID, e.MacCommand)
-	ChangePayload(e.NewPayload) (string, bool)
-	SendUplink(e.NewPayload)
-	ChangeLocation(e.NewLocation) bool
+	SetStateDevice(int, bool) (int, error)
+	SendMACCommand(lorawan.CID, e.MacCommand) (int, error)
+	ChangePayload(e.NewPayload) (string, int, error)
+	SendUplink(e.NewPayload) (int, error)
+	ChangeLocation(e.NewLocation) (int, error)
+	ChangeLocationGateway(e.NewLocation) (int, error)
 	ToggleStateGateway(int)
+	SetStateGateway(int, bool) (int, error)
+	SetImpairmentGateway(int, udp.Impairment) (int, error)
+	ImportConcentratorGateway(int, []byte) (int, error)
+	SetPcap(bool) error
+	GetPcap() models.Pcap
+	GetFrames(framelog.Filter) ([]json.RawMessage, error)
+	GetDeviceLiveState(int) (e.LiveStateDev, int, error)
+	SetMACSchedules(int, []devm.MACSchedule) (int, error)
+	GetDownlinks(int) ([]integration.Downlink, int, error)
+	ClearDownlinks(int) (int, error)
+	SendExternalUplink(integration.Uplink) (mup.InfoFrame, int, error)
+	ReceiveApplicationEvent(integration.ApplicationEvent)
+	GetDeliveryReports() []integration.DeliveryReport
+	GetDeliveryReport(int) (integration.DeliveryReport, int, error)
+	ResetDelivery()
+	QueueUplink(int, mup.InfoFrame) (mup.InfoFrame, int, error)
+	GetUplinkQueue(int) ([]mup.InfoFrame, int, error)
+	DeleteUplink(int, uint64) (int, error)
+	ClearUplinkQueue(int) (int, error)
+	NetworkSessions() ([]ns.Session, int, error)
+	QueueNetworkDownlink(int, ns.Downlink) (int, error)
+	QueueNetworkMACCommand(int, lorawan.CID, []byte) (int, error)
 }
 
 type simulatorController struct {
@@ -48,12 +87,28 @@ func NewSimulatorController(repo repo.SimulatorRepository) SimulatorController {
 	}
 }
 
-func (c *simulatorController) GetIstance() {
-	c.repo.GetIstance()
+func (c *simulatorController) GetIstance(config *models.ServerConfig) {
+	c.repo.GetIstance(config)
+}
+
+func (c *simulatorController) AddWebSocket(socket *socketio.Conn, keys bool) {
+	c.repo.AddWebSocket(socket, keys)
+}
+
+func (c *simulatorController) RemoveWebSocket(Id string) {
+	c.repo.RemoveWebSocket(Id)
+}
+
+func (c *simulatorController) SubscribeWebSocket(Id string, sub e.Subscription) bool {
+	return c.repo.SubscribeWebSocket(Id, sub)
+}
+
+func (c *simulatorController) SubscribeEvents(handler events.Handler) int {
+	return c.repo.SubscribeEvents(handler)
 }
 
-func (c *simulatorController) AddWebSocket(socket *socketio.Conn) {
-	c.repo.AddWebSocket(socket)
+func (c *simulatorController) UnsubscribeEvents(Id int) {
+	c.repo.UnsubscribeEvents(Id)
 }
 
 func (c *simulatorController) Run() bool {
@@ -64,6 +119,10 @@ func (c *simulatorController) Stop() bool {
 	return c.repo.Stop()
 }
 
+func (c *simulatorController) Close() {
+	c.repo.Close()
+}
+
 func (c *simulatorController) Status() bool {
 	return c.repo.Status()
 }
@@ -80,6 +139,10 @@ func (c *simulatorController) GetGateways() []gw.Gateway {
 	return c.repo.GetGateways()
 }
 
+func (c *simulatorController) GetGatewaysConnection() []e.LinkGw {
+	return c.repo.GetGatewaysConnection()
+}
+
 func (c *simulatorController) AddGateway(gateway *gw.Gateway) (int, int, error) {
 	return c.repo.AddGateway(gateway)
 }
@@ -88,7 +151,7 @@ func (c *simulatorController) UpdateGateway(gateway *gw.Gateway) (int, error) {
 	return c.repo.UpdateGateway(gateway)
 }
 
-func (c *simulatorController) DeleteGateway(Id int) bool {
+func (c *simulatorController) DeleteGateway(Id int) (int, error) {
 	return c.repo.DeleteGateway(Id)
 }
 
@@ -104,7 +167,7 @@ func (c *simulatorController) UpdateDevice(device *dev.Device) (int, error) {
 	return c.repo.UpdateDevice(device)
 }
 
-func (c *simulatorController) DeleteDevice(Id int) bool {
+func (c *simulatorController) DeleteDevice(Id int) (int, error) {
 	return c.repo.DeleteDevice(Id)
 }
 
@@ -112,22 +175,118 @@ func (c *simulatorController) ToggleStateDevice(Id int) {
 	c.repo.ToggleStateDevice(Id)
 }
 
-func (c *simulatorController) SendMACCommand(cid lorawan.CID, data e.MacCommand) {
-	c.repo.SendMACCommand(cid, data)
+func (c *simulatorController) SetStateDevice(Id int, active bool) (int, error) {
+	return c.repo.SetStateDevice(Id, active)
+}
+
+func (c *simulatorController) SendMACCommand(cid lorawan.CID, data e.MacCommand) (int, error) {
+	return c.repo.SendMACCommand(cid, data)
 }
 
-func (c *simulatorController) ChangePayload(pl e.NewPayload) (string, bool) {
+func (c *simulatorController) ChangePayload(pl e.NewPayload) (string, int, error) {
 	return c.repo.ChangePayload(pl)
 }
 
-func (c *simulatorController) SendUplink(pl e.NewPayload) {
-	c.repo.SendUplink(pl)
+func (c *simulatorController) SendUplink(pl e.NewPayload) (int, error) {
+	return c.repo.SendUplink(pl)
 }
 
-func (c *simulatorController) ChangeLocation(loc e.NewLocation) bool {
+func (c *simulatorController) ChangeLocation(loc e.NewLocation) (int, error) {
 	return c.repo.ChangeLocation(loc)
 }
 
 func (c *simulatorController) ToggleStateGateway(Id int) {
 	c.repo.ToggleStateGateway(Id)
 }
+
+func (c *simulatorController) SetStateGateway(Id int, active bool) (int, error) {
+	return c.repo.SetStateGateway(Id, active)
+}
+
+func (c *simulatorController) ChangeLocationGateway(loc e.NewLocation) (int, error) {
+	return c.repo.ChangeLocationGateway(loc)
+}
+
+func (c *simulatorController) SetImpairmentGateway(Id int, impairment udp.Impairment) (int, error) {
+	return c.repo.SetImpairmentGateway(Id, impairment)
+}
+
+func (c *simulatorController) ImportConcentratorGateway(Id int, globalConf []byte) (int, error) {
+	return c.repo.ImportConcentratorGateway(Id, globalConf)
+}
+
+func (c *simulatorController) SetPcap(enable bool) error {
+	return c.repo.SetPcap(enable)
+}
+
+func (c *simulatorController) GetPcap() models.Pcap {
+	return c.repo.GetPcap()
+}
+
+func (c *simulatorController) GetFrames(filter framelog.Filter) ([]json.RawMessage, error) {
+	return c.repo.GetFrames(filter)
+}
+
+func (c *simulatorController) GetDeviceLiveState(Id int) (e.LiveStateDev, int, error) {
+	return c.repo.GetDeviceLiveState(Id)
+}
+
+func (c *simulatorController) SetMACSchedules(Id int, schedules []devm.MACSchedule) (int, error) {
+	return c.repo.SetMACSchedules(Id, schedules)
+}
+
+func (c *simulatorController) GetDownlinks(Id int) ([]integration.Downlink, int, error) {
+	return c.repo.GetDownlinks(Id)
+}
+
+func (c *simulatorController) ClearDownlinks(Id int) (int, error) {
+	return c.repo.ClearDownlinks(Id)
+}
+
+func (c *simulatorController) ReceiveApplicationEvent(ev integration.ApplicationEvent) {
+	c.repo.ReceiveApplicationEvent(ev)
+}
+
+func (c *simulatorController) GetDeliveryReports() []integration.DeliveryReport {
+	return c.repo.GetDeliveryReports()
+}
+
+func (c *simulatorController) GetDeliveryReport(Id int) (integration.DeliveryReport, int, error) {
+	return c.repo.GetDeliveryReport(Id)
+}
+
+func (c *simulatorController) ResetDelivery() {
+	c.repo.ResetDelivery()
+}
+
+func (c *simulatorController) SendExternalUplink(uplink integration.Uplink) (mup.InfoFrame, int, error) {
+	return c.repo.SendExternalUplink(uplink)
+}
+
+func (c *simulatorController) QueueUplink(Id int, frame mup.InfoFrame) (mup.InfoFrame, int, error) {
+	return c.repo.QueueUplink(Id, frame)
+}
+
+func (c *simulatorController) GetUplinkQueue(Id int) ([]mup.InfoFrame, int, error) {
+	return c.repo.GetUplinkQueue(Id)
+}
+
+func (c *simulatorController) DeleteUplink(Id int, uplinkId uint64) (int, error) {
+	return c.repo.DeleteUplink(Id, uplinkId)
+}
+
+func (c *simulatorController) ClearUplinkQueue(Id int) (int, error) {
+	return c.repo.ClearUplinkQueue(Id)
+}
+
+func (c *simulatorController) NetworkSessions() ([]ns.Session, int, error) {
+	return c.repo.NetworkSessions()
+}
+
+func (c *simulatorController) QueueNetworkDownlink(Id int, downlink ns.Downlink) (int, error) {
+	return c.repo.QueueNetworkDownlink(Id, downlink)
+}
+
+func (c *simulatorController) QueueNetworkMACCommand(Id int, cid lorawan.CID, payload []byte) (int, error) {
+	return c.repo.QueueNetworkMACCommand(Id, cid, payload)
+}
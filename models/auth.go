@@ -0,0 +1,33 @@
+package models
+
+// roles of the users, each one can do what the previous ones do
+const (
+	RoleViewer   = "viewer"   // reads devices, without keys, and gateways
+	RoleOperator = "operator" // starts and stops the simulator, sends uplinks
+	RoleAdmin    = "admin"    // adds, updates and deletes devices and gateways, sets the bridge
+)
+
+// Auth protects the REST API, the socket and the dashboard
+type Auth struct {
+	Enable bool    `json:"enable"`
+	Users  []User  `json:"users"`  // HTTP basic authentication
+	Tokens []Token `json:"tokens"` // Authorization: Bearer <token>, or ?token=<token> for the socket
+}
+
+type User struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type Token struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+// Cors lists the origins allowed to call the API from a browser, none is same origin only
+type Cors struct {
+	AllowOrigins     []string `json:"allowOrigins"` // "*" for all, without credentials
+	AllowCredentials bool     `json:"allowCredentials"`
+}
@@ -6,11 +6,23 @@ import (
 )
 
 type ServerConfig struct {
-	Address       string `json:"address"`
-	Port          int    `json:"port"`
-	MetricsPort   int    `json:"metricsPort"`
-	ConfigDirname string `json:"configDirname"`
-	AutoStart     bool   `json:"autoStart"`
+	Address       string        `json:"address"`
+	Port          int           `json:"port"`
+	MetricsPort   int           `json:"metricsPort"`
+	ConfigDirname string        `json:"configDirname"`
+	AutoStart     bool          `json:"autoStart"`
+	Pcap          bool          `json:"pcap"` // capture each run in a PCAP-NG file
+	Metrics       Metrics       `json:"metrics"`
+	Tracing       Tracing       `json:"tracing"`
+	Auth          Auth          `json:"auth"`
+	Cors          Cors          `json:"cors"`
+	TLS           TLS           `json:"tls"`
+	GRPC          GRPC          `json:"grpc"`
+	NetworkServer NetworkServer `json:"networkServer"`
+	MQTT          MQTT          `json:"mqtt"`
+	Downlinks     Downlinks     `json:"downlinks"`
+	Delivery      Delivery      `json:"delivery"`
+	Webhooks      []Webhook     `json:"webhooks"`
 }
 
 func GetConfigFile(path string) (*ServerConfig, error) {
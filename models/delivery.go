@@ -0,0 +1,7 @@
+package models
+
+// Delivery is the verification of the uplinks delivered by the application server webhooks
+type Delivery struct {
+	Timeout int `json:"timeout"` // ms for a frame to be delivered, it is lost beyond, 10000 if 0
+	History int `json:"history"` // frames kept by device to match the deliveries, 1000 if 0
+}
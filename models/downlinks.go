@@ -0,0 +1,7 @@
+package models
+
+// Downlinks is the delivery of the application downlinks received by the devices
+type Downlinks struct {
+	History  int      `json:"history"`  // downlinks kept by device for the API, 100 if 0
+	Webhooks []string `json:"webhooks"` // URLs where each downlink is posted
+}
@@ -0,0 +1,7 @@
+package models
+
+// GRPC serves the gRPC API alongside the web server, with its authentication and TLS
+type GRPC struct {
+	Enable bool `json:"enable"`
+	Port   int  `json:"port"`
+}
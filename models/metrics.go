@@ -0,0 +1,8 @@
+package models
+
+// Metrics sets the labels of the Prometheus metrics, to bound the number of series
+type Metrics struct {
+	DeviceLabel    bool `json:"deviceLabel"`    // label the device metrics with the DevEUI
+	GatewayLabel   bool `json:"gatewayLabel"`   // label the gateway metrics with the MAC address
+	MaxLabelValues int  `json:"maxLabelValues"` // devices or gateways labelled, the others are "other", 0 is unlimited
+}
@@ -0,0 +1,11 @@
+package models
+
+// MQTT is the broker of the application integrations
+type MQTT struct {
+	Enable   bool   `json:"enable"`
+	Broker   string `json:"broker"`   // tcp://host:port or tls://host:port
+	ClientID string `json:"clientID"` // lwnsimulator if empty
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Prefix   string `json:"prefix"` // of the topics, lwnsimulator if empty
+}
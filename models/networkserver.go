@@ -0,0 +1,20 @@
+package models
+
+// NetworkServer is the embedded network server and join server, for the runs without
+// an external one. The virtual gateways use it when no bridge address is set
+type NetworkServer struct {
+	Enable        bool                 `json:"enable"`
+	Port          int                  `json:"port"`          // UDP, Semtech packet forwarder protocol
+	NetID         string               `json:"netID"`         // hex, 000000 if empty
+	Deduplication int                  `json:"deduplication"` // ms to wait for the copies of an uplink, 200 if 0
+	MACCommands   []ScriptedMACCommand `json:"macCommands"`
+}
+
+// ScriptedMACCommand is sent in the answer to the Uplink-th uplink of each session
+type ScriptedMACCommand struct {
+	DevEUI  string `json:"devEUI"` // all the devices if empty
+	Uplink  int    `json:"uplink"` // 1 for the first uplink after the join
+	Repeat  int    `json:"repeat"` // then every Repeat uplinks, once if 0
+	CID     uint8  `json:"cid"`
+	Payload string `json:"payload"` // hex
+}
@@ -0,0 +1,7 @@
+package models
+
+// Pcap is the state of the PCAP capture of the runs
+type Pcap struct {
+	Enable bool   `json:"enable"`
+	File   string `json:"file"` // current or last capture
+}
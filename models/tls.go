@@ -0,0 +1,66 @@
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLS serves the web server and the metrics over HTTPS
+type TLS struct {
+	Enable       bool   `json:"enable"`
+	CertFile     string `json:"certFile"`
+	KeyFile      string `json:"keyFile"`
+	ClientCAFile string `json:"clientCAFile"` // requires client certificates signed by these CAs, none if empty
+	MinVersion   string `json:"minVersion"`   // 1.2 if empty
+	RedirectPort int    `json:"redirectPort"` // HTTP port redirected to HTTPS, 0 is disabled
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Config returns the configuration of the HTTPS servers, the certificate is loaded by them
+func (t *TLS) Config() (*tls.Config, error) {
+
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, errors.New("certFile and keyFile expected")
+	}
+
+	config := tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if t.MinVersion != "" {
+
+		version, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %v", t.MinVersion)
+		}
+
+		config.MinVersion = version
+	}
+
+	if t.ClientCAFile != "" {
+
+		data, err := ioutil.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate in %v", t.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &config, nil
+}
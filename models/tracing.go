@@ -0,0 +1,7 @@
+package models
+
+// Tracing is the export of the uplink traces to an OpenTelemetry collector
+type Tracing struct {
+	Enable   bool   `json:"enable"`
+	Endpoint string `json:"endpoint"` // OTLP/HTTP, eg. http://localhost:4318
+}
@@ -0,0 +1,9 @@
+package models
+
+// Webhook is posted the events of the simulator
+type Webhook struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`  // types, eg. DeviceJoined, all the notified ones if empty
+	Secret  string   `json:"secret"`  // signs the bodies with HMAC-SHA256, unsigned if empty
+	Retries int      `json:"retries"` // of a failed post, after 1 s then twice as long each time
+}
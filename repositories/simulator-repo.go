@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"encoding/json"
 	"errors"
 
 	"github.com/brocaar/lorawan"
@@ -10,7 +11,14 @@ import (
 
 	"github.com/arslab/lwnsimulator/simulator"
 	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	mup "github.com/arslab/lwnsimulator/simulator/components/device/frames/uplink/models"
+	devm "github.com/arslab/lwnsimulator/simulator/components/device/models"
 	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	ns "github.com/arslab/lwnsimulator/simulator/components/networkserver"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/simulator/integration"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
 	"github.com/arslab/lwnsimulator/simulator/util"
 	socketio "github.com/googollee/go-socket.io"
 )
@@ -19,25 +27,55 @@ import (
 type SimulatorRepository interface {
 	Run() bool
 	Stop() bool
+	Close()
 	Status() bool
-	GetIstance()
-	AddWebSocket(*socketio.Conn)
+	GetIstance(*models.ServerConfig)
+	AddWebSocket(*socketio.Conn, bool)
+	RemoveWebSocket(string)
+	SubscribeWebSocket(string, e.Subscription) bool
+	SubscribeEvents(events.Handler) int
+	UnsubscribeEvents(int)
 	SaveBridgeAddress(models.AddressIP) error
 	GetBridgeAddress() models.AddressIP
 	GetGateways() []gw.Gateway
+	GetGatewaysConnection() []e.LinkGw
 	AddGateway(*gw.Gateway) (int, int, error)
 	UpdateGateway(*gw.Gateway) (int, error)
-	DeleteGateway(int) bool
+	DeleteGateway(int) (int, error)
 	AddDevice(*dev.Device) (int, int, error)
 	GetDevices() []dev.Device
 	UpdateDevice(*dev.Device) (int, error)
-	DeleteDevice(int) bool
+	DeleteDevice(int) (int, error)
 	ToggleStateDevice(int)
-	SendMACCommand(lorawan.CID, e.MacCommand)
-	ChangePayload(e.NewPayload) (string, bool)
-	SendUplink(e.NewPayload)
-	ChangeLocation(e.NewLocation) bool
+	SetStateDevice(int, bool) (int, error)
+	SendMACCommand(lorawan.CID, e.MacCommand) (int, error)
+	ChangePayload(e.NewPayload) (string, int, error)
+	SendUplink(e.NewPayload) (int, error)
+	ChangeLocation(e.NewLocation) (int, error)
+	ChangeLocationGateway(e.NewLocation) (int, error)
 	ToggleStateGateway(int)
+	SetStateGateway(int, bool) (int, error)
+	SetImpairmentGateway(int, udp.Impairment) (int, error)
+	ImportConcentratorGateway(int, []byte) (int, error)
+	SetPcap(bool) error
+	GetPcap() models.Pcap
+	GetFrames(framelog.Filter) ([]json.RawMessage, error)
+	GetDeviceLiveState(int) (e.LiveStateDev, int, error)
+	SetMACSchedules(int, []devm.MACSchedule) (int, error)
+	GetDownlinks(int) ([]integration.Downlink, int, error)
+	ClearDownlinks(int) (int, error)
+	SendExternalUplink(integration.Uplink) (mup.InfoFrame, int, error)
+	ReceiveApplicationEvent(integration.ApplicationEvent)
+	GetDeliveryReports() []integration.DeliveryReport
+	GetDeliveryReport(int) (integration.DeliveryReport, int, error)
+	ResetDelivery()
+	QueueUplink(int, mup.InfoFrame) (mup.InfoFrame, int, error)
+	GetUplinkQueue(int) ([]mup.InfoFrame, int, error)
+	DeleteUplink(int, uint64) (int, error)
+	ClearUplinkQueue(int) (int, error)
+	NetworkSessions() ([]ns.Session, int, error)
+	QueueNetworkDownlink(int, ns.Downlink) (int, error)
+	QueueNetworkMACCommand(int, lorawan.CID, []byte) (int, error)
 }
 
 type simulatorRepository struct {
@@ -49,12 +87,28 @@ func NewSimulatorRepository() SimulatorRepository {
 	return &simulatorRepository{}
 }
 
-func (s *simulatorRepository) GetIstance() {
-	s.sim = simulator.GetIstance()
+func (s *simulatorRepository) GetIstance(config *models.ServerConfig) {
+	s.sim = simulator.GetIstance(config)
 }
 
-func (s *simulatorRepository) AddWebSocket(socket *socketio.Conn) {
-	s.sim.AddWebSocket(socket)
+func (s *simulatorRepository) AddWebSocket(socket *socketio.Conn, keys bool) {
+	s.sim.AddWebSocket(socket, keys)
+}
+
+func (s *simulatorRepository) RemoveWebSocket(Id string) {
+	s.sim.RemoveWebSocket(Id)
+}
+
+func (s *simulatorRepository) SubscribeWebSocket(Id string, sub e.Subscription) bool {
+	return s.sim.SubscribeWebSocket(Id, sub)
+}
+
+func (s *simulatorRepository) SubscribeEvents(handler events.Handler) int {
+	return s.sim.SubscribeEvents(handler)
+}
+
+func (s *simulatorRepository) UnsubscribeEvents(Id int) {
+	s.sim.UnsubscribeEvents(Id)
 }
 
 func (s *simulatorRepository) Run() bool {
@@ -79,6 +133,10 @@ func (s *simulatorRepository) Stop() bool {
 	}
 }
 
+func (s *simulatorRepository) Close() {
+	s.sim.Close()
+}
+
 func (s *simulatorRepository) Status() bool {
 	switch s.sim.State {
 	case util.Running:
@@ -99,6 +157,10 @@ func (s *simulatorRepository) GetGateways() []gw.Gateway {
 	return s.sim.GetGateways()
 }
 
+func (s *simulatorRepository) GetGatewaysConnection() []e.LinkGw {
+	return s.sim.GetGatewaysConnection()
+}
+
 func (s *simulatorRepository) AddGateway(gateway *gw.Gateway) (int, int, error) {
 	return s.sim.SetGateway(gateway, false)
 }
@@ -108,7 +170,7 @@ func (s *simulatorRepository) UpdateGateway(gateway *gw.Gateway) (int, error) {
 	return code, err
 }
 
-func (s *simulatorRepository) DeleteGateway(Id int) bool {
+func (s *simulatorRepository) DeleteGateway(Id int) (int, error) {
 	return s.sim.DeleteGateway(Id)
 }
 
@@ -125,7 +187,7 @@ func (s *simulatorRepository) UpdateDevice(device *dev.Device) (int, error) {
 	return code, err
 }
 
-func (s *simulatorRepository) DeleteDevice(Id int) bool {
+func (s *simulatorRepository) DeleteDevice(Id int) (int, error) {
 	return s.sim.DeleteDevice(Id)
 }
 
@@ -133,22 +195,118 @@ func (s *simulatorRepository) ToggleStateDevice(Id int) {
 	s.sim.ToggleStateDevice(Id)
 }
 
-func (s *simulatorRepository) SendMACCommand(cid lorawan.CID, data e.MacCommand) {
-	s.sim.SendMACCommand(cid, data)
+func (s *simulatorRepository) SetStateDevice(Id int, active bool) (int, error) {
+	return s.sim.SetStateDevice(Id, active)
 }
 
-func (s *simulatorRepository) ChangePayload(pl e.NewPayload) (string, bool) {
+func (s *simulatorRepository) SendMACCommand(cid lorawan.CID, data e.MacCommand) (int, error) {
+	return s.sim.SendMACCommand(cid, data)
+}
+
+func (s *simulatorRepository) ChangePayload(pl e.NewPayload) (string, int, error) {
 	return s.sim.ChangePayload(pl)
 }
 
-func (s *simulatorRepository) SendUplink(pl e.NewPayload) {
-	s.sim.SendUplink(pl)
+func (s *simulatorRepository) SendUplink(pl e.NewPayload) (int, error) {
+	return s.sim.SendUplink(pl)
 }
 
-func (s *simulatorRepository) ChangeLocation(loc e.NewLocation) bool {
+func (s *simulatorRepository) ChangeLocation(loc e.NewLocation) (int, error) {
 	return s.sim.ChangeLocation(loc)
 }
 
 func (s *simulatorRepository) ToggleStateGateway(Id int) {
 	s.sim.ToggleStateGateway(Id)
 }
+
+func (s *simulatorRepository) SetStateGateway(Id int, active bool) (int, error) {
+	return s.sim.SetStateGateway(Id, active)
+}
+
+func (s *simulatorRepository) ChangeLocationGateway(loc e.NewLocation) (int, error) {
+	return s.sim.ChangeLocationGateway(loc)
+}
+
+func (s *simulatorRepository) SetImpairmentGateway(Id int, impairment udp.Impairment) (int, error) {
+	return s.sim.SetImpairmentGateway(Id, impairment)
+}
+
+func (s *simulatorRepository) ImportConcentratorGateway(Id int, globalConf []byte) (int, error) {
+	return s.sim.ImportConcentratorGateway(Id, globalConf)
+}
+
+func (s *simulatorRepository) SetPcap(enable bool) error {
+	return s.sim.SetPcap(enable)
+}
+
+func (s *simulatorRepository) GetPcap() models.Pcap {
+	return s.sim.GetPcap()
+}
+
+func (s *simulatorRepository) GetFrames(filter framelog.Filter) ([]json.RawMessage, error) {
+	return s.sim.GetFrames(filter)
+}
+
+func (s *simulatorRepository) GetDeviceLiveState(Id int) (e.LiveStateDev, int, error) {
+	return s.sim.GetDeviceLiveState(Id)
+}
+
+func (s *simulatorRepository) SetMACSchedules(Id int, schedules []devm.MACSchedule) (int, error) {
+	return s.sim.SetMACSchedules(Id, schedules)
+}
+
+func (s *simulatorRepository) GetDownlinks(Id int) ([]integration.Downlink, int, error) {
+	return s.sim.GetDownlinks(Id)
+}
+
+func (s *simulatorRepository) ClearDownlinks(Id int) (int, error) {
+	return s.sim.ClearDownlinks(Id)
+}
+
+func (s *simulatorRepository) ReceiveApplicationEvent(ev integration.ApplicationEvent) {
+	s.sim.ReceiveApplicationEvent(ev)
+}
+
+func (s *simulatorRepository) GetDeliveryReports() []integration.DeliveryReport {
+	return s.sim.GetDeliveryReports()
+}
+
+func (s *simulatorRepository) GetDeliveryReport(Id int) (integration.DeliveryReport, int, error) {
+	return s.sim.GetDeliveryReport(Id)
+}
+
+func (s *simulatorRepository) ResetDelivery() {
+	s.sim.ResetDelivery()
+}
+
+func (s *simulatorRepository) SendExternalUplink(uplink integration.Uplink) (mup.InfoFrame, int, error) {
+	return s.sim.SendExternalUplink(uplink)
+}
+
+func (s *simulatorRepository) QueueUplink(Id int, frame mup.InfoFrame) (mup.InfoFrame, int, error) {
+	return s.sim.QueueUplink(Id, frame)
+}
+
+func (s *simulatorRepository) GetUplinkQueue(Id int) ([]mup.InfoFrame, int, error) {
+	return s.sim.GetUplinkQueue(Id)
+}
+
+func (s *simulatorRepository) DeleteUplink(Id int, uplinkId uint64) (int, error) {
+	return s.sim.DeleteUplink(Id, uplinkId)
+}
+
+func (s *simulatorRepository) ClearUplinkQueue(Id int) (int, error) {
+	return s.sim.ClearUplinkQueue(Id)
+}
+
+func (s *simulatorRepository) NetworkSessions() ([]ns.Session, int, error) {
+	return s.sim.NetworkSessions()
+}
+
+func (s *simulatorRepository) QueueNetworkDownlink(Id int, downlink ns.Downlink) (int, error) {
+	return s.sim.QueueNetworkDownlink(Id, downlink)
+}
+
+func (s *simulatorRepository) QueueNetworkMACCommand(Id int, cid lorawan.CID, payload []byte) (int, error) {
+	return s.sim.QueueNetworkMACCommand(Id, cid, payload)
+}
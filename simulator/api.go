@@ -14,37 +14,117 @@ import (
 	"github.com/arslab/lwnsimulator/models"
 
 	dev "github.com/arslab/lwnsimulator/simulator/components/device"
-	f "github.com/arslab/lwnsimulator/simulator/components/forwarder"
+	mup "github.com/arslab/lwnsimulator/simulator/components/device/frames/uplink/models"
+	mac "github.com/arslab/lwnsimulator/simulator/components/device/macCommands"
+	devm "github.com/arslab/lwnsimulator/simulator/components/device/models"
 	mfw "github.com/arslab/lwnsimulator/simulator/components/forwarder/models"
 	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
-	c "github.com/arslab/lwnsimulator/simulator/console"
+	gwm "github.com/arslab/lwnsimulator/simulator/components/gateway/models"
+	ns "github.com/arslab/lwnsimulator/simulator/components/networkserver"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/simulator/integration"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/mqtt"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
 	"github.com/arslab/lwnsimulator/simulator/util"
 	"github.com/arslab/lwnsimulator/socket"
 	socketio "github.com/googollee/go-socket.io"
 )
 
-func GetIstance() *Simulator {
+// GetIstance returns the simulator of the web server configured by config, saved in its ConfigDirname
+func GetIstance(config *models.ServerConfig) *Simulator {
 
-	var s Simulator
+	path := config.ConfigDirname
 
-	s.State = util.Stopped
+	err := util.CreateConfigDir(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := []Option{
+		WithStore(&FileStore{Dir: path}),
+		WithDataDir(path),
+		WithPcap(config.Pcap),
+		WithMetrics(config.Metrics),
+		WithTracing(config.Tracing),
+		WithDownlinks(config.Downlinks),
+		WithDelivery(config.Delivery),
+		WithWebhooks(config.Webhooks),
+	}
+
+	if config.MQTT.Enable {
+
+		client := mqtt.New(config.MQTT)
+		client.Start()
+
+		log.Printf("[MQTT]: Broker [ %v ]", config.MQTT.Broker)
+
+		opts = append(opts, WithMQTT(client))
+	}
+
+	if config.NetworkServer.Enable {
 
-	s.loadData()
+		server, err := ns.New(config.NetworkServer)
+		if err != nil {
+			log.Fatal("[NS]: ", err)
+		}
+
+		err = server.Listen(fmt.Sprintf(":%v", config.NetworkServer.Port))
+		if err != nil {
+			log.Fatal("[NS]: ", err)
+		}
 
-	s.ActiveDevices = make(map[int]int)
-	s.ActiveGateways = make(map[int]int)
+		log.Printf("[NS]: Listen [ :%v ]", config.NetworkServer.Port)
 
-	s.Forwarder = *f.Setup()
+		opts = append(opts, WithNetworkServer(server))
+	}
 
-	s.Console = c.Console{}
+	s, err := New(opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	return &s
+	return s
 }
 
-func (s *Simulator) AddWebSocket(WebSocket *socketio.Conn) {
-	s.Console.SetupWebSocket(WebSocket)
-	s.Resources.AddWebSocket(WebSocket)
-	s.SetupConsole()
+// Close stops the simulator if it is running, then its webhooks and the client of the MQTT
+// broker, for good
+func (s *Simulator) Close() {
+
+	if s.State == util.Running {
+		s.Stop()
+	}
+
+	s.Downlinks.Close()
+	s.Notifications.Close()
+
+	if s.MQTT != nil {
+		s.MQTT.Stop()
+	}
+
+}
+
+// AddWebSocket adds a client of the console, the session keys are hidden from it without keys
+func (s *Simulator) AddWebSocket(WebSocket *socketio.Conn, keys bool) {
+	s.Console.AddClient(*WebSocket, keys)
+}
+
+func (s *Simulator) RemoveWebSocket(Id string) {
+	s.Console.RemoveClient(Id)
+}
+
+// SubscribeWebSocket limits the events sent to the web socket Id to the devices and gateways of sub
+func (s *Simulator) SubscribeWebSocket(Id string, sub socket.Subscription) bool {
+	return s.Console.SetSubscription(Id, sub)
+}
+
+// SubscribeEvents adds handler to the events of the simulator and returns the id to unsubscribe it
+func (s *Simulator) SubscribeEvents(handler events.Handler) int {
+	return s.Events.Subscribe(handler)
+}
+
+func (s *Simulator) UnsubscribeEvents(Id int) {
+	s.Events.Unsubscribe(Id)
 }
 
 func (s *Simulator) Run() {
@@ -54,6 +134,21 @@ func (s *Simulator) Run() {
 
 	s.Print("START", nil, util.PrintBoth)
 
+	s.Events.Publish(&events.SimulatorState{
+		Header: events.NewHeader(events.TypeSimulatorStarted),
+	})
+
+	if s.Resources.Capture.Enable {
+		s.openCapture()
+	}
+
+	err := s.Resources.FrameLog.Open(s.frameLogPath())
+	if err != nil {
+		s.Print("", err, util.PrintBoth)
+	}
+
+	s.Resources.Tracer.Start()
+
 	for _, id := range s.ActiveGateways {
 		s.turnONGateway(id)
 	}
@@ -78,12 +173,20 @@ func (s *Simulator) Stop() {
 
 	s.Resources.ExitGroup.Wait()
 
+	s.closeCapture()
+	s.Resources.FrameLog.Close()
+	s.Resources.Tracer.Stop()
+
 	s.saveStatus()
 
 	s.Forwarder.Reset()
 
 	s.Print("STOPPED", nil, util.PrintBoth)
 
+	s.Events.Publish(&events.SimulatorState{
+		Header: events.NewHeader(events.TypeSimulatorStopped),
+	})
+
 	s.reset()
 
 }
@@ -92,21 +195,9 @@ func (s *Simulator) SaveBridgeAddress(remoteAddr models.AddressIP) error {
 
 	s.BridgeAddress = fmt.Sprintf("%v:%v", remoteAddr.Address, remoteAddr.Port)
 
-	pathDir, err := util.GetPath()
+	err := s.save(RecordSimulator)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	path := pathDir + "/simulator.json"
-
-	bytes, err := json.MarshalIndent(&s, "", "\t")
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = util.WriteConfigFile(path, bytes)
-	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	s.Print("Gateway Bridge Address saved", nil, util.PrintOnlyConsole)
@@ -141,6 +232,18 @@ func (s *Simulator) GetGateways() []gw.Gateway {
 
 }
 
+func (s *Simulator) GetGatewaysConnection() []socket.LinkGw {
+
+	links := []socket.LinkGw{}
+
+	for _, g := range s.Gateways {
+		links = append(links, g.GetLinkStatus())
+	}
+
+	return links
+
+}
+
 func (s *Simulator) GetDevices() []dev.Device {
 
 	var devices []dev.Device
@@ -171,6 +274,10 @@ func (s *Simulator) SetGateway(gateway *gw.Gateway, update bool) (int, int, erro
 
 	} else {
 
+		if _, ok := s.Gateways[gateway.Id]; !ok {
+			return codes.CodeErrorNotFound, -1, errors.New("Gateway not found")
+		}
+
 		if s.Gateways[gateway.Id].IsOn() {
 			return codes.CodeErrorDeviceActive, -1, errors.New("Gateway is running, unable update")
 		}
@@ -201,17 +308,29 @@ func (s *Simulator) SetGateway(gateway *gw.Gateway, update bool) (int, int, erro
 
 	}
 
-	s.Gateways[gateway.Id] = gateway
+	if err := gateway.Info.Impairment.Validate(); err != nil {
+		return codes.CodeErrorConfiguration, -1, err
+	}
 
-	pathDir, err := util.GetPath()
-	if err != nil {
-		log.Fatal(err)
+	if update && gateway.Info.Impairment.IsZero() { //not sent by the web UI, kept
+		gateway.Info.Impairment = s.Gateways[gateway.Id].Info.Impairment
 	}
 
-	path := pathDir + "/gateways.json"
-	s.saveComponent(path, &s.Gateways)
-	path = pathDir + "/simulator.json"
-	s.saveComponent(path, &s)
+	if update && !gateway.Info.Concentrator.IsConfigured() { //imported apart, kept
+		gateway.Info.Concentrator = s.Gateways[gateway.Id].Info.Concentrator
+	}
+
+	if update && gateway.Info.StatInterval == 0 { //not sent by the web UI, kept
+		gateway.Info.StatInterval = s.Gateways[gateway.Id].Info.StatInterval
+	}
+
+	if update && gateway.Info.BatchWindow == 0 { //not sent by the web UI, kept
+		gateway.Info.BatchWindow = s.Gateways[gateway.Id].Info.BatchWindow
+	}
+
+	s.Gateways[gateway.Id] = gateway
+
+	s.save(RecordGateways, RecordSimulator)
 
 	s.Print("Gateway Saved", nil, util.PrintOnlyConsole)
 
@@ -234,26 +353,24 @@ func (s *Simulator) SetGateway(gateway *gw.Gateway, update bool) (int, int, erro
 	return codes.CodeOK, gateway.Id, nil
 }
 
-func (s *Simulator) DeleteGateway(Id int) bool {
+func (s *Simulator) DeleteGateway(Id int) (int, error) {
+
+	if _, ok := s.Gateways[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Gateway not found")
+	}
 
 	if s.Gateways[Id].IsOn() {
-		return false
+		return codes.CodeErrorGatewayActive, errors.New("Gateway is running, unable delete")
 	}
 
 	delete(s.Gateways, Id)
 	delete(s.ActiveGateways, Id)
 
-	pathDir, err := util.GetPath()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	path := pathDir + "/gateways.json"
-	s.saveComponent(path, &s.Gateways)
+	s.save(RecordGateways)
 
 	s.Print("Gateway Deleted", nil, util.PrintOnlyConsole)
 
-	return true
+	return codes.CodeOK, nil
 }
 
 func (s *Simulator) SetDevice(device *dev.Device, update bool) (int, int, error) {
@@ -267,6 +384,10 @@ func (s *Simulator) SetDevice(device *dev.Device, update bool) (int, int, error)
 
 	}
 
+	if device.Info.Configuration.Region == nil {
+		return codes.CodeErrorConfiguration, -1, errors.New("Error: Region invalid")
+	}
+
 	if !update { //new
 
 		device.Id = s.NextIDDev
@@ -274,6 +395,10 @@ func (s *Simulator) SetDevice(device *dev.Device, update bool) (int, int, error)
 
 	} else {
 
+		if _, ok := s.Devices[device.Id]; !ok {
+			return codes.CodeErrorNotFound, -1, errors.New("Device not found")
+		}
+
 		if s.Devices[device.Id].IsOn() {
 			return codes.CodeErrorDeviceActive, -1, errors.New("Device is running, unable update")
 		}
@@ -296,17 +421,21 @@ func (s *Simulator) SetDevice(device *dev.Device, update bool) (int, int, error)
 
 	}
 
-	s.Devices[device.Id] = device
+	if update { //the queue is a state, not a setting
+
+		old := s.Devices[device.Id]
+
+		device.Info.Status.UplinkQueue.Take(&old.Info.Status.UplinkQueue)
+
+		if device.Info.Configuration.MACSchedules == nil { //set by SetMACSchedules, unknown to the web UI
+			device.Info.Configuration.MACSchedules = old.Info.Configuration.MACSchedules
+		}
 
-	pathDir, err := util.GetPath()
-	if err != nil {
-		log.Fatal(err)
 	}
 
-	path := pathDir + "/devices.json"
-	s.saveComponent(path, &s.Devices)
-	path = pathDir + "/simulator.json"
-	s.saveComponent(path, &s)
+	s.putDevice(device)
+
+	s.save(RecordDevices, RecordSimulator)
 
 	s.Print("Device Saved", nil, util.PrintOnlyConsole)
 
@@ -328,26 +457,25 @@ func (s *Simulator) SetDevice(device *dev.Device, update bool) (int, int, error)
 	return codes.CodeOK, device.Id, nil
 }
 
-func (s *Simulator) DeleteDevice(Id int) bool {
+func (s *Simulator) DeleteDevice(Id int) (int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
 
 	if s.Devices[Id].IsOn() {
-		return false
+		return codes.CodeErrorDeviceActive, errors.New("Device is running, unable delete")
 	}
 
-	delete(s.Devices, Id)
+	s.removeDevice(Id)
 	delete(s.ActiveDevices, Id)
+	s.Downlinks.Clear(Id)
 
-	pathDir, err := util.GetPath()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	path := pathDir + "/devices.json"
-	s.saveComponent(path, &s.Devices)
+	s.save(RecordDevices)
 
 	s.Print("Device Deleted", nil, util.PrintOnlyConsole)
 
-	return true
+	return codes.CodeOK, nil
 }
 
 func (s *Simulator) ToggleStateDevice(Id int) {
@@ -360,31 +488,129 @@ func (s *Simulator) ToggleStateDevice(Id int) {
 
 }
 
-func (s *Simulator) SendMACCommand(cid lorawan.CID, data socket.MacCommand) {
+// SetStateDevice turns the device Id on or off while the simulator is running
+func (s *Simulator) SetStateDevice(Id int, active bool) (int, error) {
 
-	if !s.Devices[data.Id].IsOn() {
-		s.Console.PrintSocket(socket.EventResponseCommand, s.Devices[data.Id].Info.Name+" is turned off")
-		return
+	if _, ok := s.Devices[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
 	}
 
-	err := s.Devices[data.Id].SendMACCommand(cid, data.Periodicity)
-	if err != nil {
-		s.Console.PrintSocket(socket.EventResponseCommand, "Unable to send command: "+err.Error())
+	if s.State != util.Running {
+		return codes.CodeErrorStopped, errors.New("Simulator is stopped")
+	}
+
+	if s.Devices[Id].IsOn() != active {
+		s.ToggleStateDevice(Id)
+	}
+
+	return codes.CodeOK, nil
+}
+
+// GetDeviceLiveState returns the runtime state of the device Id, with its session keys
+func (s *Simulator) GetDeviceLiveState(Id int) (socket.LiveStateDev, int, error) {
+
+	d, ok := s.Devices[Id]
+	if !ok {
+		return socket.LiveStateDev{}, codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	return d.LiveState(), codes.CodeOK, nil
+}
+
+// deviceOn checks that the device Id exists and is turned on
+func (s *Simulator) deviceOn(Id int) (int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	if !s.Devices[Id].IsOn() {
+		return codes.CodeErrorDeviceInactive, errors.New(s.Devices[Id].Info.Name + " is turned off")
+	}
+
+	return codes.CodeOK, nil
+}
+
+func (s *Simulator) SendMACCommand(cid lorawan.CID, data socket.MacCommand) (int, error) {
+
+	if code, err := s.deviceOn(data.Id); err != nil {
+		s.respond(err.Error())
+		return code, err
+	}
+
+	var err error
+	deferred := false
+
+	if cid == lorawan.PingSlotInfoReq && data.Payload == "" && !data.FPort0 {
+		err = s.Devices[data.Id].SendMACCommand(cid, data.Periodicity)
 	} else {
-		s.Console.PrintSocket(socket.EventResponseCommand, "MACCommand will be sent to the next uplink")
+		deferred, err = s.injectMACCommand(cid, data)
+	}
+
+	if err != nil {
+		s.respond("Unable to send command: " + err.Error())
+		return codes.CodeErrorConfiguration, err
 	}
 
+	if deferred {
+		s.respond("FOpts of the next uplink full, MACCommand deferred to a later uplink")
+		return codes.CodeOK, nil
+	}
+
+	s.respond("MACCommand will be sent to the next uplink")
+
+	return codes.CodeOK, nil
 }
 
-func (s *Simulator) ChangePayload(pl socket.NewPayload) (string, bool) {
+// injectMACCommand sends the MAC command of data, its payload is checked if it has a name
+func (s *Simulator) injectMACCommand(cid lorawan.CID, data socket.MacCommand) (bool, error) {
 
-	devEUIstring := hex.EncodeToString(s.Devices[pl.Id].Info.DevEUI[:])
+	_, known := mac.UplinkCommands[data.CID]
+
+	payload, err := hex.DecodeString(data.Payload)
+	if err != nil {
+		return false, errors.New("payload: hex expected")
+	}
+
+	command, err := mac.UplinkCommand(cid, payload, known)
+	if err != nil {
+		return false, err
+	}
+
+	return s.Devices[data.Id].InjectMACCommand(command, data.FPort0)
+}
+
+// SetMACSchedules replaces the MAC commands sent periodically by the device Id, they are saved
+func (s *Simulator) SetMACSchedules(Id int, schedules []devm.MACSchedule) (int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	for i := range schedules {
+		if _, err := schedules[i].Command(); err != nil {
+			return codes.CodeErrorConfiguration, fmt.Errorf("MAC schedule %v: %w", i, err)
+		}
+	}
+
+	s.Devices[Id].Info.Configuration.MACSchedules = schedules
 
-	if !s.Devices[pl.Id].IsOn() {
-		s.Console.PrintSocket(socket.EventResponseCommand, s.Devices[pl.Id].Info.Name+" is turned off")
-		return devEUIstring, false
+	if err := s.save(RecordDevices); err != nil {
+		return codes.CodeErrorConfiguration, err
 	}
 
+	return codes.CodeOK, nil
+}
+
+func (s *Simulator) ChangePayload(pl socket.NewPayload) (string, int, error) {
+
+	if code, err := s.deviceOn(pl.Id); err != nil {
+		s.respond(err.Error())
+		return "", code, err
+	}
+
+	devEUIstring := hex.EncodeToString(s.Devices[pl.Id].Info.DevEUI[:])
+
 	MType := lorawan.UnconfirmedDataUp
 	if pl.MType == "ConfirmedDataUp" {
 		MType = lorawan.ConfirmedDataUp
@@ -396,16 +622,16 @@ func (s *Simulator) ChangePayload(pl socket.NewPayload) (string, bool) {
 
 	s.Devices[pl.Id].ChangePayload(MType, Payload)
 
-	s.Console.PrintSocket(socket.EventResponseCommand, s.Devices[pl.Id].Info.Name+": Payload changed")
+	s.respond(s.Devices[pl.Id].Info.Name + ": Payload changed")
 
-	return devEUIstring, true
+	return devEUIstring, codes.CodeOK, nil
 }
 
-func (s *Simulator) SendUplink(pl socket.NewPayload) {
+func (s *Simulator) SendUplink(pl socket.NewPayload) (int, error) {
 
-	if !s.Devices[pl.Id].IsOn() {
-		s.Console.PrintSocket(socket.EventResponseCommand, s.Devices[pl.Id].Info.Name+" is turned off")
-		return
+	if code, err := s.deviceOn(pl.Id); err != nil {
+		s.respond(err.Error())
+		return code, err
 	}
 
 	MType := lorawan.UnconfirmedDataUp
@@ -413,15 +639,161 @@ func (s *Simulator) SendUplink(pl socket.NewPayload) {
 		MType = lorawan.ConfirmedDataUp
 	}
 
-	s.Devices[pl.Id].NewUplink(MType, pl.Payload)
+	if err := s.Devices[pl.Id].NewUplink(MType, pl.Payload); err != nil {
+		s.respond("Unable to queue the uplink: " + err.Error())
+		return codes.CodeErrorConfiguration, err
+	}
+
+	s.respond("Uplink queued")
 
-	s.Console.PrintSocket(socket.EventResponseCommand, "Uplink queued")
+	return codes.CodeOK, nil
 }
 
-func (s *Simulator) ChangeLocation(l socket.NewLocation) bool {
+// GetDownlinks returns the last application downlinks received by the device Id, the oldest first
+func (s *Simulator) GetDownlinks(Id int) ([]integration.Downlink, int, error) {
 
-	if !s.Devices[l.Id].IsOn() {
-		return false
+	if _, ok := s.Devices[Id]; !ok {
+		return nil, codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	return s.Downlinks.History(Id), codes.CodeOK, nil
+}
+
+// ClearDownlinks forgets the application downlinks received by the device Id
+func (s *Simulator) ClearDownlinks(Id int) (int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	s.Downlinks.Clear(Id)
+
+	return codes.CodeOK, nil
+}
+
+// ReceiveApplicationEvent records an event posted by the webhook of the application server
+func (s *Simulator) ReceiveApplicationEvent(ev integration.ApplicationEvent) {
+	s.Delivery.Receive(ev)
+}
+
+// GetDeliveryReports compares the uplinks sent by each device with the ones delivered by the application server
+func (s *Simulator) GetDeliveryReports() []integration.DeliveryReport {
+	return s.Delivery.Reports()
+}
+
+// GetDeliveryReport compares the uplinks sent by the device Id with the ones delivered by the application server
+func (s *Simulator) GetDeliveryReport(Id int) (integration.DeliveryReport, int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return integration.DeliveryReport{}, codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	report := s.Delivery.Report(s.Devices[Id].Info.DevEUI)
+	report.DeviceId = Id
+	report.Name = s.Devices[Id].Info.Name
+
+	return report, codes.CodeOK, nil
+}
+
+// ResetDelivery forgets the uplinks sent and delivered
+func (s *Simulator) ResetDelivery() {
+	s.Delivery.Reset()
+}
+
+// SendExternalUplink sends the uplink of an external system through the session of the
+// device of its DevEUI, without waiting for the send interval of the device
+func (s *Simulator) SendExternalUplink(uplink integration.Uplink) (mup.InfoFrame, int, error) {
+
+	frame, err := uplink.Frame()
+	if err != nil {
+		return frame, codes.CodeErrorConfiguration, err
+	}
+
+	d, ok := s.deviceByEUI(uplink.DevEUI) //also called by the client of the MQTT broker
+	if !ok {
+		return frame, codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	if !d.IsOn() {
+		return frame, codes.CodeErrorDeviceInactive, errors.New(d.Info.Name + " is turned off")
+	}
+
+	if size := d.PayloadSize(); len(frame.Payload.(*lorawan.DataPayload).Bytes) > size {
+		return frame, codes.CodeErrorConfiguration, fmt.Errorf("payload: at most %v bytes expected at the data rate %v of %v",
+			size, d.Info.Status.DataRate, d.Info.Name)
+	}
+
+	frame, err = d.SendUplink(frame)
+	if err != nil {
+		return frame, codes.CodeErrorConfiguration, err
+	}
+
+	s.save(RecordDevices)
+
+	return frame, codes.CodeOK, nil
+}
+
+// QueueUplink queues the uplink of the application for the device Id, the queue is saved with the devices
+func (s *Simulator) QueueUplink(Id int, frame mup.InfoFrame) (mup.InfoFrame, int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return frame, codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	queued, err := s.Devices[Id].QueueUplink(frame)
+	if err != nil {
+		return frame, codes.CodeErrorConfiguration, err
+	}
+
+	s.save(RecordDevices)
+
+	return queued, codes.CodeOK, nil
+}
+
+// GetUplinkQueue returns the uplinks queued for the device Id
+func (s *Simulator) GetUplinkQueue(Id int) ([]mup.InfoFrame, int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return nil, codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	return s.Devices[Id].Info.Status.UplinkQueue.List(), codes.CodeOK, nil
+}
+
+// DeleteUplink removes the uplink uplinkId from the queue of the device Id
+func (s *Simulator) DeleteUplink(Id int, uplinkId uint64) (int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	if !s.Devices[Id].Info.Status.UplinkQueue.Delete(uplinkId) {
+		return codes.CodeErrorNotFound, errors.New("Uplink not queued")
+	}
+
+	s.save(RecordDevices)
+
+	return codes.CodeOK, nil
+}
+
+// ClearUplinkQueue removes the uplinks queued for the device Id
+func (s *Simulator) ClearUplinkQueue(Id int) (int, error) {
+
+	if _, ok := s.Devices[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	s.Devices[Id].Info.Status.UplinkQueue.Clear()
+
+	s.save(RecordDevices)
+
+	return codes.CodeOK, nil
+}
+
+func (s *Simulator) ChangeLocation(l socket.NewLocation) (int, error) {
+
+	if code, err := s.deviceOn(l.Id); err != nil {
+		return code, err
 	}
 
 	s.Devices[l.Id].ChangeLocation(l.Latitude, l.Longitude, l.Altitude)
@@ -434,7 +806,89 @@ func (s *Simulator) ChangeLocation(l socket.NewLocation) bool {
 
 	s.Forwarder.UpdateDevice(info)
 
-	return true
+	return codes.CodeOK, nil
+}
+
+func (s *Simulator) ChangeLocationGateway(l socket.NewLocation) (int, error) {
+
+	if _, ok := s.Gateways[l.Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Gateway not found")
+	}
+
+	if !s.Gateways[l.Id].IsOn() {
+		return codes.CodeErrorDeviceInactive, errors.New(s.Gateways[l.Id].Info.Name + " is turned off")
+	}
+
+	s.Gateways[l.Id].ChangeLocation(l.Latitude, l.Longitude, l.Altitude)
+
+	s.Forwarder.UpdateGateway(s.infoGateway(l.Id))
+
+	s.save(RecordGateways)
+
+	return codes.CodeOK, nil
+}
+
+func (s *Simulator) SetImpairmentGateway(Id int, impairment udp.Impairment) (int, error) {
+
+	_, ok := s.Gateways[Id]
+	if !ok {
+		return codes.CodeErrorNotFound, errors.New("Gateway not found")
+	}
+
+	if err := impairment.Validate(); err != nil {
+		return codes.CodeErrorConfiguration, err
+	}
+
+	s.Gateways[Id].SetImpairment(impairment)
+
+	s.save(RecordGateways)
+
+	s.Print("Impairment of "+s.Gateways[Id].Info.Name+" saved", nil, util.PrintOnlyConsole)
+
+	return codes.CodeOK, nil
+}
+
+func (s *Simulator) ImportConcentratorGateway(Id int, globalConf []byte) (int, error) {
+
+	_, ok := s.Gateways[Id]
+	if !ok {
+		return codes.CodeErrorNotFound, errors.New("Gateway not found")
+	}
+
+	concentrator, err := gwm.ParseGlobalConf(globalConf)
+	if err != nil {
+		return codes.CodeErrorConfiguration, err
+	}
+
+	s.Gateways[Id].Info.Concentrator = concentrator
+
+	if s.Gateways[Id].IsOn() {
+		s.Forwarder.UpdateGateway(s.infoGateway(Id))
+	}
+
+	s.save(RecordGateways)
+
+	s.Print("Concentrator of "+s.Gateways[Id].Info.Name+" imported", nil, util.PrintOnlyConsole)
+
+	return codes.CodeOK, nil
+}
+
+// SetStateGateway turns the gateway Id on or off while the simulator is running
+func (s *Simulator) SetStateGateway(Id int, active bool) (int, error) {
+
+	if _, ok := s.Gateways[Id]; !ok {
+		return codes.CodeErrorNotFound, errors.New("Gateway not found")
+	}
+
+	if s.State != util.Running {
+		return codes.CodeErrorStopped, errors.New("Simulator is stopped")
+	}
+
+	if s.Gateways[Id].IsOn() != active {
+		s.ToggleStateGateway(Id)
+	}
+
+	return codes.CodeOK, nil
 }
 
 func (s *Simulator) ToggleStateGateway(Id int) {
@@ -446,3 +900,89 @@ func (s *Simulator) ToggleStateGateway(Id int) {
 	}
 
 }
+
+// SetPcap enables the capture of the next runs, the current run starts or stops its capture
+func (s *Simulator) SetPcap(enable bool) error {
+
+	s.Resources.Capture.Enable = enable
+
+	if s.State != util.Running {
+		return nil
+	}
+
+	if enable && !s.Resources.Capture.IsOpen() {
+		return s.openCapture()
+	}
+
+	if !enable {
+		s.closeCapture()
+	}
+
+	return nil
+}
+
+func (s *Simulator) GetPcap() models.Pcap {
+
+	return models.Pcap{
+		Enable: s.Resources.Capture.Enable,
+		File:   s.Resources.Capture.Path,
+	}
+
+}
+
+// GetFrames returns the records of the frame log that match filter
+func (s *Simulator) GetFrames(filter framelog.Filter) ([]json.RawMessage, error) {
+	return s.Resources.FrameLog.Query(filter)
+}
+
+// NetworkSessions returns the sessions of the embedded network server
+func (s *Simulator) NetworkSessions() ([]ns.Session, int, error) {
+
+	if s.NetworkServer == nil {
+		return nil, codes.CodeErrorNotFound, errors.New("No embedded network server")
+	}
+
+	return s.NetworkServer.Sessions(), codes.CodeOK, nil
+}
+
+// QueueNetworkDownlink queues a downlink for the device Id in the embedded network server
+func (s *Simulator) QueueNetworkDownlink(Id int, downlink ns.Downlink) (int, error) {
+
+	if s.NetworkServer == nil {
+		return codes.CodeErrorNotFound, errors.New("No embedded network server")
+	}
+
+	d, ok := s.Devices[Id]
+	if !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	return networkCode(s.NetworkServer.Enqueue(d.Info.DevEUI, downlink))
+}
+
+// QueueNetworkMACCommand queues the MAC command cid for the device Id in the embedded network server
+func (s *Simulator) QueueNetworkMACCommand(Id int, cid lorawan.CID, payload []byte) (int, error) {
+
+	if s.NetworkServer == nil {
+		return codes.CodeErrorNotFound, errors.New("No embedded network server")
+	}
+
+	d, ok := s.Devices[Id]
+	if !ok {
+		return codes.CodeErrorNotFound, errors.New("Device not found")
+	}
+
+	return networkCode(s.NetworkServer.EnqueueMACCommand(d.Info.DevEUI, cid, payload))
+}
+
+func networkCode(err error) (int, error) {
+
+	switch err {
+	case nil:
+		return codes.CodeOK, nil
+	case ns.ErrDeviceNotFound:
+		return codes.CodeErrorNotFound, err
+	}
+
+	return codes.CodeErrorConfiguration, err
+}
@@ -2,12 +2,14 @@ package device
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/arslab/lwnsimulator/simulator/components/device/classes"
 	mup "github.com/arslab/lwnsimulator/simulator/components/device/frames/uplink/models"
+	mac "github.com/arslab/lwnsimulator/simulator/components/device/macCommands"
 	f "github.com/arslab/lwnsimulator/simulator/components/forwarder"
-	c "github.com/arslab/lwnsimulator/simulator/console"
+	"github.com/arslab/lwnsimulator/simulator/events"
 	res "github.com/arslab/lwnsimulator/simulator/resources"
 	"github.com/arslab/lwnsimulator/simulator/util"
 	"github.com/brocaar/lorawan"
@@ -18,6 +20,7 @@ func (d *Device) Setup(Resources *res.Resources, forwarder *f.Forwarder) {
 	d.State = util.Stopped
 
 	d.Exit = make(chan struct{})
+	d.Wake = make(chan struct{}, 1)
 
 	d.Info.JoinEUI = lorawan.EUI64{0, 0, 0, 0, 0, 0, 0, 0}
 	d.Info.NetID = lorawan.NetID{0, 0, 0}
@@ -68,8 +71,8 @@ func (d *Device) Setup(Resources *res.Resources, forwarder *f.Forwarder) {
 
 }
 
-func (d *Device) SetConsole(console *c.Console) {
-	d.Console = *console
+func (d *Device) SetEvents(bus *events.Bus) {
+	d.Events = bus
 }
 
 func (d *Device) TurnOFF() {
@@ -86,6 +89,9 @@ func (d *Device) TurnON() {
 
 	d.State = util.Running
 
+	state := d.buildLiveState() //before the goroutine of the device writes its state
+	d.setLiveState(&state)
+
 	go d.Run()
 
 	d.Print("Turn ON", nil, util.PrintBoth)
@@ -135,7 +141,39 @@ func (d *Device) SendMACCommand(cid lorawan.CID, periodicity uint8) error {
 	return nil
 }
 
-func (d *Device) NewUplink(mtype lorawan.MType, payload string) {
+// maxPendingFOpts is the size of the MAC commands waiting for FOpts, those of the next
+// uplinks once the FOpts of the next one is full
+const maxPendingFOpts = 4 * mac.MaxFOptsLen
+
+// InjectMACCommand sends command, its CID and its payload, with the next uplink: in FOpts,
+// or in the payload of an uplink on FPort 0 queued after the other ones. deferred is true
+// if the FOpts of the next uplink is full, the command waits for the following ones
+func (d *Device) InjectMACCommand(command []byte, fport0 bool) (deferred bool, err error) {
+
+	if fport0 {
+
+		fport := uint8(0)
+
+		_, err := d.QueueUplink(mup.InfoFrame{
+			MType:   lorawan.UnconfirmedDataUp,
+			Payload: &lorawan.DataPayload{Bytes: command},
+			FPort:   &fport,
+		})
+
+		return false, err
+	}
+
+	size := mac.Size(d.Info.Status.DataUplink.FOpts) + len(command)
+	if size > maxPendingFOpts {
+		return false, fmt.Errorf("%v bytes of MAC commands already pending, at most %v", size-len(command), maxPendingFOpts)
+	}
+
+	d.Info.Status.DataUplink.FOpts = append(d.Info.Status.DataUplink.FOpts, &lorawan.DataPayload{Bytes: command})
+
+	return size > mac.MaxFOptsLen, nil
+}
+
+func (d *Device) NewUplink(mtype lorawan.MType, payload string) error {
 
 	FRMPayload := &lorawan.DataPayload{
 		Bytes: []byte(payload),
@@ -146,8 +184,43 @@ func (d *Device) NewUplink(mtype lorawan.MType, payload string) {
 		Payload: FRMPayload,
 	}
 
-	d.Info.Status.BufferUplinks = append(d.Info.Status.BufferUplinks, info)
+	_, err := d.QueueUplink(info)
+
+	return err
+}
+
+// QueueUplink queues the frame of the application, it is returned with its Id
+func (d *Device) QueueUplink(frame mup.InfoFrame) (mup.InfoFrame, error) {
+	return d.Info.Status.UplinkQueue.Push(frame)
+}
+
+// SendUplink queues the frame and wakes the device to send it without waiting for its send interval
+func (d *Device) SendUplink(frame mup.InfoFrame) (mup.InfoFrame, error) {
+
+	frame, err := d.QueueUplink(frame)
+	if err != nil {
+		return frame, err
+	}
+
+	select {
+	case d.Wake <- struct{}{}:
+	default: //already woken
+	}
+
+	return frame, nil
+}
+
+// PayloadSize returns the size of the largest FRMPayload of the next uplink, at the data rate
+// of the device and with its pending MAC commands
+func (d *Device) PayloadSize() int {
+
+	m, n := d.Info.Configuration.Region.GetPayloadSize(d.Info.Status.DataRate, d.Info.Status.DataUplink.DwellTime)
+
+	if len(d.Info.Status.DataUplink.FOpts) > 0 {
+		return n
+	}
 
+	return m
 }
 
 func (d *Device) ChangePayload(mtype lorawan.MType, payload lorawan.Payload) {
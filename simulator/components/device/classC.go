@@ -23,6 +23,7 @@ func (d *Device) DownlinkReceivedRX2ClassC() {
 
 		downlink := d.Info.Status.InfoClassC.Downlink
 
+		d.downlinkReceived(downlink, true)
 		d.ExecuteMACCommand(downlink)
 
 		d.ADRProcedure()
@@ -31,6 +32,8 @@ func (d *Device) DownlinkReceivedRX2ClassC() {
 			d.FPendingProcedure(&downlink)
 		}
 
+		d.publishLiveState()
+
 		d.Info.Status.InfoClassC.WakeUpClass()
 
 		d.Info.Status.InfoClassC.Mutex.Unlock()
@@ -56,6 +56,7 @@ func (a *TypeA) ReceiveWindows(delayRX1 time.Duration, delayRX2 time.Duration) *
 		a.Info.Forwarder.UnRegister(a.Info.RX[i].GetListeningFrequency(), a.Info.DevEUI)
 
 		if resp != nil {
+			a.Info.Status.RXWindow = i
 			return resp
 		}
 	}
@@ -63,6 +63,7 @@ func (b *TypeB) ReceiveWindows(delayRX1 time.Duration, delayRX2 time.Duration) *
 		b.Info.Forwarder.UnRegister(b.Info.RX[i].GetListeningFrequency(), b.Info.DevEUI)
 
 		if resp != nil {
+			b.Info.Status.RXWindow = i
 			return resp
 		}
 
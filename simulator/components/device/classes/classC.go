@@ -62,6 +62,8 @@ func (c *TypeC) ReceiveWindows(delayRX1 time.Duration, delayRX2 time.Duration) *
 
 	c.Info.Forwarder.UnRegister(c.Info.RX[0].GetListeningFrequency(), c.Info.DevEUI)
 
+	c.Info.Status.RXWindow = 0
+
 	return resp
 
 }
@@ -2,33 +2,43 @@ package device
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
-	c "github.com/arslab/lwnsimulator/simulator/console"
+	"github.com/arslab/lwnsimulator/simulator/events"
 	res "github.com/arslab/lwnsimulator/simulator/resources"
 
 	"github.com/arslab/lwnsimulator/simulator/components/device/classes"
+	dl "github.com/arslab/lwnsimulator/simulator/components/device/frames/downlink"
 	"github.com/arslab/lwnsimulator/simulator/components/device/models"
+	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/resources/tracing"
 	"github.com/arslab/lwnsimulator/simulator/util"
 	"github.com/arslab/lwnsimulator/socket"
+	"github.com/brocaar/lorawan"
 )
 
 type Device struct {
 	State     int                      `json:"-"`
 	Exit      chan struct{}            `json:"-"`
+	Wake      chan struct{}            `json:"-"` // sends the next uplink before the send interval
 	Id        int                      `json:"id"`
 	Info      models.InformationDevice `json:"info"`
 	Class     classes.Class            `json:"-"`
 	Resources *res.Resources           `json:"-"`
 	Mutex     sync.Mutex               `json:"-"`
-	Console   c.Console                `json:"-"`
+	Events    *events.Bus              `json:"-"`
+	liveMutex sync.Mutex               // of live
+	live      *socket.LiveStateDev     // built by the goroutine of the device while it runs
 }
 
 // *******************Intern func*******************/
 func (d *Device) Run() {
 
 	defer d.Resources.ExitGroup.Done()
+	defer d.setLiveState(nil) //built by LiveState once the device is off
 
 	d.OtaaActivation()
 
@@ -41,6 +51,9 @@ func (d *Device) Run() {
 		case <-ticker.C:
 			break
 
+		case <-d.Wake:
+			ticker.Reset(d.Info.Configuration.SendInterval)
+
 		case <-d.Exit:
 			d.Print("Turn OFF", nil, util.PrintBoth)
 			return
@@ -93,34 +106,134 @@ func (d *Device) modeToString() string {
 
 func (d *Device) Print(content string, err error, printType int) {
 
-	now := time.Now()
-	message := ""
 	messageLog := ""
-	event := socket.EventDev
 	class := d.Class.ToString()
 	mode := d.modeToString()
 
 	if err == nil {
-		message = fmt.Sprintf("[ %s ] DEV[%s] |%s| {%s}: %s", now.Format(time.Stamp), d.Info.Name, mode, class, content)
 		messageLog = fmt.Sprintf("DEV[%s] |%s| {%s}: %s", d.Info.Name, mode, class, content)
 	} else {
-		message = fmt.Sprintf("[ %s ] DEV[%s] |%s| {%s} [ERROR]: %s", now.Format(time.Stamp), d.Info.Name, mode, class, err)
 		messageLog = fmt.Sprintf("DEV[%s] |%s| {%s} [ERROR]: %s", d.Info.Name, mode, class, err)
-		event = socket.EventError
 	}
 
-	data := socket.ConsoleLog{
-		Name: d.Info.Name,
-		Msg:  message,
+	d.Events.Publish(&events.Log{
+		Header:  events.NewHeader(events.TypeLog),
+		Source:  events.SourceDevice,
+		Id:      d.Id,
+		Name:    d.Info.Name,
+		Message: messageLog,
+		Error:   err != nil,
+		Output:  printType,
+	})
+}
+
+// Ref identifies the device in its events
+func (d *Device) Ref() events.Device {
+
+	class := ""
+	if d.Class != nil {
+		class = d.Class.ToString()
+	}
+
+	return events.Device{
+		Id:     d.Id,
+		Name:   d.Info.Name,
+		DevEUI: d.Info.DevEUI,
+		Region: rp.RegionToString(d.Info.Configuration.Region.GetCode()),
+		Class:  class,
 	}
 
-	switch printType {
-	case util.PrintBoth:
-		d.Console.PrintSocket(event, data)
-		d.Console.PrintLog(messageLog)
-	case util.PrintOnlySocket:
-		d.Console.PrintSocket(event, data)
-	case util.PrintOnlyConsole:
-		d.Console.PrintLog(messageLog)
+}
+
+func (d *Device) uplinkSent(frame []byte, info pkt.RXPK) {
+
+	var phy lorawan.PHYPayload
+	if err := phy.UnmarshalBinary(frame); err != nil {
+		return
 	}
+
+	ev := events.UplinkSent{
+		Header:         events.NewHeader(events.TypeUplinkSent),
+		Device:         d.Ref(),
+		DevAddr:        d.Info.DevAddr,
+		MType:          phy.MHDR.MType,
+		Frequency:      uint32(math.Round(info.Frequency * 1000000.0)),
+		DataRate:       info.DatR,
+		DR:             d.Info.Status.DataRate,
+		TXPower:        d.Info.Status.TXPower,
+		Retransmission: d.Info.Status.Mode == util.Retransmission,
+	}
+
+	if macPL, ok := phy.MACPayload.(*lorawan.MACPayload); ok {
+
+		ev.FCnt = macPL.FHDR.FCnt
+		ev.FPort = macPL.FPort
+
+		if macPL.FPort != nil && *macPL.FPort > 0 && phy.DecryptFRMPayload(d.Info.AppSKey) == nil && len(macPL.FRMPayload) > 0 {
+			if pl, ok := macPL.FRMPayload[0].(*lorawan.DataPayload); ok {
+				ev.Payload = pl.Bytes
+			}
+		}
+	}
+
+	d.Info.Status.LastUplinkTime = time.Now()
+
+	d.Resources.Tracer.Uplink(d.Info.DevEUI).AddEvent("uplink sent", map[string]interface{}{
+		"lorawan.mtype":  ev.MType.String(),
+		"lorawan.fcnt":   ev.FCnt,
+		"lora.frequency": ev.Frequency,
+		"lora.data_rate": ev.DataRate,
+		"retransmission": ev.Retransmission,
+	})
+
+	d.Events.Publish(&ev)
+}
+
+// downlinkReceived publishes downlink, unsolicited if received in the continuous RX2 of class C
+func (d *Device) downlinkReceived(downlink dl.InformationDownlink, unsolicited bool) {
+
+	window := "RXC"
+	latency := float64(0)
+
+	if !unsolicited {
+		window = fmt.Sprintf("RX%v", d.Info.Status.RXWindow+1)
+		latency = float64(time.Since(d.Info.Status.LastUplinkTime)) / float64(time.Millisecond)
+	}
+
+	d.Events.Publish(&events.DownlinkReceived{
+		Header:   events.NewHeader(events.TypeDownlinkReceived),
+		Device:   d.Ref(),
+		MType:    downlink.MType,
+		FCnt:     downlink.FCnt,
+		FPort:    downlink.FPort,
+		ACK:      downlink.ACK,
+		FPending: downlink.FPending,
+		Payload:  downlink.DataPayload,
+		Window:   window,
+		Latency:  latency,
+	})
+
+}
+
+func (d *Device) ackTimeout() {
+
+	d.Events.Publish(&events.AckTimeout{
+		Header:    events.NewHeader(events.TypeAckTimeout),
+		Device:    d.Ref(),
+		FCnt:      d.Info.Status.DataUplink.FCnt,
+		Confirmed: d.Info.Status.LastMType == lorawan.ConfirmedDataUp,
+	})
+
+}
+
+// traceWindows ends the span of the receive windows with their outcome
+func (d *Device) traceWindows(span *tracing.Span, phy *lorawan.PHYPayload) {
+
+	if phy == nil {
+		span.SetError("No downlink received")
+	} else {
+		span.SetAttribute("rx.window", fmt.Sprintf("RX%v", d.Info.Status.RXWindow+1))
+	}
+
+	span.End()
 }
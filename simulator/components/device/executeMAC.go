@@ -12,6 +12,7 @@ import (
 	dl "github.com/arslab/lwnsimulator/simulator/components/device/frames/downlink"
 	mac "github.com/arslab/lwnsimulator/simulator/components/device/macCommands"
 	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
+	"github.com/arslab/lwnsimulator/simulator/events"
 	"github.com/arslab/lwnsimulator/simulator/util"
 	"github.com/brocaar/lorawan"
 )
@@ -26,10 +27,10 @@ const (
 // uplink
 func (d *Device) newMACComands(CmdS []lorawan.Payload) {
 
-	nCommand := len(CmdS) + len(d.Info.Status.DataUplink.FOpts)
-	if nCommand > 15 {
+	size := mac.Size(CmdS) + mac.Size(d.Info.Status.DataUplink.FOpts)
+	if size > mac.MaxFOptsLen {
 
-		msg := fmt.Sprintf("Insert %d MACCommands(max 15)", nCommand)
+		msg := fmt.Sprintf("Insert %d bytes of MACCommands(max %d)", size, mac.MaxFOptsLen)
 		d.Print(msg, nil, util.PrintBoth)
 
 		return
@@ -95,6 +96,12 @@ func (d *Device) ExecuteMACCommand(downlink dl.InformationDownlink) {
 			d.executeBeaconFreqReq(payloadBytes)
 		}
 
+		d.Events.Publish(&events.MACCommandExecuted{
+			Header: events.NewHeader(events.TypeMACCommandExecuted),
+			Device: d.Ref(),
+			CID:    cid,
+		})
+
 	}
 
 	if len(LinkADRReqCommands) != 0 {
@@ -14,6 +14,8 @@ type InformationDownlink struct {
 	DataPayload   []byte            `json:"-"`
 	FPending      bool              `json:"-"`
 	DwellTime     lorawan.DwellTime `json:"-"`
+	FCnt          uint32            `json:"-"`
+	FPort         *uint8            `json:"-"`
 }
 
 func GetDownlink(phy lorawan.PHYPayload, disableCounter bool, counter uint32, NwkSKey [16]byte, AppSKey [16]byte) (*InformationDownlink, error) {
@@ -51,6 +53,8 @@ func GetDownlink(phy lorawan.PHYPayload, disableCounter bool, counter uint32, Nw
 	downlink.FPending = macPL.FHDR.FCtrl.FPending
 
 	downlink.ACK = macPL.FHDR.FCtrl.ACK
+	downlink.FCnt = macPL.FHDR.FCnt
+	downlink.FPort = macPL.FPort
 
 	//MACCommand
 	if len(macPL.FHDR.FOpts) != 0 {
@@ -1,10 +1,79 @@
 package models_uplink
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
 	"github.com/brocaar/lorawan"
 )
 
 type InfoFrame struct {
-	MType   lorawan.MType
-	Payload lorawan.Payload
+	Id       uint64          `json:"id"` // in the queue of the device
+	MType    lorawan.MType   `json:"-"`
+	Payload  lorawan.Payload `json:"-"`
+	FPort    *uint8          `json:"fPort,omitempty"`  // the FPort of the device if nil, 0 for MAC commands in the payload
+	Priority int             `json:"priority"`         // the highest is sent first
+	SendAt   *time.Time      `json:"sendAt,omitempty"` // not sent before
+	Queued   time.Time       `json:"queued"`
+}
+
+func (f *InfoFrame) MarshalJSON() ([]byte, error) {
+
+	type Alias InfoFrame
+
+	payload := []byte{}
+	if f.Payload != nil {
+
+		bytes, err := f.Payload.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		payload = bytes
+	}
+
+	return json.Marshal(&struct {
+		Confirmed bool   `json:"confirmed"`
+		Payload   string `json:"payload"` // hex
+		*Alias
+	}{
+		Confirmed: f.MType == lorawan.ConfirmedDataUp,
+		Payload:   hex.EncodeToString(payload),
+		Alias:     (*Alias)(f),
+	})
+
+}
+
+func (f *InfoFrame) UnmarshalJSON(data []byte) error {
+
+	type Alias InfoFrame
+
+	aux := &struct {
+		Confirmed bool   `json:"confirmed"`
+		Payload   string `json:"payload"`
+		*Alias
+	}{
+		Alias: (*Alias)(f),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	payload, err := hex.DecodeString(aux.Payload)
+	if err != nil {
+		return err
+	}
+
+	f.MType = lorawan.UnconfirmedDataUp
+	if aux.Confirmed {
+		f.MType = lorawan.ConfirmedDataUp
+	}
+
+	f.Payload = &lorawan.DataPayload{
+		Bytes: payload,
+	}
+
+	return nil
 }
@@ -0,0 +1,152 @@
+package models_uplink
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MaxQueue is the number of uplinks that can be queued for a device
+const MaxQueue = 128
+
+// ErrQueueFull is returned by Push when the queue holds MaxQueue uplinks
+var ErrQueueFull = errors.New("Uplink queue full")
+
+// Queue holds the uplinks of the application, they are sent before the periodic payload
+type Queue struct {
+	Mutex  sync.Mutex
+	frames []InfoFrame
+	lastId uint64
+}
+
+// Push queues frame with a new Id and returns it
+func (q *Queue) Push(frame InfoFrame) (InfoFrame, error) {
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	if len(q.frames) >= MaxQueue {
+		return frame, ErrQueueFull
+	}
+
+	q.lastId++
+
+	frame.Id = q.lastId
+	frame.Queued = time.Now()
+
+	q.frames = append(q.frames, frame)
+
+	return frame, nil
+}
+
+// Pop removes the next uplink to send at now: the one with the highest priority among the
+// ones whose send time has come, the first queued on a tie
+func (q *Queue) Pop(now time.Time) (InfoFrame, bool) {
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	next := -1
+
+	for i, f := range q.frames {
+
+		if f.SendAt != nil && f.SendAt.After(now) {
+			continue
+		}
+
+		if next < 0 || f.Priority > q.frames[next].Priority {
+			next = i
+		}
+	}
+
+	if next < 0 {
+		return InfoFrame{}, false
+	}
+
+	frame := q.frames[next]
+	q.frames = append(q.frames[:next], q.frames[next+1:]...)
+
+	return frame, true
+}
+
+// List returns the queued uplinks in the order they were queued
+func (q *Queue) List() []InfoFrame {
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	return append([]InfoFrame{}, q.frames...)
+}
+
+// Delete removes the uplink Id, false if it is not queued
+func (q *Queue) Delete(Id uint64) bool {
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	for i, f := range q.frames {
+		if f.Id == Id {
+			q.frames = append(q.frames[:i], q.frames[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clear removes every uplink and returns how many were queued
+func (q *Queue) Clear() int {
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	n := len(q.frames)
+	q.frames = nil
+
+	return n
+}
+
+// Take replaces the uplinks of the queue with the ones of other, which is emptied
+func (q *Queue) Take(other *Queue) {
+
+	if q == other {
+		return
+	}
+
+	other.Mutex.Lock()
+	frames, lastId := other.frames, other.lastId
+	other.frames = nil
+	other.Mutex.Unlock()
+
+	q.Mutex.Lock()
+	q.frames, q.lastId = frames, lastId
+	q.Mutex.Unlock()
+}
+
+func (q *Queue) MarshalJSON() ([]byte, error) {
+
+	return json.Marshal(q.List())
+}
+
+func (q *Queue) UnmarshalJSON(data []byte) error {
+
+	var frames []InfoFrame
+	if err := json.Unmarshal(data, &frames); err != nil {
+		return err
+	}
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	q.frames = frames
+	q.lastId = 0
+
+	for _, f := range frames {
+		if f.Id > q.lastId {
+			q.lastId = f.Id
+		}
+	}
+
+	return nil
+}
@@ -22,7 +22,22 @@ type InfoUplink struct {
 func (up *InfoUplink) GetFrame(mtype lorawan.MType, payload lorawan.DataPayload,
 	devAddr lorawan.DevAddr, AppSKey, NwkSKey [16]byte, ack bool) ([]byte, error) {
 
-	FOpts := up.loadFOpts()
+	return up.GetFrameOnPort(mtype, up.FPort, payload, devAddr, AppSKey, NwkSKey, ack)
+}
+
+// GetFrameOnPort is GetFrame on fport. On FPort 0 the payload holds MAC commands, it is
+// encrypted with NwkSKey and the MAC commands of FOpts wait for the next uplink
+func (up *InfoUplink) GetFrameOnPort(mtype lorawan.MType, fport *uint8, payload lorawan.DataPayload,
+	devAddr lorawan.DevAddr, AppSKey, NwkSKey [16]byte, ack bool) ([]byte, error) {
+
+	var FOpts []lorawan.Payload
+
+	key := AppSKey
+	if fport != nil && *fport == 0 {
+		key = NwkSKey
+	} else {
+		FOpts = up.loadFOpts()
+	}
 
 	phy := lorawan.PHYPayload{
 		MHDR: lorawan.MHDR{
@@ -41,14 +56,14 @@ func (up *InfoUplink) GetFrame(mtype lorawan.MType, payload lorawan.DataPayload,
 				FCnt:  up.FCnt,
 				FOpts: FOpts,
 			},
-			FPort: up.FPort,
+			FPort: fport,
 			FRMPayload: []lorawan.Payload{
 				&payload,
 			},
 		},
 	}
 
-	bytes, err := encryptFrame(phy, AppSKey, NwkSKey)
+	bytes, err := encryptFrame(phy, key, NwkSKey)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -63,18 +78,22 @@ func (up *InfoUplink) GetFrame(mtype lorawan.MType, payload lorawan.DataPayload,
 func (up *InfoUplink) loadFOpts() []lorawan.Payload {
 
 	FOpts := up.AckMacCommand.GetAll()
-	if len(up.FOpts) > 0 {
+	size := mac.Size(FOpts)
 
-		if len(up.FOpts)+len(FOpts) < 15 {
-			FOpts = append(FOpts, up.FOpts...)
-			up.FOpts = up.FOpts[:0] //reset
-		} else {
-			FOpts = append(FOpts, up.FOpts[:15-len(FOpts)]...)
-			up.FOpts = up.FOpts[15-len(FOpts):] //reset
+	i := 0
+	for ; i < len(up.FOpts); i++ { //the next ones wait for the next uplink
+
+		next := mac.Size(up.FOpts[i : i+1])
+		if size+next > mac.MaxFOptsLen {
+			break
 		}
 
+		FOpts = append(FOpts, up.FOpts[i])
+		size += next
 	}
 
+	up.FOpts = up.FOpts[i:]
+
 	return FOpts
 }
 
@@ -102,7 +121,7 @@ func (up *InfoUplink) IsTherePingSlotInfoReq() bool {
 
 		cid, _, err := mac.ParseMACCommand(cmd, true)
 		if err != nil {
-			return false
+			continue //a raw command
 		}
 
 		if cid == lorawan.PingSlotInfoReq {
@@ -6,32 +6,15 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-
 	"github.com/arslab/lwnsimulator/simulator/components/device/classes"
 	"github.com/arslab/lwnsimulator/simulator/components/device/features/adr"
 	dl "github.com/arslab/lwnsimulator/simulator/components/device/frames/downlink"
 	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
+	"github.com/arslab/lwnsimulator/simulator/events"
 	"github.com/arslab/lwnsimulator/simulator/util"
 	"github.com/brocaar/lorawan"
 )
 
-var (
-	uplinkCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "device_uplink_sent_total",
-		Help: "The total number of uplinks sent",
-	})
-	downlinkCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "device_downlink_received_total",
-		Help: "The total number of downlinks received",
-	})
-	ackTimeoutCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "device_ack_timetou_total",
-		Help: "The total number of ACK timeouts",
-	})
-)
-
 func (d *Device) Execute() {
 
 	var downlink *dl.InformationDownlink
@@ -40,29 +23,42 @@ func (d *Device) Execute() {
 	err = nil
 	downlink = nil
 
+	defer d.publishLiveState()
+
 	if d.Info.Status.DoSwitchChannel {
 		d.SwitchChannel()
 	}
 
+	trace := d.Resources.Tracer.StartUplink(d.Info.DevEUI, "uplink")
+	defer d.Resources.Tracer.EndUplink(d.Info.DevEUI)
+
+	trace.SetAttribute("device.name", d.Info.Name)
+	trace.SetAttribute("device.mode", d.modeToString())
+
+	span := d.Resources.Tracer.StartSpan("device.create_uplink", trace)
 	uplinks := d.CreateUplink()
+	span.SetAttribute("frames", len(uplinks))
+	span.End()
+
 	for i := 0; i < len(uplinks); i++ {
 
 		data := d.SetInfo(uplinks[i], false)
 		d.Class.SendData(data)
 
 		d.Print("Uplink sent", nil, util.PrintBoth)
-		uplinkCounter.Inc()
+		d.uplinkSent(uplinks[i], data)
 	}
 
 	d.Print("Open RXs for "+strconv.Itoa(int(d.Info.RX[0].Channel.FrequencyDownlink))+
 		" and "+strconv.Itoa(int(d.Info.RX[1].Channel.FrequencyDownlink)), nil, util.PrintBoth)
 
+	rx := d.Resources.Tracer.StartSpan("device.rx_windows", trace)
 	phy := d.Class.ReceiveWindows(0, 0)
+	d.traceWindows(rx, phy)
 
 	if phy != nil {
 
 		d.Print("Downlink Received", nil, util.PrintBoth)
-		downlinkCounter.Inc()
 
 		if d.Info.Status.Mode != util.Activation {
 			d.Info.Status.DoSwitchChannel = false
@@ -71,11 +67,13 @@ func (d *Device) Execute() {
 		downlink, err = d.ProcessDownlink(*phy)
 		if err != nil {
 			d.Print("", err, util.PrintBoth)
+			trace.SetError(err.Error())
 			return
 		}
 
 		if downlink != nil { //downlink ricevuto
 
+			d.downlinkReceived(*downlink, false)
 			d.ExecuteMACCommand(*downlink)
 
 			if d.Info.Status.Mode != util.Retransmission {
@@ -93,7 +91,11 @@ func (d *Device) Execute() {
 		<-timerAckTimeout.C
 
 		d.Print("ACK Timeout", nil, util.PrintBoth)
-		ackTimeoutCounter.Inc()
+		d.ackTimeout()
+
+		if d.Info.Status.LastMType == lorawan.ConfirmedDataUp {
+			trace.SetError("ACK Timeout")
+		}
 	}
 
 	d.ADRProcedure()
@@ -170,7 +172,6 @@ func (d *Device) FPendingProcedure(downlink *dl.InformationDownlink) {
 			if phy != nil {
 
 				d.Print("Downlink Received", nil, util.PrintBoth)
-				downlinkCounter.Inc()
 
 				downlink, err = d.ProcessDownlink(*phy)
 				if err != nil {
@@ -180,6 +181,7 @@ func (d *Device) FPendingProcedure(downlink *dl.InformationDownlink) {
 
 				if downlink != nil { //downlink ricevuto
 
+					d.downlinkReceived(*downlink, false)
 					d.ExecuteMACCommand(*downlink)
 
 				}
@@ -194,7 +196,7 @@ func (d *Device) FPendingProcedure(downlink *dl.InformationDownlink) {
 				<-timerAckTimeout.C
 
 				d.Print("ACK Timeout", nil, util.PrintBoth)
-				ackTimeoutCounter.Inc()
+				d.ackTimeout()
 
 			}
 
@@ -406,6 +408,14 @@ func (d *Device) SwitchClass(class int) {
 func (d *Device) UnJoined() bool {
 
 	if d.Info.Configuration.SupportedOtaa {
+
+		if d.Info.Status.Joined {
+			d.Events.Publish(&events.DeviceUnjoined{
+				Header: events.NewHeader(events.TypeDeviceUnjoined),
+				Device: d.Ref(),
+			})
+		}
+
 		d.Info.Status.Joined = false
 		return true //Otaa
 	}
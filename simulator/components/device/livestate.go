@@ -0,0 +1,158 @@
+package device
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/arslab/lwnsimulator/simulator/components/device/features"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/socket"
+	"github.com/brocaar/lorawan"
+)
+
+// LiveState returns the runtime state of the device, with its session keys. While the device
+// runs, it is the copy built by its goroutine at the turn on, after the join and after each uplink
+func (d *Device) LiveState() socket.LiveStateDev {
+
+	d.liveMutex.Lock()
+	live := d.live
+	d.liveMutex.Unlock()
+
+	if live == nil { //off, no goroutine writes the state
+		return d.buildLiveState()
+	}
+
+	state := *live //its slices are never written once built
+	state.Active = d.IsOn()
+
+	return state
+}
+
+func (d *Device) setLiveState(state *socket.LiveStateDev) {
+
+	d.liveMutex.Lock()
+	d.live = state
+	d.liveMutex.Unlock()
+
+}
+
+// buildLiveState reads the state of the device, on its goroutine while it runs
+func (d *Device) buildLiveState() socket.LiveStateDev {
+
+	class := ""
+	if d.Class != nil {
+		class = d.Class.ToString()
+	}
+
+	state := socket.LiveStateDev{
+		Id:          d.Id,
+		Name:        d.Info.Name,
+		DevEUI:      d.Info.DevEUI,
+		Active:      d.IsOn(),
+		Joined:      d.Info.Status.Joined,
+		Mode:        d.modeToString(),
+		Class:       class,
+		DevAddr:     d.Info.DevAddr,
+		NwkSKey:     hex.EncodeToString(d.Info.NwkSKey[:]),
+		AppSKey:     hex.EncodeToString(d.Info.AppSKey[:]),
+		FCntUp:      d.Info.Status.DataUplink.FCnt,
+		FCntDown:    d.Info.Status.FCntDown,
+		DataRate:    d.Info.Status.DataRate,
+		TXPower:     d.Info.Status.TXPower,
+		Channels:    []socket.LiveChannel{},
+		ChannelMask: []int{},
+		RX1DROffset: d.Info.Configuration.RX1DROffset,
+		ADR: socket.LiveADR{
+			Enable:    d.Info.Status.DataUplink.ADR.ADR,
+			ADRACKCnt: d.Info.Status.DataUplink.ADR.ADRACKCnt,
+			ADRACKReq: d.Info.Status.DataUplink.ADR.ADRACKReq,
+		},
+		MACCommands: []socket.LiveMACCommand{},
+		Uplinks:     []socket.LiveUplink{},
+	}
+
+	for i, c := range d.Info.Configuration.Channels {
+
+		state.Channels = append(state.Channels, socket.LiveChannel{
+			Index:             i,
+			Active:            c.Active,
+			EnableUplink:      c.EnableUplink,
+			FrequencyUplink:   c.FrequencyUplink,
+			FrequencyDownlink: c.FrequencyDownlink,
+			MinDR:             c.MinDR,
+			MaxDR:             c.MaxDR,
+		})
+
+		if c.Active && c.EnableUplink {
+			state.ChannelMask = append(state.ChannelMask, i)
+		}
+	}
+
+	if len(d.Info.RX) > 0 {
+		state.RX1 = liveWindow(d.Info.RX[0])
+	}
+
+	if len(d.Info.RX) > 1 {
+		state.RX2 = liveWindow(d.Info.RX[1])
+	}
+
+	commands := append([]lorawan.Payload{}, d.Info.Status.DataUplink.FOpts...)
+	commands = append(commands, d.Info.Status.DataUplink.AckMacCommand.GetAll()...)
+
+	for _, c := range commands {
+
+		bytes, err := c.MarshalBinary()
+		if err != nil || len(bytes) == 0 {
+			continue
+		}
+
+		state.MACCommands = append(state.MACCommands, socket.LiveMACCommand{
+			CID:     bytes[0],
+			Payload: hex.EncodeToString(bytes[1:]),
+		})
+	}
+
+	for _, u := range d.Info.Status.UplinkQueue.List() {
+
+		uplink := socket.LiveUplink{
+			MType: u.MType.String(),
+		}
+
+		if u.Payload != nil {
+			bytes, _ := u.Payload.MarshalBinary()
+			uplink.Payload = hex.EncodeToString(bytes)
+		}
+
+		state.Uplinks = append(state.Uplinks, uplink)
+	}
+
+	if !d.Info.Status.LastUplinkTime.IsZero() {
+		last := d.Info.Status.LastUplinkTime
+		state.LastUplink = &last
+	}
+
+	return state
+}
+
+func liveWindow(w features.Window) socket.LiveWindow {
+	return socket.LiveWindow{
+		Frequency:    w.Channel.FrequencyDownlink,
+		DataRate:     w.DataRate,
+		Delay:        int(w.Delay / time.Millisecond),
+		DurationOpen: int(w.DurationOpen / time.Millisecond),
+	}
+}
+
+// publishLiveState is called by the goroutine of the device, the state is kept for LiveState
+func (d *Device) publishLiveState() {
+
+	state := d.buildLiveState()
+	d.setLiveState(&state)
+
+	d.Events.Publish(&events.DeviceLiveState{
+		Header: events.NewHeader(events.TypeDeviceLiveState),
+		Device: d.Ref(),
+		State:  state,
+	})
+
+}
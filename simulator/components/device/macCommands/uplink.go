@@ -0,0 +1,89 @@
+package macCommands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/brocaar/lorawan"
+)
+
+// MaxFOptsLen is the size of the MAC commands sent in the header of an uplink
+const MaxFOptsLen = 15
+
+// UplinkCommands are the MAC commands that a device can send, LoRaWAN 1.0 and 1.1
+var UplinkCommands = map[string]lorawan.CID{
+	"ResetInd":            lorawan.ResetInd,
+	"LinkCheckReq":        lorawan.LinkCheckReq,
+	"LinkADRAns":          lorawan.LinkADRAns,
+	"DutyCycleAns":        lorawan.DutyCycleAns,
+	"RXParamSetupAns":     lorawan.RXParamSetupAns,
+	"DevStatusAns":        lorawan.DevStatusAns,
+	"NewChannelAns":       lorawan.NewChannelAns,
+	"RXTimingSetupAns":    lorawan.RXTimingSetupAns,
+	"TXParamSetupAns":     lorawan.TXParamSetupAns,
+	"DLChannelAns":        lorawan.DLChannelAns,
+	"RekeyInd":            lorawan.RekeyInd,
+	"ADRParamSetupAns":    lorawan.ADRParamSetupAns,
+	"DeviceTimeReq":       lorawan.DeviceTimeReq,
+	"RejoinParamSetupAns": lorawan.RejoinParamSetupAns,
+	"PingSlotInfoReq":     lorawan.PingSlotInfoReq,
+	"PingSlotChannelAns":  lorawan.PingSlotChannelAns,
+	"BeaconFreqAns":       lorawan.BeaconFreqAns,
+	"DeviceModeInd":       lorawan.DeviceModeInd,
+}
+
+// ParseCID returns the CID of a name of UplinkCommands or of a number, eg. 0x80.
+// known is false for a number, its payload is sent as is
+func ParseCID(name string) (cid lorawan.CID, known bool, err error) {
+
+	if cid, ok := UplinkCommands[name]; ok {
+		return cid, true, nil
+	}
+
+	n, err := strconv.ParseUint(name, 0, 8)
+	if err != nil {
+		return 0, false, errors.New("name of an uplink MAC command or number from 0 to 255 expected")
+	}
+
+	return lorawan.CID(n), false, nil
+}
+
+// UplinkCommand returns the bytes of the command cid with payload, the size of the
+// payload of a known command is checked
+func UplinkCommand(cid lorawan.CID, payload []byte, known bool) ([]byte, error) {
+
+	if known {
+
+		size := 0
+		if _, s, err := lorawan.GetMACPayloadAndSize(true, cid); err == nil {
+			size = s
+		}
+
+		if len(payload) != size {
+			return nil, fmt.Errorf("payload of %v bytes expected", size)
+		}
+	}
+
+	command := append([]byte{byte(cid)}, payload...)
+
+	if len(command) > MaxFOptsLen {
+		return nil, fmt.Errorf("MAC command longer than %v bytes", MaxFOptsLen)
+	}
+
+	return command, nil
+}
+
+// Size is the number of bytes of the commands
+func Size(commands []lorawan.Payload) int {
+
+	size := 0
+
+	for _, c := range commands {
+		if bytes, err := c.MarshalBinary(); err == nil {
+			size += len(bytes)
+		}
+	}
+
+	return size
+}
@@ -36,6 +36,8 @@ type Configuration struct {
 	NbRepConfirmedDataUp   int   `json:"nbRetransmission"` //Nb retrasmission of ConfirmedDataUp
 	NbRepUnconfirmedDataUp uint8 `json:"-"`                // Nb retrasmission of UnconfirmedDataUp
 	RSSI                   int16 `json:"rssi"`
+
+	MACSchedules []MACSchedule `json:"macSchedules"` // MAC commands sent periodically
 }
 
 func (c *Configuration) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/hex"
+	"errors"
+
+	mac "github.com/arslab/lwnsimulator/simulator/components/device/macCommands"
+)
+
+// MACSchedule sends a MAC command with one uplink every Every
+type MACSchedule struct {
+	CID     string `json:"cid"`     // name, eg. LinkCheckReq, or number, eg. 0x80
+	Payload string `json:"payload"` // hex, without the CID
+	Every   int    `json:"every"`   // uplinks
+	FPort0  bool   `json:"fPort0"`  // in the payload of an uplink on FPort 0 rather than in FOpts
+}
+
+// Command returns the bytes of the MAC command, CID and payload
+func (s *MACSchedule) Command() ([]byte, error) {
+
+	if s.Every < 1 {
+		return nil, errors.New("every must be at least 1")
+	}
+
+	cid, known, err := mac.ParseCID(s.CID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := hex.DecodeString(s.Payload)
+	if err != nil {
+		return nil, errors.New("payload: hex expected")
+	}
+
+	return mac.UplinkCommand(cid, payload, known)
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/base64"
 	"encoding/json"
+	"time"
 
 	modelClass "github.com/arslab/lwnsimulator/simulator/components/device/classes/models_classes"
 	"github.com/arslab/lwnsimulator/simulator/components/device/features/channels"
@@ -17,10 +18,10 @@ type Status struct {
 	Joined bool `json:"-"`
 	Mode   int  `json:"-"`
 
-	DataUplink    up.InfoUplink   `json:"infoUplink"`
-	MType         lorawan.MType   `json:"mtype"`   // from UI
-	Payload       lorawan.Payload `json:"payload"` // from UI
-	BufferUplinks []mup.InfoFrame `json:"-"`       // from socket
+	DataUplink  up.InfoUplink   `json:"infoUplink"`
+	MType       lorawan.MType   `json:"mtype"`       // from UI
+	Payload     lorawan.Payload `json:"payload"`     // from UI
+	UplinkQueue mup.Queue       `json:"uplinkQueue"` // from socket and API
 
 	DataDownlink dl.InformationDownlink `json:"-"`
 	FCntDown     uint32                 `json:"fcntDown"`
@@ -42,6 +43,10 @@ type Status struct {
 	AlignCurrentTime            bool          `json:"aligncurrentTime"`
 
 	DoSwitchChannel bool `json:"-"` // indicate if switching channel is desired
+
+	LastUplinkTime time.Time `json:"-"`
+	CounterUplinks uint32    `json:"-"` // new uplinks since the setup, for the MAC schedules
+	RXWindow       int       `json:"-"` // index of the window of the last downlink
 }
 
 func (s *Status) MarshalJSON() ([]byte, error) {
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/arslab/lwnsimulator/simulator/events"
 	"github.com/arslab/lwnsimulator/simulator/util"
 
 	act "github.com/arslab/lwnsimulator/simulator/components/device/activation"
@@ -20,6 +21,8 @@ const (
 
 func (d *Device) OtaaActivation() {
 
+	start := time.Now()
+
 	for !d.Info.Status.Joined {
 
 		d.Info.Status.Mode = util.Activation
@@ -30,12 +33,20 @@ func (d *Device) OtaaActivation() {
 
 		d.SwitchClass(classes.ClassA)
 
+		trace := d.Resources.Tracer.StartUplink(d.Info.DevEUI, "join")
+		trace.SetAttribute("device.name", d.Info.Name)
+
 		d.SendJoinRequest()
 
 		d.Print("Open RXs for "+strconv.Itoa(int(d.Info.RX[0].Channel.FrequencyDownlink))+
 			" and "+strconv.Itoa(int(d.Info.RX[1].Channel.FrequencyDownlink)), nil, util.PrintBoth)
 
+		reason := "No join accept received"
+
+		rx := d.Resources.Tracer.StartSpan("device.rx_windows", trace)
 		phy := d.Class.ReceiveWindows(JOINACCEPTDELAY1, JOINACCEPTDELAY2)
+		d.traceWindows(rx, phy)
+
 		if phy != nil {
 
 			d.Print("Downlink received", nil, util.PrintBoth)
@@ -43,6 +54,7 @@ func (d *Device) OtaaActivation() {
 			_, err := d.ProcessDownlink(*phy)
 			if err != nil {
 				d.Print("", err, util.PrintBoth)
+				reason = err.Error()
 
 				timerAckTimeout := time.NewTimer(d.Info.Configuration.AckTimeout)
 				<-timerAckTimeout.C
@@ -53,16 +65,37 @@ func (d *Device) OtaaActivation() {
 			d.Print("None downlink received", nil, util.PrintBoth)
 		}
 
+		if !d.Info.Status.Joined {
+			trace.SetError(reason)
+		}
+
+		d.Resources.Tracer.EndUplink(d.Info.DevEUI)
+
 		if d.Info.Status.Joined {
 
 			d.Print("Joined", nil, util.PrintBoth)
 			d.Info.Status.Mode = util.Normal
 
+			d.Events.Publish(&events.DeviceJoined{
+				Header:   events.NewHeader(events.TypeDeviceJoined),
+				Device:   d.Ref(),
+				DevAddr:  d.Info.DevAddr,
+				Duration: float64(time.Since(start)) / float64(time.Millisecond),
+			})
+
+			d.publishLiveState()
+
 			return
 		}
 
 		d.Print("Unjoined", nil, util.PrintBoth)
 
+		d.Events.Publish(&events.JoinFailed{
+			Header: events.NewHeader(events.TypeJoinFailed),
+			Device: d.Ref(),
+			Reason: reason,
+		})
+
 	}
 
 	return
@@ -125,6 +158,10 @@ func (d *Device) ProcessJoinAccept(JoinAccPayload *lorawan.JoinAcceptPayload) (*
 
 	d.Info.Status.Joined = true
 
+	//a new session starts the counters again
+	d.Info.Status.DataUplink.FCnt = 0
+	d.Info.Status.FCntDown = 0
+
 	//cflist
 	if JoinAccPayload.CFList != nil {
 
@@ -53,33 +53,48 @@ type Region interface {
 
 type regionInfo struct {
 	info func() Region
+	name string
 }
 
 var regionRegistry = map[int]regionInfo{
-	Code_Eu868: {func() Region { return &Eu868{} }},
-	Code_Us915: {func() Region { return &Us915{} }},
-	Code_Cn779: {func() Region { return &Cn779{} }},
-	Code_Eu433: {func() Region { return &Eu433{} }},
-	Code_Au915: {func() Region { return &Au915{} }},
-	Code_Cn470: {func() Region { return &Cn470{} }},
-	Code_As923: {func() Region { return &As923{} }},
-	Code_Kr920: {func() Region { return &Kr920{} }},
-	Code_In865: {func() Region { return &In865{} }},
-	Code_Ru864: {func() Region { return &Ru864{} }},
-	Code_EuFSK: {func() Region { return &EuFSK{} }},
-	Code_Ql256: {func() Region { return &Ql256{} }},
+	Code_Eu868: {func() Region { return &Eu868{} }, "EU868"},
+	Code_Us915: {func() Region { return &Us915{} }, "US915"},
+	Code_Cn779: {func() Region { return &Cn779{} }, "CN779"},
+	Code_Eu433: {func() Region { return &Eu433{} }, "EU433"},
+	Code_Au915: {func() Region { return &Au915{} }, "AU915"},
+	Code_Cn470: {func() Region { return &Cn470{} }, "CN470"},
+	Code_As923: {func() Region { return &As923{} }, "AS923"},
+	Code_Kr920: {func() Region { return &Kr920{} }, "KR920"},
+	Code_In865: {func() Region { return &In865{} }, "IN865"},
+	Code_Ru864: {func() Region { return &Ru864{} }, "RU864"},
+	Code_EuFSK: {func() Region { return &EuFSK{} }, "EUFSK"},
+	Code_Ql256: {func() Region { return &Ql256{} }, "QL256"},
 }
 
+// GetRegionalParameters returns the region Code, nil if unknown
 func GetRegionalParameters(Code int) Region {
 
-	r := regionRegistry[Code]
+	r, ok := regionRegistry[Code]
+	if !ok {
+		return nil
+	}
+
 	return r.info()
 
 }
 
+// RegionToString returns the name of the region Code, eg. EU868
+func RegionToString(Code int) string {
+	return regionRegistry[Code].name
+}
+
 func GetInfo(Code int) models.Informations {
 
 	region := GetRegionalParameters(Code)
+	if region == nil {
+		return models.Informations{}
+	}
+
 	region.Setup()
 
 	param := region.GetParameters()
@@ -0,0 +1,42 @@
+package device
+
+import (
+	"fmt"
+
+	"github.com/arslab/lwnsimulator/simulator/util"
+)
+
+// scheduleMACCommands queues the MAC commands of the schedules due with this uplink
+func (d *Device) scheduleMACCommands() {
+
+	d.Info.Status.CounterUplinks++
+
+	for i := range d.Info.Configuration.MACSchedules {
+
+		schedule := &d.Info.Configuration.MACSchedules[i]
+
+		if schedule.Every < 1 || d.Info.Status.CounterUplinks%uint32(schedule.Every) != 0 {
+			continue
+		}
+
+		deferred := false
+
+		command, err := schedule.Command()
+		if err == nil {
+			deferred, err = d.InjectMACCommand(command, schedule.FPort0)
+		}
+
+		if err != nil {
+			d.Print("", fmt.Errorf("MAC schedule %v: %w", schedule.CID, err), util.PrintBoth)
+			continue
+		}
+
+		if deferred {
+			d.Print(fmt.Sprintf("MAC schedule %v: FOpts full, command deferred to a later uplink", schedule.CID), nil, util.PrintOnlyConsole)
+			continue
+		}
+
+		d.Print(fmt.Sprintf("MAC schedule %v: command queued", schedule.CID), nil, util.PrintOnlyConsole)
+	}
+
+}
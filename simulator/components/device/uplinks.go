@@ -15,6 +15,7 @@ func (d *Device) CreateUplink() [][]byte {
 
 	var mtype lorawan.MType
 	var payload lorawan.Payload
+	var fport *uint8
 	var DataPayload []lorawan.DataPayload
 	var frames [][]byte
 
@@ -33,24 +34,23 @@ func (d *Device) CreateUplink() [][]byte {
 		d.Info.Status.DataUplink.ClassB = false
 	}
 
+	fport = d.Info.Status.DataUplink.FPort
+
 	switch d.Info.Status.Mode {
 	case util.Retransmission:
 		return d.Info.Status.LastUplinks
 
 	case util.Normal: //new uplink
 
-		if len(d.Info.Status.BufferUplinks) > 0 {
-
-			mtype = d.Info.Status.BufferUplinks[0].MType
-			payload = d.Info.Status.BufferUplinks[0].Payload
+		d.scheduleMACCommands()
 
-			switch len(d.Info.Status.BufferUplinks) {
-			case 1:
-				d.Info.Status.BufferUplinks = d.Info.Status.BufferUplinks[:0]
+		if queued, ok := d.Info.Status.UplinkQueue.Pop(time.Now()); ok {
 
-			default:
-				d.Info.Status.BufferUplinks = d.Info.Status.BufferUplinks[1:]
+			mtype = queued.MType
+			payload = queued.Payload
 
+			if queued.FPort != nil {
+				fport = queued.FPort
 			}
 
 		} else {
@@ -86,11 +86,11 @@ func (d *Device) CreateUplink() [][]byte {
 
 		alignedPayload := DataPayload[i]
 
-		if d.Info.Status.AlignCurrentTime {
+		if d.Info.Status.AlignCurrentTime && (fport == nil || *fport != 0) {
 			alignedPayload = alignWithCurrentTime(alignedPayload)
 		}
 
-		frame, err := d.Info.Status.DataUplink.GetFrame(mtype, alignedPayload, d.Info.DevAddr, d.Info.AppSKey, d.Info.NwkSKey, false)
+		frame, err := d.Info.Status.DataUplink.GetFrameOnPort(mtype, fport, alignedPayload, d.Info.DevAddr, d.Info.AppSKey, d.Info.NwkSKey, false)
 		if err != nil {
 			d.Print("", err, util.PrintBoth)
 			continue
@@ -153,6 +153,7 @@ func (d *Device) SendEmptyFrame() {
 	d.Class.SendData(info)
 
 	d.Print("Empty Frame sent", nil, util.PrintBoth)
+	d.uplinkSent(emptyFrame, info)
 }
 
 func (d *Device) SendAck() {
@@ -163,6 +164,7 @@ func (d *Device) SendAck() {
 	d.Class.SendData(info)
 
 	d.Print("ACK sent", nil, util.PrintBoth)
+	d.uplinkSent(ack, info)
 }
 
 func (d *Device) SendJoinRequest() {
@@ -1,10 +1,15 @@
 package forwarder
 
 import (
+	"encoding/base64"
+	"math"
+	"time"
+
 	dl "github.com/arslab/lwnsimulator/simulator/components/device/frames/downlink"
 	m "github.com/arslab/lwnsimulator/simulator/components/forwarder/models"
 	"github.com/arslab/lwnsimulator/simulator/resources/communication/buffer"
 	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
 	"github.com/brocaar/lorawan"
 )
 
@@ -27,17 +32,7 @@ func (f *Forwarder) AddDevice(d m.InfoDevice) {
 	defer f.Mutex.Unlock()
 
 	f.Devices[d.DevEUI] = d
-
-	inner := make(map[lorawan.EUI64]*buffer.BufferUplink)
-	f.DevToGw[d.DevEUI] = inner
-
-	for _, g := range f.Gateways {
-
-		if inRange(d, g) {
-			f.DevToGw[d.DevEUI][g.MACAddress] = g.Buffer
-		}
-
-	}
+	f.updateReachability(d)
 
 }
 
@@ -47,14 +42,7 @@ func (f *Forwarder) AddGateway(g m.InfoGateway) {
 	defer f.Mutex.Unlock()
 
 	f.Gateways[g.MACAddress] = g
-
-	for _, d := range f.Devices {
-
-		if inRange(d, g) {
-			f.DevToGw[d.DevEUI][g.MACAddress] = g.Buffer
-		}
-
-	}
+	f.updateGateway(g)
 }
 
 func (f *Forwarder) DeleteDevice(DevEUI lorawan.EUI64) {
@@ -88,6 +76,25 @@ func (f *Forwarder) UpdateDevice(d m.InfoDevice) {
 	f.AddDevice(d)
 }
 
+func (f *Forwarder) UpdateGateway(g m.InfoGateway) {
+
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+
+	old, ok := f.Gateways[g.MACAddress]
+	if !ok {
+		return
+	}
+
+	if g.Buffer == nil {
+		g.Buffer = old.Buffer
+	}
+
+	f.Gateways[g.MACAddress] = g
+	f.updateGateway(g)
+
+}
+
 func (f *Forwarder) Register(freq uint32, devEUI lorawan.EUI64, rDownlink *dl.ReceivedDownlink) {
 
 	f.Mutex.Lock()
@@ -141,18 +148,75 @@ func (f *Forwarder) Uplink(data pkt.RXPK, DevEUI lorawan.EUI64) {
 
 	rxpk := createPacket(data)
 
-	for _, up := range f.DevToGw[DevEUI] {
-		up.Push(rxpk)
+	now := time.Now()
+	airtime, _ := pkt.TimeOnAir(rxpk.Modu, rxpk.DatR, rxpk.CodR, int(rxpk.Size), 0, true)
+
+	var frame framelog.Frame
+	logging := false
+
+	phy, err := base64.StdEncoding.DecodeString(rxpk.Data)
+	if err == nil {
+
+		freq := uint32(math.Round(rxpk.Frequency * 1000000.0))
+		f.Capture.WriteLoRa(now, freq, rxpk.DatR, rxpk.RSSI, rxpk.LSNR, phy)
+
+		if f.FrameLog.IsOpen() {
+			frame = f.FrameLog.Decode(phy, true, []lorawan.EUI64{DevEUI})
+			logging = true
+		}
+
 	}
 
+	trace := f.Tracer.Uplink(DevEUI)
+	span := f.Tracer.StartSpan("forwarder.fanout", trace)
+	span.SetAttribute("gateways.in_range", len(f.DevToGw[DevEUI]))
+	span.SetAttribute("lora.airtime_ms", float64(airtime)/float64(time.Millisecond))
+
+	deaf, filtered := 0, 0
+
+	for macAddress, up := range f.DevToGw[DevEUI] {
+
+		g := f.Gateways[macAddress]
+
+		if g.JIT != nil && g.JIT.IsTransmitting(now.Add(-airtime), now) {
+			deaf++
+			continue //half-duplex, the gateway is deaf while it transmits
+		}
+
+		received, ok := g.Concentrator.Receive(rxpk)
+		if !ok {
+			filtered++
+			continue //the gateway doesn't listen on the frequency and datarate of the uplink
+		}
+
+		f.Tracer.AddHop(macAddress, received.Tmst, trace)
+		up.Push(received)
+
+		if logging { //a record for each gateway, as on the network server
+			record := frame
+			record.SetUplink(macAddress, received, airtime)
+			f.FrameLog.Write(record)
+		}
+
+	}
+
+	span.SetAttribute("gateways.deaf", deaf)
+	span.SetAttribute("gateways.filtered", filtered)
+	span.End()
+
 	f.Mutex.Unlock()
 
 }
 
-func (f *Forwarder) Downlink(data *lorawan.PHYPayload, freq uint32, macAddress lorawan.EUI64) {
+// Downlink delivers the frame transmitted by the gateway with txpk to the devices listening on freq
+func (f *Forwarder) Downlink(data *lorawan.PHYPayload, freq uint32, macAddress lorawan.EUI64, txpk pkt.TXPK) {
 
 	f.Mutex.Lock()
 
+	if f.FrameLog.IsOpen() {
+		f.logDownlink(data, macAddress, txpk)
+	}
+
 	var devMap = f.GwtoDev[freq]
 	if devMap == nil {
 		devMap = f.GwtoDev[0]
@@ -177,5 +241,68 @@ func (f *Forwarder) Downlink(data *lorawan.PHYPayload, freq uint32, macAddress l
 }
 
 func (f *Forwarder) Reset() {
-	f = Setup()
+
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+
+	f.DevToGw = make(map[lorawan.EUI64]map[lorawan.EUI64]*buffer.BufferUplink)
+	f.GwtoDev = make(map[uint32]map[lorawan.EUI64]map[lorawan.EUI64]*dl.ReceivedDownlink)
+	f.Devices = make(map[lorawan.EUI64]m.InfoDevice)
+	f.Gateways = make(map[lorawan.EUI64]m.InfoGateway)
+
+}
+
+// updateReachability rebuilds the set of gateways that hear the device, caller must hold the mutex
+func (f *Forwarder) updateReachability(d m.InfoDevice) {
+
+	inner, ok := f.DevToGw[d.DevEUI]
+	if !ok {
+		inner = make(map[lorawan.EUI64]*buffer.BufferUplink)
+		f.DevToGw[d.DevEUI] = inner
+	}
+
+	for key := range inner {
+		delete(inner, key)
+	}
+
+	for _, g := range f.Gateways {
+
+		if inRange(d, g) {
+			inner[g.MACAddress] = g.Buffer
+		}
+
+	}
+
+}
+
+// updateGateway adds g to the gateways that hear each device in its range and removes it from
+// the others, caller must hold the mutex
+func (f *Forwarder) updateGateway(g m.InfoGateway) {
+
+	for _, d := range f.Devices {
+
+		if inRange(d, g) {
+			f.DevToGw[d.DevEUI][g.MACAddress] = g.Buffer
+		} else {
+			delete(f.DevToGw[d.DevEUI], g.MACAddress)
+		}
+
+	}
+
+}
+
+// logDownlink writes the record of a downlink, caller must hold the mutex
+func (f *Forwarder) logDownlink(data *lorawan.PHYPayload, macAddress lorawan.EUI64, txpk pkt.TXPK) {
+
+	phy, err := data.MarshalBinary()
+	if err != nil {
+		return
+	}
+
+	airtime, _ := pkt.TimeOnAir(txpk.Modu, txpk.DatR, txpk.CodR, int(txpk.Size), int(txpk.Prea), !txpk.NCRC)
+
+	frame := f.FrameLog.Decode(phy, false, nil) //the frame log finds the device by DevAddr
+	frame.SetDownlink(macAddress, txpk, airtime)
+
+	f.FrameLog.Write(frame)
 }
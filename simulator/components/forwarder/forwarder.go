@@ -8,7 +8,10 @@ import (
 	m "github.com/arslab/lwnsimulator/simulator/components/forwarder/models"
 	"github.com/arslab/lwnsimulator/simulator/resources/communication/buffer"
 	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/pcap"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
 	loc "github.com/arslab/lwnsimulator/simulator/resources/location"
+	"github.com/arslab/lwnsimulator/simulator/resources/tracing"
 	"github.com/brocaar/lorawan"
 )
 
@@ -18,6 +21,9 @@ type Forwarder struct {
 	Devices  map[lorawan.EUI64]m.InfoDevice
 	Gateways map[lorawan.EUI64]m.InfoGateway
 	Mutex    sync.Mutex
+	Capture  *pcap.Capture      // records the frames on the air
+	FrameLog *framelog.FrameLog // decodes the frames on the air
+	Tracer   *tracing.Tracer    // traces the uplinks through the gateways
 }
 
 // GPS offset compensates for the drift between UTC and GPS time
@@ -33,7 +39,7 @@ func createPacket(info pkt.RXPK) pkt.RXPK {
 
 		Time:      tnow.Format(time.RFC3339),
 		Tmms:      &tmms,
-		Tmst:      uint32(tnow.Unix()),
+		Tmst:      pkt.GetTmst(tnow),
 		Channel:   info.Channel,
 		RFCH:      0,
 		Frequency: info.Frequency,
@@ -1,6 +1,7 @@
 package models
 
 import (
+	gwm "github.com/arslab/lwnsimulator/simulator/components/gateway/models"
 	"github.com/arslab/lwnsimulator/simulator/resources/communication/buffer"
 	loc "github.com/arslab/lwnsimulator/simulator/resources/location"
 	"github.com/brocaar/lorawan"
@@ -13,7 +14,9 @@ type InfoDevice struct {
 }
 
 type InfoGateway struct {
-	MACAddress lorawan.EUI64
-	Buffer     *buffer.BufferUplink
-	Location   loc.Location
+	MACAddress   lorawan.EUI64
+	Buffer       *buffer.BufferUplink
+	Location     loc.Location
+	Concentrator gwm.Concentrator
+	JIT          *gwm.JITQueue
 }
@@ -2,9 +2,11 @@ package gateway
 
 import (
 	"sync"
+	"time"
 
 	f "github.com/arslab/lwnsimulator/simulator/components/forwarder"
-	c "github.com/arslab/lwnsimulator/simulator/console"
+	"github.com/arslab/lwnsimulator/simulator/components/gateway/models"
+	"github.com/arslab/lwnsimulator/simulator/events"
 	res "github.com/arslab/lwnsimulator/simulator/resources"
 	"github.com/arslab/lwnsimulator/simulator/resources/communication/buffer"
 	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
@@ -28,29 +30,28 @@ func (g *Gateway) Setup(BridgeAddress *string,
 
 }
 
-func (g *Gateway) SetConsole(console *c.Console) {
-	g.Console = *console
+func (g *Gateway) SetEvents(bus *events.Bus) {
+	g.Events = bus
 }
 
 func (g *Gateway) TurnON() {
 
-	var err error
-
 	g.State = util.Running
+	g.Exit = make(chan struct{})
 
-	//udp
-	if g.Info.TypeGateway { //real
-		g.Info.Connection, err = udp.ConnectTo(g.Info.AddrIP + ":" + g.Info.Port)
-	} else { //virtual
-		g.Info.Connection, err = udp.ConnectTo(*g.Info.BridgeAddress)
-	}
+	g.Link.Reset()
+	g.JIT.Reset()
+	g.Backhaul.Setup(g.Info.Impairment)
+	g.Backhaul.Capture = &g.Resources.Capture
 
+	//udp
+	err := g.connect()
 	if err != nil {
 		g.Print("", err, util.PrintOnlyConsole)
-	} else {
-		g.Print("UDP connection with "+g.Info.Connection.RemoteAddr().String(), nil, util.PrintOnlyConsole)
 	}
 
+	g.connectionUp = time.Now()
+
 	go g.Receiver()
 
 	if g.Info.TypeGateway { //real
@@ -66,8 +67,25 @@ func (g *Gateway) TurnOFF() {
 
 	g.State = util.Stopped
 
-	g.BufferUplink.Signal()   //signal to sender
-	g.Info.Connection.Close() //signal to receiver
+	close(g.Exit)           //signal to reconnection
+	g.BufferUplink.Signal() //signal to sender
+
+	g.disconnect(models.LinkDisconnected) //signal to receiver
+
+}
+
+func (g *Gateway) SetImpairment(impairment udp.Impairment) {
+
+	g.Info.Impairment = impairment
+	g.Backhaul.SetImpairment(impairment)
+
+}
+
+func (g *Gateway) ChangeLocation(lat float64, lng float64, alt int32) {
+
+	g.Info.Location.Latitude = lat
+	g.Info.Location.Longitude = lng
+	g.Info.Location.Altitude = alt
 
 }
 
@@ -0,0 +1,221 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/arslab/lwnsimulator/simulator/components/gateway/models"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	"github.com/arslab/lwnsimulator/simulator/util"
+	"github.com/arslab/lwnsimulator/socket"
+)
+
+const (
+	// AckTimeout is the time within a PUSH ACK or PULL ACK is expected
+	AckTimeout = 2 * time.Second
+
+	// SilenceKeepAlives is the number of keep alive periods without ACK after which the network server is considered silent
+	SilenceKeepAlives = 3
+
+	MinBackoff = 1 * time.Second
+	MaxBackoff = 60 * time.Second
+)
+
+func (g *Gateway) remoteAddress() string {
+
+	if g.Info.TypeGateway { //real
+		return g.Info.AddrIP + ":" + g.Info.Port
+	}
+
+	return *g.Info.BridgeAddress
+}
+
+// connect resolves the remote address again, so that a changed DNS record is followed
+func (g *Gateway) connect() error {
+
+	g.setLinkState(models.LinkConnecting)
+
+	dial := g.Resources.Dial
+	if dial == nil {
+		dial = udp.Dial
+	}
+
+	connection, err := dial(g.remoteAddress())
+	if err != nil {
+		g.setLinkState(models.LinkDisconnected)
+		return err
+	}
+
+	g.setConn(connection)
+
+	g.Print("UDP connection with "+connection.RemoteAddr().String(), nil, util.PrintOnlyConsole)
+
+	return nil
+}
+
+// disconnect closes the connection, the receiver will establish a new one
+func (g *Gateway) disconnect(state int) {
+
+	if connection := g.setConn(nil); connection != nil {
+		connection.Close()
+	}
+
+	g.setLinkState(state)
+}
+
+// conn returns the connection, nil while the gateway is disconnected. The senders use this
+// snapshot, a closed connection only fails their write
+func (g *Gateway) conn() net.Conn {
+
+	g.connMutex.Lock()
+	defer g.connMutex.Unlock()
+
+	return g.Info.Connection
+}
+
+// setConn replaces the connection and returns the previous one
+func (g *Gateway) setConn(connection net.Conn) net.Conn {
+
+	g.connMutex.Lock()
+	defer g.connMutex.Unlock()
+
+	previous := g.Info.Connection
+	g.Info.Connection = connection
+
+	return previous
+}
+
+// reconnect tries to connect with an exponential backoff until it succeeds or the gateway is turned off
+func (g *Gateway) reconnect() {
+
+	for g.CanExecute() {
+
+		backoff := g.Link.NextBackoff(MinBackoff, MaxBackoff)
+
+		msg := fmt.Sprintf("Reconnect to %v in %v", g.remoteAddress(), backoff)
+		g.Print(msg, nil, util.PrintBoth)
+
+		select {
+		case <-time.After(backoff):
+		case <-g.Exit:
+			return
+		}
+
+		err := g.connect()
+		if err != nil {
+			g.Print("", err, util.PrintBoth)
+			continue
+		}
+
+		g.connectionUp = time.Now()
+
+		if !g.Info.TypeGateway {
+
+			err = g.sendPullData()
+			if err != nil {
+				g.Print("", err, util.PrintBoth)
+			}
+
+		}
+
+		return
+	}
+
+}
+
+// checkLink expires the unacknowledged datagrams and reports if the network server is silent
+func (g *Gateway) checkLink() bool {
+
+	expired := g.Link.Expire(AckTimeout)
+	if expired > 0 {
+
+		g.Stat.ACKR = g.Link.ACKR()
+
+		msg := fmt.Sprintf("%v datagrams not acknowledged", expired)
+		g.Print(msg, nil, util.PrintOnlyConsole)
+
+	}
+
+	if g.Info.TypeGateway || g.Info.KeepAlive <= 0 { //no keep alive, no expected ACK
+		return false
+	}
+
+	return g.Link.IsSilent(g.connectionUp, SilenceKeepAlives*g.Info.KeepAlive)
+}
+
+// sent records the datagram before it is sent, its ACK may arrive before the send returns
+func (g *Gateway) sent(packet []byte) {
+
+	if len(packet) < 4 { //no header, no ACK expected
+		return
+	}
+
+	g.Link.Sent(pkt.GetToken(packet), packet[3])
+}
+
+// unsent forgets the datagram that could not be sent
+func (g *Gateway) unsent(packet []byte) {
+	g.Link.Cancel(pkt.GetToken(packet))
+}
+
+func (g *Gateway) acknowledged(packet []byte, typePacket byte) bool {
+
+	ok := g.Link.Ack(pkt.GetToken(packet), typePacket)
+	if ok && g.CanExecute() {
+		g.Stat.ACKR = g.Link.ACKR()
+		g.setLinkState(models.LinkConnected)
+	}
+
+	return ok
+}
+
+func (g *Gateway) setLinkState(state int) {
+
+	if !g.Link.SetState(state) {
+		return
+	}
+
+	g.Print("Connection "+models.LinkStateToString(state), nil, util.PrintOnlyConsole)
+
+	typeEvent := events.TypeGatewayDisconnected
+	switch state {
+	case models.LinkConnecting:
+		typeEvent = events.TypeGatewayConnecting
+	case models.LinkConnected:
+		typeEvent = events.TypeGatewayConnected
+	}
+
+	g.Events.Publish(&events.GatewayLink{
+		Header:  events.NewHeader(typeEvent),
+		Gateway: g.Ref(),
+		Link:    g.GetLinkStatus(),
+	})
+
+}
+
+func (g *Gateway) GetLinkStatus() socket.LinkGw {
+
+	g.Link.Mutex.Lock()
+	defer g.Link.Mutex.Unlock()
+
+	status := socket.LinkGw{
+		Id:            g.Id,
+		Name:          g.Info.Name,
+		State:         models.LinkStateToString(g.Link.State),
+		Reconnections: g.Link.Reconnections,
+		ACKR:          g.Stat.ACKR,
+	}
+
+	if g.State == util.Stopped {
+		status.State = models.LinkStateToString(models.LinkDisconnected)
+	}
+
+	if !g.Link.LastAck.IsZero() {
+		status.LastAck = g.Link.LastAck.Format(time.RFC3339)
+	}
+
+	return status
+}
@@ -2,30 +2,40 @@ package gateway
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	f "github.com/arslab/lwnsimulator/simulator/components/forwarder"
 	"github.com/arslab/lwnsimulator/simulator/components/gateway/models"
-	c "github.com/arslab/lwnsimulator/simulator/console"
+	"github.com/arslab/lwnsimulator/simulator/events"
 	res "github.com/arslab/lwnsimulator/simulator/resources"
 	"github.com/arslab/lwnsimulator/simulator/resources/communication/buffer"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
 	"github.com/arslab/lwnsimulator/simulator/util"
-	"github.com/arslab/lwnsimulator/socket"
 )
 
 type Gateway struct {
 	Id   int                `json:"id"`
 	Info models.InfoGateway `json:"info"`
 
-	State int `json:"-"`
+	State int           `json:"-"`
+	Exit  chan struct{} `json:"-"`
 
 	Resources *res.Resources `json:"-"` //is a pointer
 	Forwarder *f.Forwarder   `json:"-"` //is a pointer
 
-	Stat models.Stat `json:"-"`
+	Stat models.Stat     `json:"-"`
+	Link models.Link     `json:"-"`
+	JIT  models.JITQueue `json:"-"`
+
+	lastStat     models.Stat // counters at the last stat report
+	connectionUp time.Time   // reference of the silence detection
+	connMutex    sync.Mutex  // of Info.Connection, read by the senders while the receiver reconnects
 
 	BufferUplink buffer.BufferUplink `json:"-"`
-	Console      c.Console           `json:"-"`
+	Backhaul     udp.Backhaul        `json:"-"`
+	Events       *events.Bus         `json:"-"`
 }
 
 func (g *Gateway) CanExecute() bool {
@@ -40,32 +50,52 @@ func (g *Gateway) CanExecute() bool {
 
 func (g *Gateway) Print(content string, err error, printType int) {
 
-	now := time.Now()
-	message := ""
 	messageLog := ""
-	event := socket.EventGw
 
 	if err == nil {
-		message = fmt.Sprintf("[ %s ] GW[%s]: %s", now.Format(time.Stamp), g.Info.Name, content)
 		messageLog = fmt.Sprintf("GW[%s]: %s", g.Info.Name, content)
 	} else {
-		message = fmt.Sprintf("[ %s ] GW[%s] [ERROR]: %s", now.Format(time.Stamp), g.Info.Name, err)
 		messageLog = fmt.Sprintf("GW[%s] [ERROR]: %s", g.Info.Name, err)
-		event = socket.EventError
 	}
 
-	data := socket.ConsoleLog{
-		Name: g.Info.Name,
-		Msg:  message,
-	}
+	g.Events.Publish(&events.Log{
+		Header:  events.NewHeader(events.TypeLog),
+		Source:  events.SourceGateway,
+		Id:      g.Id,
+		Name:    g.Info.Name,
+		Message: messageLog,
+		Error:   err != nil,
+		Output:  printType,
+	})
+}
+
+// Ref identifies the gateway in its events
+func (g *Gateway) Ref() events.Gateway {
 
-	switch printType {
-	case util.PrintBoth:
-		g.Console.PrintSocket(event, data)
-		g.Console.PrintLog(messageLog)
-	case util.PrintOnlySocket:
-		g.Console.PrintSocket(event, data)
-	case util.PrintOnlyConsole:
-		g.Console.PrintLog(messageLog)
+	return events.Gateway{
+		Id:         g.Id,
+		Name:       g.Info.Name,
+		MACAddress: g.Info.MACAddress,
 	}
+
+}
+
+func (g *Gateway) datagramSent(typePacket byte) {
+
+	g.Events.Publish(&events.Datagram{
+		Header:     events.NewHeader(events.TypeDatagramSent),
+		Gateway:    g.Ref(),
+		PacketType: pkt.PacketToString(typePacket),
+	})
+
+}
+
+func (g *Gateway) datagramReceived(typePacket byte) {
+
+	g.Events.Publish(&events.Datagram{
+		Header:     events.NewHeader(events.TypeDatagramReceived),
+		Gateway:    g.Ref(),
+		PacketType: pkt.PacketToString(typePacket),
+	})
+
 }
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+)
+
+const (
+	NbMultiSFChannels = 8
+
+	// IF chain of the LoRa single-SF and FSK channels, after the multi-SF ones
+	IndexLoraStd = 8
+	IndexFSK     = 9
+
+	BandwidthMultiSF = 125000
+)
+
+// Concentrator is the radio configuration of the gateway (SX1301/SX1302 style)
+// empty, the gateway receives every frequency as before
+type Concentrator struct {
+	Radios  []Radio                      `json:"radios"`  // RF chains
+	MultiSF [NbMultiSFChannels]IFChannel `json:"multiSF"` // IF chains 0-7, LoRa multi spreading factor at 125 kHz
+	LoraStd IFChannel                    `json:"loraStd"` // IF chain 8, LoRa single spreading factor
+	FSK     IFChannel                    `json:"fsk"`     // IF chain 9
+}
+
+type Radio struct {
+	Enable bool   `json:"enable"`
+	Type   string `json:"type"`
+	Freq   uint32 `json:"freq"` // center frequency in Hz
+}
+
+type IFChannel struct {
+	Enable       bool   `json:"enable"`
+	Radio        uint8  `json:"radio"`
+	IF           int32  `json:"if"`                     // offset from the center frequency of the radio in Hz
+	Bandwidth    uint32 `json:"bandwidth,omitempty"`    // only LoRa std and FSK
+	SpreadFactor uint8  `json:"spreadFactor,omitempty"` // only LoRa std
+	Datarate     uint32 `json:"datarate,omitempty"`     // only FSK
+}
+
+func (c *Concentrator) IsConfigured() bool {
+	return len(c.Radios) > 0
+}
+
+// Receive returns the rxpk as demodulated by the IF chain that listens on its frequency and datarate,
+// false if no IF chain receives it
+func (c *Concentrator) Receive(rxpk pkt.RXPK) (pkt.RXPK, bool) {
+
+	if !c.IsConfigured() {
+		return rxpk, true
+	}
+
+	freq := uint32(math.Round(rxpk.Frequency * 1000000.0))
+
+	if rxpk.Modu == "FSK" {
+
+		if c.listens(c.FSK, freq) {
+
+			rxpk.Channel = IndexFSK
+			rxpk.RFCH = c.FSK.Radio
+			return rxpk, true
+
+		}
+
+		return rxpk, false
+	}
+
+	var sf, bw uint32
+	_, err := fmt.Sscanf(rxpk.DatR, "SF%dBW%d", &sf, &bw)
+	if err != nil {
+		return rxpk, false
+	}
+
+	if bw*1000 == BandwidthMultiSF && sf >= 7 && sf <= 12 {
+
+		for i, ch := range c.MultiSF {
+
+			if c.listens(ch, freq) {
+
+				rxpk.Channel = uint16(i)
+				rxpk.RFCH = ch.Radio
+				return rxpk, true
+
+			}
+
+		}
+
+	}
+
+	if c.listens(c.LoraStd, freq) && bw*1000 == c.LoraStd.Bandwidth && sf == uint32(c.LoraStd.SpreadFactor) {
+
+		rxpk.Channel = IndexLoraStd
+		rxpk.RFCH = c.LoraStd.Radio
+		return rxpk, true
+
+	}
+
+	return rxpk, false
+}
+
+func (c *Concentrator) listens(ch IFChannel, freq uint32) bool {
+
+	if !ch.Enable || int(ch.Radio) >= len(c.Radios) || !c.Radios[ch.Radio].Enable {
+		return false
+	}
+
+	return int64(c.Radios[ch.Radio].Freq)+int64(ch.IF) == int64(freq)
+}
+
+//*******************************global_conf.json**************************************/
+
+type semtechRadio struct {
+	Enable bool   `json:"enable"`
+	Type   string `json:"type"`
+	Freq   uint32 `json:"freq"`
+}
+
+type semtechChannel struct {
+	Enable       bool   `json:"enable"`
+	Radio        uint8  `json:"radio"`
+	IF           int32  `json:"if"`
+	Bandwidth    uint32 `json:"bandwidth"`
+	SpreadFactor uint8  `json:"spread_factor"`
+	Datarate     uint32 `json:"datarate"`
+}
+
+// ParseGlobalConf imports the concentrator of a Semtech packet forwarder's global_conf.json,
+// with its /* */ and // comments as the packet forwarder accepts them
+func ParseGlobalConf(data []byte) (Concentrator, error) {
+
+	var c Concentrator
+
+	data = stripComments(data)
+
+	conf := struct {
+		SX1301 map[string]json.RawMessage `json:"SX1301_conf"`
+		SX130x map[string]json.RawMessage `json:"SX130x_conf"`
+	}{}
+
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return c, err
+	}
+
+	sx := conf.SX1301
+	if sx == nil {
+		sx = conf.SX130x
+	}
+
+	if sx == nil {
+		return c, errors.New("SX1301_conf or SX130x_conf expected")
+	}
+
+	for i := 0; ; i++ {
+
+		raw, ok := sx["radio_"+strconv.Itoa(i)]
+		if !ok {
+			break
+		}
+
+		var r semtechRadio
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return c, err
+		}
+
+		c.Radios = append(c.Radios, Radio(r))
+	}
+
+	if len(c.Radios) == 0 {
+		return c, errors.New("No radio configured")
+	}
+
+	for i := 0; i < NbMultiSFChannels; i++ {
+
+		ch, err := parseSemtechChannel(sx, "chan_multiSF_"+strconv.Itoa(i))
+		if err != nil {
+			return c, err
+		}
+
+		c.MultiSF[i] = ch
+	}
+
+	var err error
+
+	c.LoraStd, err = parseSemtechChannel(sx, "chan_Lora_std")
+	if err != nil {
+		return c, err
+	}
+
+	c.FSK, err = parseSemtechChannel(sx, "chan_FSK")
+
+	return c, err
+}
+
+// stripComments replaces the comments outside the strings of data with spaces, so that the
+// offsets of the JSON errors are kept
+func stripComments(data []byte) []byte {
+
+	out := append([]byte{}, data...)
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+
+		switch {
+
+		case inString:
+			if out[i] == '\\' {
+				i++
+			} else if out[i] == '"' {
+				inString = false
+			}
+
+		case out[i] == '"':
+			inString = true
+
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out) - i - 2 //unterminated, up to the end
+			} else {
+				end += 2
+			}
+			blank(out[i : i+2+end])
+			i += 1 + end
+
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out) - i
+			}
+			blank(out[i : i+end])
+			i += end - 1
+
+		}
+
+	}
+
+	return out
+}
+
+// blank replaces the bytes of a comment with spaces, keeping the new lines
+func blank(comment []byte) {
+
+	for i := range comment {
+		if comment[i] != '\n' {
+			comment[i] = ' '
+		}
+	}
+
+}
+
+func parseSemtechChannel(sx map[string]json.RawMessage, key string) (IFChannel, error) {
+
+	var ch semtechChannel
+
+	raw, ok := sx[key]
+	if !ok {
+		return IFChannel{}, nil
+	}
+
+	if err := json.Unmarshal(raw, &ch); err != nil {
+		return IFChannel{}, fmt.Errorf("%v: %v", key, err)
+	}
+
+	return IFChannel(ch), nil
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"testing"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+)
+
+const globalConf = `{
+	/* EU868, radio_0 at 867.5 MHz and radio_1 at 868.5 MHz */
+	"SX1301_conf": {
+		"lorawan_public": true, // the public sync word
+		"radio_0": {"enable": true, "type": "SX1257", "freq": 867500000},
+		"radio_1": {"enable": true, "type": "SX1257", "freq": 868500000},
+		"chan_multiSF_0": {"enable": true, "radio": 1, "if": -400000},
+		"chan_multiSF_1": {"enable": true, "radio": 1, "if": -200000},
+		"chan_multiSF_2": {"enable": true, "radio": 1, "if": 0},
+		"chan_multiSF_3": {"enable": false, "radio": 0, "if": -400000},
+		"chan_Lora_std": {"enable": true, "radio": 1, "if": -200000, "bandwidth": 250000, "spread_factor": 7},
+		"chan_FSK": {"enable": true, "radio": 1, "if": 300000, "bandwidth": 125000, "datarate": 50000}
+	},
+	"gateway_conf": {"server_address": "http://localhost/*not a comment*/", "serv_port_up": 1700}
+}`
+
+func TestParseGlobalConf(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		data       string
+		wantErr    bool
+		wantRadios int
+	}{
+		{"with comments", globalConf, false, 2},
+		{"SX130x", `{"SX130x_conf": {"radio_0": {"enable": true, "freq": 867500000}}}`, false, 1},
+		{"unterminated comment", `{"SX1301_conf": {"radio_0": {"enable": true, "freq": 867500000}}} /* end`, false, 1},
+		{"no concentrator", `{"gateway_conf": {}}`, true, 0},
+		{"no radio", `{"SX1301_conf": {}}`, true, 0},
+		{"invalid channel", `{"SX1301_conf": {"radio_0": {"freq": 1}, "chan_FSK": {"if": "a"}}}`, true, 1},
+		{"invalid JSON", `{"SX1301_conf": `, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			c, err := ParseGlobalConf([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+
+			if len(c.Radios) != tt.wantRadios {
+				t.Errorf("%v radios, want %v", len(c.Radios), tt.wantRadios)
+			}
+
+		})
+	}
+
+}
+
+func TestParseGlobalConfChannels(t *testing.T) {
+
+	c, err := ParseGlobalConf([]byte(globalConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Radios[1] != (Radio{Enable: true, Type: "SX1257", Freq: 868500000}) {
+		t.Errorf("radio_1 %+v", c.Radios[1])
+	}
+
+	if c.MultiSF[1] != (IFChannel{Enable: true, Radio: 1, IF: -200000}) || c.MultiSF[3].Enable {
+		t.Errorf("multi-SF channels %+v", c.MultiSF)
+	}
+
+	if c.LoraStd != (IFChannel{Enable: true, Radio: 1, IF: -200000, Bandwidth: 250000, SpreadFactor: 7}) {
+		t.Errorf("LoRa std channel %+v", c.LoraStd)
+	}
+
+	if c.FSK != (IFChannel{Enable: true, Radio: 1, IF: 300000, Bandwidth: 125000, Datarate: 50000}) {
+		t.Errorf("FSK channel %+v", c.FSK)
+	}
+
+}
+
+func TestStripComments(t *testing.T) {
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"none", `{"a": 1}`, `{"a": 1}`},
+		{"block", `{"a": /* one */ 1}`, `{"a":           1}`},
+		{"line", "{\"a\": 1 // one\n}", "{\"a\": 1       \n}"},
+		{"multi-line block", "/* a\nb */{}", "    \n    {}"},
+		{"in a string", `{"a": "/* b */ // c"}`, `{"a": "/* b */ // c"}`},
+		{"escaped quote", `{"a": "\" /* b */"}`, `{"a": "\" /* b */"}`},
+		{"unterminated block", `{} /* a`, `{}     `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripComments([]byte(tt.data))); got != tt.want {
+				t.Errorf("stripComments(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestConcentratorReceive(t *testing.T) {
+
+	c, err := ParseGlobalConf([]byte(globalConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		rxpk        pkt.RXPK
+		want        bool
+		wantChannel uint16
+	}{
+		{"multi-SF", pkt.RXPK{Frequency: 868.3, Modu: "LORA", DatR: "SF9BW125"}, true, 1},
+		{"multi-SF SF12", pkt.RXPK{Frequency: 868.5, Modu: "LORA", DatR: "SF12BW125"}, true, 2},
+		{"disabled channel", pkt.RXPK{Frequency: 867.1, Modu: "LORA", DatR: "SF7BW125"}, false, 0},
+		{"no channel", pkt.RXPK{Frequency: 869.525, Modu: "LORA", DatR: "SF7BW125"}, false, 0},
+		{"LoRa std", pkt.RXPK{Frequency: 868.3, Modu: "LORA", DatR: "SF7BW250"}, true, IndexLoraStd},
+		{"LoRa std other SF", pkt.RXPK{Frequency: 868.3, Modu: "LORA", DatR: "SF8BW250"}, false, 0},
+		{"FSK", pkt.RXPK{Frequency: 868.8, Modu: "FSK", DatR: "50000"}, true, IndexFSK},
+		{"FSK other frequency", pkt.RXPK{Frequency: 868.3, Modu: "FSK", DatR: "50000"}, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			rxpk, got := c.Receive(tt.rxpk)
+			if got != tt.want {
+				t.Fatalf("Receive = %v, want %v", got, tt.want)
+			}
+
+			if got && (rxpk.Channel != tt.wantChannel || rxpk.RFCH != 1) {
+				t.Errorf("chan %v, rfch %v, want %v, 1", rxpk.Channel, rxpk.RFCH, tt.wantChannel)
+			}
+
+		})
+	}
+
+}
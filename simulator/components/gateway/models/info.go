@@ -6,21 +6,26 @@ import (
 	"net"
 	"time"
 
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
 	loc "github.com/arslab/lwnsimulator/simulator/resources/location"
 	"github.com/brocaar/lorawan"
 )
 
 type InfoGateway struct {
-	Active        bool          `json:"active"`
-	TypeGateway   bool          `json:"typeGateway"` //true real
-	Name          string        `json:"name"`
-	MACAddress    lorawan.EUI64 `json:"macAddress"`
-	Location      loc.Location  `json:"location"`
-	KeepAlive     time.Duration `json:"keepAlive"`
-	Connection    *net.UDPConn  `json:"-"`
-	AddrIP        string        `json:"ip"`
-	Port          string        `json:"port"`
-	BridgeAddress *string       `json:"-"` //is a pointer
+	Active        bool           `json:"active"`
+	TypeGateway   bool           `json:"typeGateway"` //true real
+	Name          string         `json:"name"`
+	MACAddress    lorawan.EUI64  `json:"macAddress"`
+	Location      loc.Location   `json:"location"`
+	KeepAlive     time.Duration  `json:"keepAlive"`
+	StatInterval  time.Duration  `json:"statInterval"` // interval of the status reports
+	BatchWindow   time.Duration  `json:"batchWindow"`  // time waited for other uplinks to send in the same PUSH DATA
+	Connection    net.Conn       `json:"-"`
+	AddrIP        string         `json:"ip"`
+	Port          string         `json:"port"`
+	BridgeAddress *string        `json:"-"` //is a pointer
+	Impairment    udp.Impairment `json:"impairment"`
+	Concentrator  Concentrator   `json:"concentrator"`
 }
 
 func (g *InfoGateway) MarshalJSON() ([]byte, error) {
@@ -28,13 +33,17 @@ func (g *InfoGateway) MarshalJSON() ([]byte, error) {
 	type Alias InfoGateway
 
 	return json.Marshal(&struct {
-		MACAddress string `json:"macAddress"`
-		KeepAlive  int    `json:"keepAlive"`
+		MACAddress   string `json:"macAddress"`
+		KeepAlive    int    `json:"keepAlive"`
+		StatInterval int    `json:"statInterval"`
+		BatchWindow  int    `json:"batchWindow"`
 
 		*Alias
 	}{
-		MACAddress: hex.EncodeToString(g.MACAddress[:]),
-		KeepAlive:  int(g.KeepAlive / time.Second),
+		MACAddress:   hex.EncodeToString(g.MACAddress[:]),
+		KeepAlive:    int(g.KeepAlive / time.Second),
+		StatInterval: int(g.StatInterval / time.Second),
+		BatchWindow:  int(g.BatchWindow / time.Millisecond),
 
 		Alias: (*Alias)(g),
 	})
@@ -46,8 +55,10 @@ func (g *InfoGateway) UnmarshalJSON(data []byte) error {
 	type Alias InfoGateway
 
 	aux := &struct {
-		MACAddress string `json:"macAddress"`
-		KeepAlive  int    `json:"keepAlive"`
+		MACAddress   string `json:"macAddress"`
+		KeepAlive    int    `json:"keepAlive"`
+		StatInterval int    `json:"statInterval"`
+		BatchWindow  int    `json:"batchWindow"`
 		*Alias
 	}{
 		Alias: (*Alias)(g),
@@ -61,6 +72,8 @@ func (g *InfoGateway) UnmarshalJSON(data []byte) error {
 	copy(g.MACAddress[:8], MACAddressTmp)
 
 	g.KeepAlive = time.Duration(aux.KeepAlive) * time.Second
+	g.StatInterval = time.Duration(aux.StatInterval) * time.Second
+	g.BatchWindow = time.Duration(aux.BatchWindow) * time.Millisecond
 
 	return nil
 }
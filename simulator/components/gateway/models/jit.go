@@ -0,0 +1,121 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+)
+
+// delays of the just-in-time queue of the Semtech packet forwarder
+const (
+	TxStartDelay      = 1500 * time.Microsecond // time to ramp up the radio before a TX
+	TxMarginDelay     = 1 * time.Millisecond    // guard between two TX
+	TxJitDelay        = 30 * time.Millisecond   // minimum time to schedule a TX
+	TxMaxAdvanceDelay = 3 * 128 * time.Second   // maximum time in advance a TX is scheduled
+)
+
+// Transmission is a downlink occupying the radio of the gateway
+type Transmission struct {
+	Start time.Time
+	End   time.Time
+}
+
+// JITQueue contains the downlinks scheduled by the gateway, one at a time (half-duplex)
+type JITQueue struct {
+	Mutex sync.Mutex
+	Queue []Transmission
+}
+
+// Enqueue schedules a TX of length airtime at start and returns the TX_ACK error (pkt.NONE if accepted)
+func (q *JITQueue) Enqueue(start time.Time, airtime time.Duration, immediate bool) (time.Time, string) {
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	now := time.Now()
+	q.clean(now)
+
+	if immediate {
+
+		start = now.Add(TxJitDelay)
+
+		for _, tx := range q.Queue { //first free slot
+			if overlaps(start, start.Add(airtime), tx) {
+				start = tx.End.Add(TxMarginDelay + TxStartDelay)
+			}
+		}
+
+	} else {
+
+		if start.Before(now.Add(TxJitDelay)) {
+			return start, pkt.TOO_LATE
+		}
+
+		if start.After(now.Add(TxMaxAdvanceDelay)) {
+			return start, pkt.TOO_EARLY
+		}
+
+		for _, tx := range q.Queue {
+			if overlaps(start, start.Add(airtime), tx) {
+				return start, pkt.COLLISION_PACKET
+			}
+		}
+
+	}
+
+	q.Queue = append(q.Queue, Transmission{
+		Start: start,
+		End:   start.Add(airtime),
+	})
+
+	sort.Slice(q.Queue, func(i, j int) bool {
+		return q.Queue[i].Start.Before(q.Queue[j].Start)
+	})
+
+	return start, pkt.NONE
+}
+
+// IsTransmitting reports if the radio is busy transmitting between from and to
+func (q *JITQueue) IsTransmitting(from time.Time, to time.Time) bool {
+
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+
+	for _, tx := range q.Queue {
+		if overlaps(from, to, tx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *JITQueue) Reset() {
+
+	q.Mutex.Lock()
+	q.Queue = q.Queue[:0]
+	q.Mutex.Unlock()
+
+}
+
+// clean drops the transmissions already ended, caller must hold the mutex
+func (q *JITQueue) clean(now time.Time) {
+
+	i := 0
+	for _, tx := range q.Queue {
+		if tx.End.After(now) {
+			q.Queue[i] = tx
+			i++
+		}
+	}
+
+	q.Queue = q.Queue[:i]
+}
+
+func overlaps(start time.Time, end time.Time, tx Transmission) bool {
+
+	// the radio is busy from the ramp up to the guard after the end
+	return start.Before(tx.End.Add(TxMarginDelay)) && tx.Start.Add(-TxStartDelay).Before(end)
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+)
+
+func TestJITQueueEnqueue(t *testing.T) {
+
+	const airtime = 100 * time.Millisecond
+
+	scheduled := time.Second // from now, of the transmission already in the queue
+	end := scheduled + airtime
+
+	tests := []struct {
+		name      string
+		start     time.Duration // from now
+		immediate bool
+		want      string
+		wantStart time.Duration // from now, when accepted
+	}{
+		{"free", 2 * time.Second, false, pkt.NONE, 2 * time.Second},
+		{"too late", 10 * time.Millisecond, false, pkt.TOO_LATE, 0},
+		{"too early", TxMaxAdvanceDelay + time.Minute, false, pkt.TOO_EARLY, 0},
+		{"overlap", scheduled + airtime/2, false, pkt.COLLISION_PACKET, 0},
+		{"within the margin after", end + TxMarginDelay/2, false, pkt.COLLISION_PACKET, 0},
+		{"within the ramp up before", scheduled - airtime - TxStartDelay/2, false, pkt.COLLISION_PACKET, 0},
+		{"after the margin", end + TxMarginDelay + TxStartDelay, false, pkt.NONE, end + TxMarginDelay + TxStartDelay},
+		{"immediate", 0, true, pkt.NONE, TxJitDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			now := time.Now()
+
+			q := JITQueue{Queue: []Transmission{{
+				Start: now.Add(scheduled),
+				End:   now.Add(end),
+			}}}
+
+			start, got := q.Enqueue(now.Add(tt.start), airtime, tt.immediate)
+			if got != tt.want {
+				t.Fatalf("Enqueue = %v, want %v", got, tt.want)
+			}
+
+			if got != pkt.NONE {
+
+				if len(q.Queue) != 1 {
+					t.Errorf("%v transmissions queued, want 1", len(q.Queue))
+				}
+
+				return
+			}
+
+			if diff := start.Sub(now.Add(tt.wantStart)); diff < 0 || diff > 10*time.Millisecond {
+				t.Errorf("start %v from the expected one", diff)
+			}
+
+			if len(q.Queue) != 2 || q.Queue[0].Start.After(q.Queue[1].Start) {
+				t.Errorf("queue %v, want 2 transmissions by start", q.Queue)
+			}
+
+		})
+	}
+
+}
+
+func TestJITQueueImmediateBusy(t *testing.T) {
+
+	const airtime = 100 * time.Millisecond
+
+	now := time.Now()
+	end := now.Add(TxJitDelay + airtime)
+
+	q := JITQueue{Queue: []Transmission{{Start: now.Add(TxJitDelay), End: end}}}
+
+	start, got := q.Enqueue(time.Time{}, airtime, true)
+	if got != pkt.NONE {
+		t.Fatalf("Enqueue = %v, want %v", got, pkt.NONE)
+	}
+
+	if want := end.Add(TxMarginDelay + TxStartDelay); !start.Equal(want) {
+		t.Errorf("start %v after the busy radio, want %v", start.Sub(end), want.Sub(end))
+	}
+
+}
+
+func TestJITQueueIsTransmitting(t *testing.T) {
+
+	now := time.Now()
+	tx := Transmission{Start: now.Add(time.Second), End: now.Add(2 * time.Second)}
+
+	tests := []struct {
+		name string
+		from time.Duration
+		to   time.Duration
+		want bool
+	}{
+		{"before", 0, 500 * time.Millisecond, false},
+		{"during", 1200 * time.Millisecond, 1300 * time.Millisecond, true},
+		{"across the start", 500 * time.Millisecond, 1100 * time.Millisecond, true},
+		{"in the margin", 2*time.Second + TxMarginDelay/2, 3 * time.Second, true},
+		{"after", 3 * time.Second, 4 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			q := JITQueue{Queue: []Transmission{tx}}
+
+			if got := q.IsTransmitting(now.Add(tt.from), now.Add(tt.to)); got != tt.want {
+				t.Errorf("IsTransmitting = %v, want %v", got, tt.want)
+			}
+
+		})
+	}
+
+}
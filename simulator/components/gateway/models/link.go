@@ -0,0 +1,233 @@
+package models
+
+import (
+	"sync"
+	"time"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+)
+
+const (
+	LinkDisconnected = iota
+	LinkConnecting
+	LinkConnected
+	LinkSilent
+)
+
+// Link tracks the state of the UDP connection with the network server
+type Link struct {
+	Mutex         sync.Mutex
+	State         int
+	LastAck       time.Time
+	Reconnections uint32
+	Backoff       time.Duration
+
+	PushSent  uint32 // PUSH DATA whose acknowledgement is settled (acked or expired)
+	PushAcked uint32
+
+	lastPushSent  uint32 // counters at the last stat report
+	lastPushAcked uint32
+
+	pending map[uint16]datagram // outstanding PUSH DATA and PULL DATA by token
+}
+
+type datagram struct {
+	Type byte
+	Sent time.Time
+}
+
+// Reset clears the outstanding datagrams and the state of the link
+func (l *Link) Reset() {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	l.State = LinkDisconnected
+	l.LastAck = time.Time{}
+	l.Backoff = 0
+	l.pending = make(map[uint16]datagram)
+
+	l.PushSent, l.PushAcked = 0, 0
+	l.lastPushSent, l.lastPushAcked = 0, 0
+
+}
+
+// Sent records a datagram waiting for its ACK
+func (l *Link) Sent(token uint16, typePacket byte) {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	if l.pending == nil {
+		l.pending = make(map[uint16]datagram)
+	}
+
+	l.pending[token] = datagram{
+		Type: typePacket,
+		Sent: time.Now(),
+	}
+
+}
+
+// Cancel forgets a datagram that could not be sent
+func (l *Link) Cancel(token uint16) {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	delete(l.pending, token)
+}
+
+// Ack matches an ACK against the outstanding datagram of type typePacket, false if it is unknown
+func (l *Link) Ack(token uint16, typePacket byte) bool {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	d, ok := l.pending[token]
+	if !ok || d.Type != typePacket {
+		return false
+	}
+
+	delete(l.pending, token)
+
+	l.LastAck = time.Now()
+	l.Backoff = 0
+
+	if typePacket == pkt.TypePushData {
+		l.PushSent++
+		l.PushAcked++
+	}
+
+	return true
+}
+
+// Expire drops the datagrams not acknowledged within timeout and returns how many they are
+func (l *Link) Expire(timeout time.Duration) int {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	expired := 0
+	for token, d := range l.pending {
+
+		if time.Since(d.Sent) < timeout {
+			continue
+		}
+
+		if d.Type == pkt.TypePushData {
+			l.PushSent++
+		}
+
+		delete(l.pending, token)
+		expired++
+
+	}
+
+	return expired
+}
+
+// ACKR is the percentage of upstream datagrams that were acknowledged
+func (l *Link) ACKR() float64 {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	if l.PushSent == 0 {
+		return 0
+	}
+
+	return float64(int(float64(l.PushAcked)/float64(l.PushSent)*1000)) / 10
+}
+
+// IntervalACKR is the ACKR since its last call
+func (l *Link) IntervalACKR() float64 {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	sent := l.PushSent - l.lastPushSent
+	acked := l.PushAcked - l.lastPushAcked
+
+	l.lastPushSent = l.PushSent
+	l.lastPushAcked = l.PushAcked
+
+	if sent == 0 {
+		return 0
+	}
+
+	return float64(int(float64(acked)/float64(sent)*1000)) / 10
+}
+
+// SetState changes the state of the link and reports if it is changed
+func (l *Link) SetState(state int) bool {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	if l.State == state {
+		return false
+	}
+
+	l.State = state
+
+	return true
+}
+
+func (l *Link) GetState() int {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	return l.State
+}
+
+// IsSilent reports if nothing was acknowledged in the last period
+func (l *Link) IsSilent(since time.Time, period time.Duration) bool {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	last := l.LastAck
+	if last.Before(since) {
+		last = since
+	}
+
+	return time.Since(last) > period
+}
+
+// NextBackoff returns the delay before the next reconnection, doubling it up to max
+func (l *Link) NextBackoff(min time.Duration, max time.Duration) time.Duration {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	if l.Backoff < min {
+		l.Backoff = min
+	} else {
+		l.Backoff *= 2
+		if l.Backoff > max {
+			l.Backoff = max
+		}
+	}
+
+	l.Reconnections++
+
+	return l.Backoff
+}
+
+func LinkStateToString(state int) string {
+
+	switch state {
+	case LinkDisconnected:
+		return "disconnected"
+	case LinkConnecting:
+		return "connecting"
+	case LinkConnected:
+		return "connected"
+	case LinkSilent:
+		return "silent"
+	}
+
+	return ""
+}
@@ -3,28 +3,13 @@ package gateway
 import (
 	"errors"
 	"fmt"
+	"net"
 	"time"
 
+	f "github.com/arslab/lwnsimulator/simulator/components/forwarder"
+	"github.com/arslab/lwnsimulator/simulator/components/gateway/models"
 	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
-	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
 	"github.com/arslab/lwnsimulator/simulator/util"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-)
-
-var (
-	pushAckCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gateway_push_ack_total",
-		Help: "The total number of gateway PUSH ACK",
-	})
-	pullAckCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gateway_pull_ack_total",
-		Help: "The total number of gateway PULL ACK",
-	})
-	pullRespCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gateway_pull_resp_total",
-		Help: "The total number of gateway PULL RESP",
-	})
 )
 
 func (g *Gateway) Receiver() {
@@ -44,28 +29,22 @@ func (g *Gateway) Receiver() {
 
 		}
 
-		for g.Info.Connection == nil {
-
-			if !g.CanExecute() {
-
-				g.Print("Turn OFF", nil, util.PrintBoth)
-				return
-
-			}
-
-			g.Info.Connection, err = udp.ConnectTo(*g.Info.BridgeAddress) //stabilish new connection
-			if err != nil {
-
-				msg := fmt.Sprintf("Unable Connect to %v", g.Info.BridgeAddress)
-				g.Print("", errors.New(msg), util.PrintBoth)
+		connection := g.conn()
+		if connection == nil {
 
-				continue
+			g.reconnect() //stabilish new connection
+			continue
 
-			}
+		}
 
+		deadline := time.Now().Add(AckTimeout)
+		if next := g.Backhaul.Deliver(); !next.IsZero() && next.Before(deadline) {
+			deadline = next //wakes up to deliver the datagrams held back by the impairment
 		}
 
-		n, _, err = g.Info.Connection.ReadFromUDP(ReceiveBuffer)
+		connection.SetReadDeadline(deadline)
+
+		n, err = connection.Read(ReceiveBuffer)
 
 		if !g.CanExecute() {
 			g.Print("Turn OFF", nil, util.PrintBoth)
@@ -74,81 +53,194 @@ func (g *Gateway) Receiver() {
 
 		if err != nil {
 
-			msg := fmt.Sprintf("No connection with %v, it may be off", *g.Info.BridgeAddress)
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+
+				if g.checkLink() {
+
+					msg := fmt.Sprintf("No ACK from %v, it may be off", g.remoteAddress())
+					g.Print("", errors.New(msg), util.PrintBoth)
+
+					g.disconnect(models.LinkSilent)
+
+				}
+
+				continue
+			}
+
+			msg := fmt.Sprintf("No connection with %v, it may be off", g.remoteAddress())
 			g.Print("", errors.New(msg), util.PrintBoth)
 
+			g.disconnect(models.LinkDisconnected)
+
 			continue
 
 		}
 
-		receivedPack := ReceiveBuffer[:n]
+		arrived := time.Now()
 
-		g.Stat.DWNb++
+		g.Resources.Capture.WriteUDP(arrived, connection.RemoteAddr(), connection.LocalAddr(), ReceiveBuffer[:n])
 
-		err = pkt.ParseReceivePacket(receivedPack)
-		if err != nil {
-			g.Print("Packet not supported", nil, util.PrintBoth)
-			continue
+		g.Backhaul.Receive(ReceiveBuffer[:n], func(receivedPack []byte) {
+			g.handlePacket(receivedPack, arrived)
+		})
+
+		if g.checkLink() {
+			g.disconnect(models.LinkSilent)
 		}
 
-		time.Sleep(time.Second) //sync le print
+	}
 
-		msg := fmt.Sprintf("%v received", pkt.PacketToString(receivedPack[3]))
-		g.Print(msg, nil, util.PrintBoth)
+}
 
-		typepkt := pkt.GetTypePacket(receivedPack)
-		switch *typepkt {
+// handlePacket handles a datagram arrived on the connection at arrived
+func (g *Gateway) handlePacket(receivedPack []byte, arrived time.Time) {
 
-		case pkt.TypePushAck:
-			g.Stat.ACKR++
-			pushAckCounter.Inc()
+	if !g.CanExecute() {
+		return
+	}
 
-		case pkt.TypePullAck:
-			pullAckCounter.Inc()
-			break
+	err := pkt.ParseReceivePacket(receivedPack)
+	if err != nil {
+		g.Print("Packet not supported", nil, util.PrintBoth)
+		return
+	}
 
-		case pkt.TypePullResp:
+	msg := fmt.Sprintf("%v received", pkt.PacketToString(receivedPack[3]))
+	g.Print(msg, nil, util.PrintBoth)
 
-			phy, freq, err := pkt.GetInfoPullResp(receivedPack)
-			if err != nil {
-				g.Print("", err, util.PrintBoth)
-				continue
-			}
+	typepkt := pkt.GetTypePacket(receivedPack)
+	switch *typepkt {
 
-			g.Forwarder.Downlink(phy, *freq, g.Info.MACAddress)
+	case pkt.TypePushAck:
+
+		if !g.acknowledged(receivedPack, pkt.TypePushData) {
+			g.Print("PUSH ACK with unknown token", nil, util.PrintOnlyConsole)
+			return
+		}
 
-			g.Stat.RXFW++
+		g.datagramReceived(pkt.TypePushAck)
 
-			pullRespCounter.Inc()
+	case pkt.TypePullAck:
 
-			//TX ACK
-			packet, err := pkt.CreatePacket(pkt.TypeTxAck, g.Info.MACAddress, pkt.Stat{}, nil, pkt.GetTokenFromPullResp(receivedPack))
-			if err != nil {
-				g.Print("", err, util.PrintBoth)
-			}
+		if !g.acknowledged(receivedPack, pkt.TypePullData) {
+			g.Print("PULL ACK with unknown token", nil, util.PrintOnlyConsole)
+			return
+		}
 
-			_, err = udp.SendDataUDP(g.Info.Connection, packet)
+		g.datagramReceived(pkt.TypePullAck)
 
-			if !g.CanExecute() {
-				g.Print("Turn OFF", nil, util.PrintBoth)
-				return
-			}
+	case pkt.TypePullResp:
 
-			if err != nil {
-				msg := fmt.Sprintf("No connection with %v, it may be off", *g.Info.BridgeAddress)
-				g.Print("", errors.New(msg), util.PrintBoth)
-			} else {
+		g.Stat.DWNb++
+		g.datagramReceived(pkt.TypePullResp)
 
-				g.Stat.TXNb++
-				g.Print("TX ACK sent", nil, util.PrintBoth)
+		g.scheduleDownlink(receivedPack, arrived)
 
-			}
+	default:
+		g.Print("Packet not supported", nil, util.PrintBoth)
+
+	}
+
+}
+
+// scheduleDownlink queues the txpk in the JIT queue, answers with the TX ACK and
+// delivers the downlink when its transmission starts
+func (g *Gateway) scheduleDownlink(pullResp []byte, arrived time.Time) {
+
+	txpk, err := pkt.GetTXPKPullResp(pullResp)
+	if err != nil {
+		g.Print("", err, util.PrintBoth)
+		return
+	}
+
+	phy, freq, err := pkt.GetInfoPullResp(pullResp)
+	if err != nil {
+		g.Print("", err, util.PrintBoth)
+		return
+	}
+
+	span := g.traceDownlink(txpk, arrived)
+
+	Error := pkt.NONE
+	start := g.txStart(txpk)
+
+	airtime, err := pkt.TimeOnAir(txpk.Modu, txpk.DatR, txpk.CodR, int(txpk.Size), int(txpk.Prea), !txpk.NCRC)
+	if err != nil {
+		g.Print("", err, util.PrintBoth)
+		Error = pkt.TX_FREQ
+	} else {
+		start, Error = g.JIT.Enqueue(start, airtime, txpk.Imme)
+	}
+
+	//TX ACK
+	packet, err := pkt.CreateTXPacket(g.Info.MACAddress, pkt.GetTokenFromPullResp(pullResp), Error)
+	if err != nil {
+		g.Print("", err, util.PrintBoth)
+		return
+	}
+
+	_, err = g.Backhaul.Send(g.conn(), packet)
+
+	if !g.CanExecute() {
+		span.SetError("Gateway turned off")
+		span.End()
+		return
+	}
+
+	if err != nil {
+		msg := fmt.Sprintf("No connection with %v, it may be off", g.remoteAddress())
+		g.Print("", errors.New(msg), util.PrintBoth)
+	} else {
+		g.Print("TX ACK sent", nil, util.PrintBoth)
+	}
+
+	span.SetAttribute("tx_ack.error", Error)
+
+	if Error != pkt.NONE {
+		g.Print("Downlink rejected: "+Error, nil, util.PrintBoth)
+
+		span.SetError("Downlink rejected: " + Error)
+		span.End()
+
+		return
+	}
 
-		default:
-			g.Print("Packet not supported", nil, util.PrintBoth)
+	time.AfterFunc(time.Until(start), func() {
 
+		defer span.End() //transmitted
+
+		if !g.CanExecute() {
+			span.SetError("Gateway turned off")
+			return
 		}
 
+		g.Resources.Capture.WriteLoRa(time.Now(), *freq, txpk.DatR, int16(txpk.Powe), 0, txpk.Data)
+
+		g.Forwarder.Downlink(phy, *freq, g.Info.MACAddress, *txpk)
+		g.Stat.TXNb++
+
+	})
+
+}
+
+// txStart is the time at which the txpk must be transmitted
+func (g *Gateway) txStart(txpk *pkt.TXPK) time.Time {
+
+	now := time.Now()
+
+	switch {
+
+	case txpk.Imme:
+		return now
+
+	case txpk.Tmst != nil:
+		return pkt.TimeFromTmst(*txpk.Tmst, now)
+
+	case txpk.Tmms != nil:
+		epoch, _ := time.Parse(time.RFC3339, "1980-01-06T00:00:00Z")
+		return epoch.Add(time.Duration(*txpk.Tmms-f.GPSOffset) * time.Millisecond)
+
 	}
 
+	return now
 }
@@ -6,55 +6,48 @@ import (
 	"time"
 
 	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
-	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-
 	"github.com/arslab/lwnsimulator/simulator/util"
 )
 
-var (
-	pushDataCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gateway_data_sent_total",
-		Help: "The total number of gateway PUSH DATA",
-	})
-	pullDataCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gateway_pull_data_total",
-		Help: "The total number of gateway PULL DATA",
-	})
-)
-
 func (g *Gateway) SenderVirtual() {
 
 	defer g.Print("Sender Turn OFF", nil, util.PrintOnlyConsole)
 
 	go g.KeepAlive()
+	go g.StatReporter()
 
 	for {
 
-		rxpk := g.BufferUplink.Pop() //wait uplink
+		rxpks := g.nextBatch() //wait uplinks
 
 		if !g.CanExecute() {
 			return
 		}
 
-		g.Stat.RXNb++
-		g.Stat.RXOK++
-
-		packet, err := g.createPacket(rxpk)
+		packet, err := g.createPacket(rxpks)
 		if err != nil {
 			g.Print("", err, util.PrintBoth)
+			continue
 		}
 
-		_, err = udp.SendDataUDP(g.Info.Connection, packet)
+		hops := g.traceBatch(rxpks)
+
+		g.sent(packet)
+
+		_, err = g.Backhaul.SendFunc(g.conn(), packet, g.traceUpstream(hops))
 		if err != nil {
 
-			msg := fmt.Sprintf("Unable to send data to %v, it may be off", *g.Info.BridgeAddress)
+			g.unsent(packet)
+
+			msg := fmt.Sprintf("Unable to send data to %v, it may be off", g.remoteAddress())
 			g.Print("", errors.New(msg), util.PrintBoth)
 
 		} else {
-			g.Print("PUSH DATA send", nil, util.PrintBoth)
-			pushDataCounter.Inc()
+			g.Stat.RXFW += uint32(len(rxpks))
+
+			msg := fmt.Sprintf("PUSH DATA send (%v rxpk)", len(rxpks))
+			g.Print(msg, nil, util.PrintBoth)
+			g.datagramSent(pkt.TypePushData)
 		}
 
 	}
@@ -65,71 +58,94 @@ func (g *Gateway) SenderReal() {
 
 	defer g.Print("Sender Turn OFF", nil, util.PrintOnlyConsole)
 
+	go g.StatReporter()
+
 	for {
 
-		rxpk := g.BufferUplink.Pop() //wait uplink
+		rxpks := g.nextBatch() //wait uplinks
 
 		if !g.CanExecute() {
 			return
 		}
 
-		g.Stat.RXNb++
-		g.Stat.RXOK++
-
-		packet, err := g.createPacket(rxpk)
+		packet, err := g.createPacket(rxpks)
 		if err != nil {
 			g.Print("", err, util.PrintBoth)
+			continue
 		}
 
-		_, err = udp.SendDataUDP(g.Info.Connection, packet)
+		hops := g.traceBatch(rxpks)
+
+		g.sent(packet)
+
+		_, err = g.Backhaul.SendFunc(g.conn(), packet, g.traceUpstream(hops))
 		if err != nil {
 
-			msg := fmt.Sprintf("Unable to send data to %v, it may be off", *g.Info.BridgeAddress)
+			g.unsent(packet)
+
+			msg := fmt.Sprintf("Unable to send data to %v, it may be off", g.remoteAddress())
 			g.Print("", errors.New(msg), util.PrintBoth)
 
 		} else {
+			g.Stat.RXFW += uint32(len(rxpks))
+
 			msg := fmt.Sprintf("Forward PUSH DATA to %v:%v", g.Info.AddrIP, g.Info.Port)
 			g.Print(msg, nil, util.PrintBoth)
 
-			pushDataCounter.Inc()
+			g.datagramSent(pkt.TypePushData)
 		}
 
 	}
 }
 
-func (g *Gateway) sendPullData() error {
+// nextBatch waits an uplink and then the batch window, to send together the uplinks received meanwhile
+func (g *Gateway) nextBatch() []pkt.RXPK {
+
+	rxpk := g.BufferUplink.Pop()
 
 	if !g.CanExecute() {
 		return nil
 	}
 
-	pulldata, _ := pkt.CreatePacket(pkt.TypePullData, g.Info.MACAddress, pkt.Stat{}, nil, 0)
+	if g.Info.BatchWindow > 0 {
 
-	_, err := udp.SendDataUDP(g.Info.Connection, pulldata)
+		select {
+		case <-time.After(g.Info.BatchWindow):
+		case <-g.Exit:
+			return nil
+		}
 
-	return err
+	}
+
+	rxpks := append([]pkt.RXPK{rxpk}, g.BufferUplink.PopAll(MaxRXPKBatch-1)...)
+
+	g.Stat.RXNb += uint32(len(rxpks))
+	g.Stat.RXOK += uint32(len(rxpks))
+
+	return rxpks
 }
 
-func (g *Gateway) createPacket(info pkt.RXPK) ([]byte, error) {
-
-	stat := pkt.Stat{
-		Time: pkt.GetTime(),
-		Lati: g.Info.Location.Latitude,
-		Long: g.Info.Location.Longitude,
-		Alti: g.Info.Location.Altitude,
-		RXNb: g.Stat.RXNb,
-		RXOK: g.Stat.RXOK,
-		RXFW: g.Stat.RXFW,
-		ACKR: g.Stat.ACKR,
-		DWNb: g.Stat.DWNb,
-		TXNb: g.Stat.TXNb,
+func (g *Gateway) sendPullData() error {
+
+	connection := g.conn()
+	if !g.CanExecute() || connection == nil {
+		return nil
 	}
 
-	rxpks := []pkt.RXPK{
-		info,
+	pulldata, _ := pkt.CreatePacket(pkt.TypePullData, g.Info.MACAddress, nil, nil, 0)
+
+	g.sent(pulldata)
+
+	_, err := g.Backhaul.Send(connection, pulldata)
+	if err != nil {
+		g.unsent(pulldata)
 	}
 
-	return pkt.CreatePacket(pkt.TypePushData, g.Info.MACAddress, stat, rxpks, 0)
+	return err
+}
+
+func (g *Gateway) createPacket(rxpks []pkt.RXPK) ([]byte, error) {
+	return pkt.CreatePacket(pkt.TypePushData, g.Info.MACAddress, nil, rxpks, 0)
 }
 
 func (g *Gateway) KeepAlive() {
@@ -148,7 +164,7 @@ func (g *Gateway) KeepAlive() {
 				g.Print("", err, util.PrintBoth)
 			} else {
 				g.Print("PULL DATA send", nil, util.PrintBoth)
-				pullDataCounter.Inc()
+				g.datagramSent(pkt.TypePullData)
 			}
 
 		}
@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"time"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/util"
+)
+
+const (
+	// DefaultStatInterval is the stat interval of the Semtech packet forwarder
+	DefaultStatInterval = 30 * time.Second
+
+	// MaxRXPKBatch is the maximum number of rxpk in a PUSH DATA
+	MaxRXPKBatch = 8
+)
+
+// StatReporter sends a PUSH DATA with only the gateway's status every stat interval
+func (g *Gateway) StatReporter() {
+
+	interval := g.Info.StatInterval
+	if interval <= 0 {
+		interval = DefaultStatInterval
+	}
+
+	tickerStat := time.NewTicker(interval)
+	defer tickerStat.Stop()
+
+	for {
+
+		select {
+		case <-tickerStat.C:
+		case <-g.Exit:
+			return
+		}
+
+		if !g.CanExecute() {
+			return
+		}
+
+		err := g.sendStat()
+		if err != nil {
+			g.Print("", err, util.PrintBoth)
+		} else {
+			g.Print("PUSH DATA (stat) send", nil, util.PrintOnlyConsole)
+			g.datagramSent(pkt.TypePushData)
+		}
+
+	}
+
+}
+
+func (g *Gateway) sendStat() error {
+
+	stat := g.createStat()
+
+	packet, err := pkt.CreatePacket(pkt.TypePushData, g.Info.MACAddress, &stat, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	g.sent(packet)
+
+	_, err = g.Backhaul.Send(g.conn(), packet)
+	if err != nil {
+		g.unsent(packet)
+	}
+
+	return err
+}
+
+// createStat returns the counters of the last stat interval, as the Semtech packet forwarder does
+func (g *Gateway) createStat() pkt.Stat {
+
+	current := g.Stat
+	last := g.lastStat
+	g.lastStat = current
+
+	return pkt.Stat{
+		Time: pkt.GetTime(),
+		Lati: g.Info.Location.Latitude,
+		Long: g.Info.Location.Longitude,
+		Alti: g.Info.Location.Altitude,
+		RXNb: current.RXNb - last.RXNb,
+		RXOK: current.RXOK - last.RXOK,
+		RXFW: current.RXFW - last.RXFW,
+		ACKR: g.Link.IntervalACKR(),
+		DWNb: current.DWNb - last.DWNb,
+		TXNb: current.TXNb - last.TXNb,
+	}
+}
@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"time"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/resources/tracing"
+)
+
+// traceBatch traces the wait in the gateway of the traced uplinks of a PUSH DATA
+func (g *Gateway) traceBatch(rxpks []pkt.RXPK) []*tracing.Hop {
+
+	var hops []*tracing.Hop
+	now := time.Now()
+
+	for _, rxpk := range rxpks {
+
+		hop := g.Resources.Tracer.Hop(g.Info.MACAddress, rxpk.Tmst)
+		if hop == nil {
+			continue
+		}
+
+		span := g.Resources.Tracer.StartSpanAt("gateway.batch", hop.Trace, hop.Received)
+		g.setSpanGateway(span)
+		span.SetAttribute("push_data.rxpk", len(rxpks))
+		span.EndAt(now)
+
+		hops = append(hops, hop)
+	}
+
+	return hops
+}
+
+// traceUpstream returns the callback of the backhaul that traces the PUSH DATA of hops
+func (g *Gateway) traceUpstream(hops []*tracing.Hop) func(time.Time) {
+
+	if len(hops) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+
+	return func(written time.Time) {
+
+		for _, hop := range hops {
+
+			span := g.Resources.Tracer.StartSpanAt("backhaul.upstream", hop.Trace, start)
+			g.setSpanGateway(span)
+
+			if written.IsZero() {
+				span.SetError("PUSH DATA lost on the backhaul")
+				span.End()
+				continue
+			}
+
+			hop.SetSent(written)
+			span.EndAt(written)
+		}
+
+	}
+}
+
+// traceDownlink traces the network server and the backhaul of a PULL RESP arrived at
+// arrived, and returns the span of its transmission if it answers a traced uplink
+func (g *Gateway) traceDownlink(txpk *pkt.TXPK, arrived time.Time) *tracing.Span {
+
+	if txpk.Tmst == nil {
+		return nil //immediate or GPS time, not an answer to an uplink
+	}
+
+	hop := g.Resources.Tracer.HopOfDownlink(g.Info.MACAddress, *txpk.Tmst)
+	if hop == nil {
+		return nil
+	}
+
+	if sent := hop.Sent(); !sent.IsZero() {
+		ns := g.Resources.Tracer.StartSpanAt("network_server", hop.Trace, sent)
+		g.setSpanGateway(ns)
+		ns.EndAt(arrived) //with the network latency of the backhaul
+	}
+
+	downstream := g.Resources.Tracer.StartSpanAt("backhaul.downstream", hop.Trace, arrived)
+	g.setSpanGateway(downstream)
+	downstream.End()
+
+	span := g.Resources.Tracer.StartSpan("gateway.downlink", hop.Trace)
+	g.setSpanGateway(span)
+	span.SetAttribute("lora.frequency", txpk.Freq)
+	span.SetAttribute("lora.data_rate", txpk.DatR)
+
+	return span
+}
+
+func (g *Gateway) setSpanGateway(span *tracing.Span) {
+	span.SetAttribute("gateway.mac", g.Info.MACAddress.String())
+	span.SetAttribute("gateway.name", g.Info.Name)
+}
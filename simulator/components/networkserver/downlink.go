@@ -0,0 +1,179 @@
+package networkserver
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/brocaar/lorawan"
+)
+
+const (
+	// TXPower of the downlinks, in dBm
+	TXPower = 14
+
+	// FSKDeviation of the FSK downlinks, in Hz
+	FSKDeviation = 25000
+)
+
+// downlink builds the next frame of the session: the ACK, the MAC commands and the first
+// queued downlink. The commands that don't fit wait for the next one. The mutex must be held
+func (s *Server) downlink(ss *session, ack bool, commands [][]byte) (lorawan.PHYPayload, error) {
+
+	commands = append(commands, ss.macQueue...)
+	ss.macQueue = nil
+
+	var fopts []lorawan.Payload
+	size := 0
+
+	for _, c := range commands {
+
+		if size+len(c) > MaxFOptsLen {
+			ss.macQueue = append(ss.macQueue, c)
+			continue
+		}
+
+		fopts = append(fopts, &lorawan.DataPayload{Bytes: c})
+		size += len(c)
+	}
+
+	macPL := lorawan.MACPayload{
+		FHDR: lorawan.FHDR{
+			DevAddr: ss.DevAddr,
+			FCtrl: lorawan.FCtrl{
+				ACK: ack,
+			},
+			FCnt:  ss.FCntDown,
+			FOpts: fopts,
+		},
+	}
+
+	mtype := lorawan.UnconfirmedDataDown
+	key := ss.appSKey
+
+	if len(ss.queue) > 0 {
+
+		downlink := ss.queue[0]
+		ss.queue = ss.queue[1:]
+
+		if downlink.Confirmed {
+			mtype = lorawan.ConfirmedDataDown
+		}
+
+		fport := downlink.FPort
+		macPL.FPort = &fport
+		macPL.FRMPayload = []lorawan.Payload{&lorawan.DataPayload{Bytes: downlink.Payload}}
+
+	}
+
+	macPL.FHDR.FCtrl.FPending = len(ss.queue) > 0 || len(ss.macQueue) > 0
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{
+			MType: mtype,
+			Major: lorawan.LoRaWANR1,
+		},
+		MACPayload: &macPL,
+	}
+
+	if err := phy.EncryptFRMPayload(key); err != nil {
+		return phy, err
+	}
+
+	if err := phy.SetDownlinkDataMIC(lorawan.LoRaWAN1_0, 0, ss.nwkSKey); err != nil {
+		return phy, err
+	}
+
+	ss.FCntDown++
+
+	return phy, nil
+}
+
+// transmit sends the frame in the first receive window of the device, through the
+// gateway of the reception, delay after the end of the uplink
+func (s *Server) transmit(r reception, device Device, phy lorawan.PHYPayload, delay time.Duration) error {
+
+	region := rp.GetRegionalParameters(device.Region)
+	if region == nil {
+		return errors.New("Unknown region")
+	}
+
+	region.Setup()
+
+	frequency, dr, err := rx1(region, device, r.rxpk)
+	if err != nil {
+		return err
+	}
+
+	data, err := phy.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	modu, datr := region.GetDataRate(dr)
+	tmst := r.rxpk.Tmst + uint32(delay/time.Microsecond)
+
+	txpk := pkt.TXPK{
+		Tmst: &tmst,
+		Powe: TXPower,
+		Freq: float64(frequency) / 1000000.0,
+		Modu: modu,
+		DatR: datr,
+		IPol: true,
+		Size: uint16(len(data)),
+		Data: data,
+	}
+
+	if modu == "FSK" {
+		txpk.FDev = FSKDeviation
+	} else {
+		txpk.CodR = region.GetCodR(dr)
+	}
+
+	return s.send(r.gateway, txpk)
+}
+
+// rx1 returns the frequency in Hz and the datarate of the first receive window
+func rx1(region rp.Region, device Device, rxpk pkt.RXPK) (uint32, uint8, error) {
+
+	frequency := uint32(math.Round(rxpk.Frequency * 1000000.0))
+
+	dr, ok := dataRate(region, rxpk)
+	if !ok {
+		return 0, 0, errors.New("Datarate " + rxpk.DatR + " not in the region")
+	}
+
+	channels := region.GetChannels()
+
+	index := -1
+	for i, c := range channels {
+		if c.FrequencyUplink == frequency {
+			index = i
+			break
+		}
+	}
+
+	drRX1, indexRX1 := region.SetupRX1(dr, device.RX1DROffset, index, lorawan.DwellTimeNoLimit)
+
+	if index < 0 || indexRX1 < 0 || indexRX1 >= len(channels) || channels[indexRX1].FrequencyDownlink == 0 {
+		return frequency, drRX1, nil //a channel added by the device, eg. with the CFList
+	}
+
+	return channels[indexRX1].FrequencyDownlink, drRX1, nil
+}
+
+// dataRate is the index of the datarate of the rxpk in the region
+func dataRate(region rp.Region, rxpk pkt.RXPK) (uint8, bool) {
+
+	for dr := 0; dr < 16; dr++ {
+
+		modu, datr := region.GetDataRate(uint8(dr))
+		if datr == rxpk.DatR && (rxpk.Modu == "" || modu == rxpk.Modu) {
+			return uint8(dr), true
+		}
+	}
+
+	return 0, false
+}
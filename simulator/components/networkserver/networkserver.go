@@ -0,0 +1,344 @@
+package networkserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/brocaar/lorawan"
+)
+
+const (
+	// DefaultDeduplication is the time to wait for the copies of an uplink received by several gateways
+	DefaultDeduplication = 200 * time.Millisecond
+
+	// InProcess is the bridge address of the gateways connected with Dial
+	InProcess = "in-process:0"
+
+	// replies queued for an in-process gateway, the next ones are dropped as on UDP
+	pipeQueueSize = 64
+)
+
+// Server is a minimal network server and join server. It speaks the Semtech packet forwarder
+// protocol over UDP, or in-process with Dial, joins the OTAA devices, checks the MIC and the
+// counter of the uplinks and answers them with the ACKs, the queued downlinks and the MAC commands
+type Server struct {
+	Mutex         sync.Mutex
+	Device        func(DevEUI lorawan.EUI64) (Device, bool) // keys of a device, eg. of a simulator
+	ABPDevices    func(DevAddr lorawan.DevAddr) []Device    // the ABP devices with DevAddr
+	Print         func(content string, err error)           // log.Println if nil
+	netID         lorawan.NetID
+	deduplication time.Duration
+	script        []scriptedCommand
+	sessions      map[lorawan.EUI64]*session
+	gateways      map[lorawan.EUI64]func([]byte) error // where the PULL RESP are sent
+	pending       map[string]*uplink                   // being deduplicated, by PHYPayload
+	conn          net.PacketConn
+}
+
+type scriptedCommand struct {
+	devEUI *lorawan.EUI64 // all the devices if nil
+	uplink int
+	repeat int
+	cid    lorawan.CID
+	data   []byte // CID and payload
+}
+
+// New returns a server that doesn't listen yet
+func New(config models.NetworkServer) (*Server, error) {
+
+	s := Server{
+		deduplication: DefaultDeduplication,
+		sessions:      make(map[lorawan.EUI64]*session),
+		gateways:      make(map[lorawan.EUI64]func([]byte) error),
+		pending:       make(map[string]*uplink),
+	}
+
+	if config.NetID != "" {
+		if err := s.netID.UnmarshalText([]byte(config.NetID)); err != nil {
+			return nil, fmt.Errorf("netID: %w", err)
+		}
+	}
+
+	if config.Deduplication > 0 {
+		s.deduplication = time.Duration(config.Deduplication) * time.Millisecond
+	}
+
+	for i, c := range config.MACCommands {
+
+		if c.Uplink < 1 || c.Repeat < 0 {
+			return nil, fmt.Errorf("macCommands[%v]: uplink must be at least 1 and repeat not negative", i)
+		}
+
+		payload, err := hex.DecodeString(c.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("macCommands[%v].payload: %w", i, err)
+		}
+
+		command := scriptedCommand{
+			uplink: c.Uplink,
+			repeat: c.Repeat,
+			cid:    lorawan.CID(c.CID),
+			data:   append([]byte{c.CID}, payload...),
+		}
+
+		if c.DevEUI != "" {
+
+			var devEUI lorawan.EUI64
+			if err := devEUI.UnmarshalText([]byte(c.DevEUI)); err != nil {
+				return nil, fmt.Errorf("macCommands[%v].devEUI: %w", i, err)
+			}
+
+			command.devEUI = &devEUI
+		}
+
+		if len(command.data) > MaxFOptsLen {
+			return nil, fmt.Errorf("macCommands[%v]: longer than %v bytes", i, MaxFOptsLen)
+		}
+
+		s.script = append(s.script, command)
+	}
+
+	return &s, nil
+}
+
+// Listen serves the gateways on the UDP address, eg. :1700
+func (s *Server) Listen(address string) error {
+
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+
+	s.Mutex.Lock()
+	s.conn = conn
+	s.Mutex.Unlock()
+
+	go func() {
+
+		buffer := make([]byte, 65535)
+
+		for {
+
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return //closed
+			}
+
+			data := append([]byte{}, buffer[:n]...)
+
+			s.handle(data, func(reply []byte) error {
+				_, err := conn.WriteTo(reply, addr)
+				return err
+			})
+
+		}
+
+	}()
+
+	return nil
+}
+
+// Address is where the gateways of the host reach the server, empty if it doesn't listen
+func (s *Server) Address() string {
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.conn == nil {
+		return ""
+	}
+
+	addr, ok := s.conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return s.conn.LocalAddr().String()
+	}
+
+	if addr.IP.IsUnspecified() {
+		return net.JoinHostPort("127.0.0.1", strconv.Itoa(addr.Port))
+	}
+
+	return addr.String()
+}
+
+// Close stops listening, the in-process gateways stay connected
+func (s *Server) Close() error {
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
+
+// Dial connects a gateway in-process, it is a udp.Dialer that ignores the address
+func (s *Server) Dial(address string) (net.Conn, error) {
+
+	gateway, server := net.Pipe()
+
+	replies := make(chan []byte, pipeQueueSize)
+
+	go func() { //a reply must not wait for the gateway to read it
+		for reply := range replies {
+			if _, err := server.Write(reply); err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+
+		defer close(replies)
+
+		buffer := make([]byte, 65535)
+
+		for {
+
+			n, err := server.Read(buffer)
+			if err != nil {
+				server.Close()
+				return
+			}
+
+			data := append([]byte{}, buffer[:n]...)
+
+			s.handle(data, func(reply []byte) error {
+
+				select {
+				case replies <- reply:
+					return nil
+				default:
+					return errors.New("Queue of the gateway full")
+				}
+
+			})
+
+		}
+
+	}()
+
+	return gateway, nil
+}
+
+// handle answers a datagram of a gateway with reply
+func (s *Server) handle(data []byte, reply func([]byte) error) {
+
+	if len(data) < pkt.MinLenPushData || data[0] != pkt.PVersion {
+		return
+	}
+
+	var mac lorawan.EUI64
+	if len(data) >= pkt.SizeHeader {
+		copy(mac[:], data[4:pkt.SizeHeader])
+	}
+
+	token := pkt.GetToken(data)
+
+	switch data[3] {
+
+	case pkt.TypePushData:
+
+		if len(data) < pkt.SizeHeader {
+			return
+		}
+
+		reply(pkt.GetHeader(pkt.TypePushAck, mac, token)[:pkt.MinLenPushAck])
+
+		var payload pkt.PushDataPayload
+		if err := json.Unmarshal(data[pkt.SizeHeader:], &payload); err != nil {
+			s.print("", fmt.Errorf("PUSH DATA of %v: %w", mac, err))
+			return
+		}
+
+		for _, rxpk := range payload.RXPK {
+			if rxpk.Stat != -1 { //CRC ok or none
+				s.receive(mac, rxpk)
+			}
+		}
+
+	case pkt.TypePullData:
+
+		if len(data) < pkt.MinLenPullData {
+			return
+		}
+
+		s.Mutex.Lock()
+		s.gateways[mac] = reply
+		s.Mutex.Unlock()
+
+		reply(pkt.GetHeader(pkt.TypePullAck, mac, token)[:pkt.MinLenPullAck])
+
+	case pkt.TypeTxAck:
+
+		var payload pkt.TXACKPayload
+		if len(data) > pkt.SizeHeader {
+			json.Unmarshal(data[pkt.SizeHeader:], &payload)
+		}
+
+		if payload.TXPKACK.Error != "" && payload.TXPKACK.Error != pkt.NONE {
+			s.print("", fmt.Errorf("Downlink rejected by %v: %v", mac, payload.TXPKACK.Error))
+		}
+
+	}
+
+}
+
+// send transmits the txpk through the gateway mac
+func (s *Server) send(mac lorawan.EUI64, txpk pkt.TXPK) error {
+
+	s.Mutex.Lock()
+	reply, ok := s.gateways[mac]
+	s.Mutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("No PULL DATA from %v", mac)
+	}
+
+	payload, err := json.Marshal(pkt.PullRespPayload{TXPK: txpk})
+	if err != nil {
+		return err
+	}
+
+	header := pkt.GetHeader(pkt.TypePullResp, mac, 0)[:4] //no MAC address in a PULL RESP
+
+	return reply(append(header, payload...))
+}
+
+func (s *Server) print(content string, err error) {
+
+	if s.Print != nil {
+		s.Print(content, err)
+		return
+	}
+
+	if err != nil {
+		log.Println("[NS] [ERROR]:", err)
+		return
+	}
+
+	log.Println("[NS]:", content)
+}
+
+// device returns the keys of the device DevEUI
+func (s *Server) device(DevEUI lorawan.EUI64) (Device, bool) {
+
+	if s.Device == nil {
+		return Device{}, false
+	}
+
+	return s.Device(DevEUI)
+}
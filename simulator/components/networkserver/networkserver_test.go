@@ -0,0 +1,346 @@
+package networkserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/brocaar/lorawan"
+)
+
+var (
+	testGateway = lorawan.EUI64{1, 1, 1, 1, 1, 1, 1, 1}
+	testDevEUI  = lorawan.EUI64{1, 2, 3, 4, 5, 6, 7, 8}
+	testDevAddr = lorawan.DevAddr{0x26, 1, 2, 3}
+	testNwkSKey = lorawan.AES128Key{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	testAppSKey = lorawan.AES128Key{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	testAppKey  = lorawan.AES128Key{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+)
+
+var abpDevice = Device{
+	DevEUI:  testDevEUI,
+	DevAddr: testDevAddr,
+	NwkSKey: testNwkSKey,
+	AppSKey: testAppSKey,
+	Region:  rp.Code_Eu868,
+}
+
+var otaaDevice = Device{
+	DevEUI:   testDevEUI,
+	OTAA:     true,
+	AppKey:   testAppKey,
+	Region:   rp.Code_Eu868,
+	RX1Delay: time.Second,
+}
+
+// newTestServer returns a server of device whose PULL RESP are sent to the channel
+func newTestServer(t *testing.T, device Device) (*Server, chan pkt.TXPK) {
+
+	t.Helper()
+
+	s, err := New(models.NetworkServer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Print = func(string, error) {}
+
+	s.Device = func(DevEUI lorawan.EUI64) (Device, bool) {
+		return device, DevEUI == device.DevEUI
+	}
+
+	s.ABPDevices = func(DevAddr lorawan.DevAddr) []Device {
+
+		if device.OTAA || DevAddr != device.DevAddr {
+			return nil
+		}
+
+		return []Device{device}
+	}
+
+	sent := make(chan pkt.TXPK, 8)
+
+	s.gateways[testGateway] = func(reply []byte) error {
+
+		var payload pkt.PullRespPayload
+		if err := json.Unmarshal(reply[4:], &payload); err != nil {
+			t.Error(err)
+		}
+
+		sent <- payload.TXPK
+		return nil
+	}
+
+	return s, sent
+}
+
+// receiveTest processes phy as received by the test gateway
+func receiveTest(s *Server, phy []byte) {
+
+	s.process(&uplink{
+		phy: phy,
+		received: []reception{{
+			gateway: testGateway,
+			rxpk: pkt.RXPK{
+				Frequency: 868.1,
+				Modu:      "LORA",
+				DatR:      "SF7BW125",
+				LSNR:      5,
+				RSSI:      -60,
+			},
+		}},
+	})
+
+}
+
+func dataUplink(t *testing.T, nwkSKey lorawan.AES128Key, fcnt uint32, confirmed bool) []byte {
+
+	t.Helper()
+
+	mtype := lorawan.UnconfirmedDataUp
+	if confirmed {
+		mtype = lorawan.ConfirmedDataUp
+	}
+
+	fport := uint8(1)
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: mtype, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.MACPayload{
+			FHDR:       lorawan.FHDR{DevAddr: testDevAddr, FCnt: fcnt}, //32 bits in the MIC, 16 in the frame
+			FPort:      &fport,
+			FRMPayload: []lorawan.Payload{&lorawan.DataPayload{Bytes: []byte{1, 2, 3}}},
+		},
+	}
+
+	if err := phy.EncryptFRMPayload(testAppSKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := phy.SetUplinkDataMIC(lorawan.LoRaWAN1_0, 0, 0, 0, nwkSKey, nwkSKey); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := phy.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func joinRequest(t *testing.T, appKey lorawan.AES128Key, devNonce lorawan.DevNonce) []byte {
+
+	t.Helper()
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: lorawan.JoinRequest, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.JoinRequestPayload{
+			DevEUI:   testDevEUI,
+			DevNonce: devNonce,
+		},
+	}
+
+	if err := phy.SetUplinkJoinMIC(appKey); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := phy.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestFullFCnt(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		last   uint32
+		fcnt16 uint32
+		want   uint32
+	}{
+		{"next", 0, 1, 1},
+		{"same", 5, 5, 5},
+		{"gap", 10, 100, 100},
+		{"lower", 0x10005, 3, 0x10003},
+		{"rollover", 0xffff, 0, 0x10000},
+		{"rollover with a gap", 0x1fff0, 2, 0x20002},
+		{"upper bits kept", 0x2fffe, 0xffff, 0x2ffff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fullFCnt(tt.last, tt.fcnt16); got != tt.want {
+				t.Errorf("fullFCnt(%#x, %#x) = %#x, want %#x", tt.last, tt.fcnt16, got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestDataUplink(t *testing.T) {
+
+	wrongKey := lorawan.AES128Key{0xff}
+
+	type frame struct {
+		fcnt uint32
+		key  lorawan.AES128Key
+	}
+
+	tests := []struct {
+		name        string
+		frames      []frame
+		wantUplinks int
+		wantFCntUp  uint32
+	}{
+		{"valid MIC", []frame{{0, testNwkSKey}}, 1, 0},
+		{"invalid MIC", []frame{{0, wrongKey}}, 0, 0},
+		{"increasing", []frame{{0, testNwkSKey}, {1, testNwkSKey}, {5, testNwkSKey}}, 3, 5},
+		{"counter already received", []frame{{3, testNwkSKey}, {2, testNwkSKey}}, 1, 3},
+		{"repetition", []frame{{3, testNwkSKey}, {3, testNwkSKey}}, 1, 3},
+		{"invalid MIC after a valid one", []frame{{1, testNwkSKey}, {2, wrongKey}}, 1, 1},
+		{"16 bits rollover", []frame{{0xffff, testNwkSKey}, {0x10000, testNwkSKey}}, 2, 0x10000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s, _ := newTestServer(t, abpDevice)
+
+			for _, f := range tt.frames {
+				receiveTest(s, dataUplink(t, f.key, f.fcnt, false))
+			}
+
+			sessions := s.Sessions()
+			if len(sessions) != 1 {
+				t.Fatalf("%v sessions, want 1", len(sessions))
+			}
+
+			if sessions[0].Uplinks != tt.wantUplinks || sessions[0].FCntUp != tt.wantFCntUp {
+				t.Errorf("uplinks %v, FCntUp %#x, want %v, %#x",
+					sessions[0].Uplinks, sessions[0].FCntUp, tt.wantUplinks, tt.wantFCntUp)
+			}
+
+		})
+	}
+
+}
+
+func TestJoin(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		keys          []lorawan.AES128Key
+		nonces        []lorawan.DevNonce
+		wantJoined    bool
+		wantJoinNonce lorawan.JoinNonce
+	}{
+		{"valid", []lorawan.AES128Key{testAppKey}, []lorawan.DevNonce{1}, true, 1},
+		{"invalid MIC", []lorawan.AES128Key{{0xff}}, []lorawan.DevNonce{1}, false, 0},
+		{"DevNonce reused", []lorawan.AES128Key{testAppKey, testAppKey}, []lorawan.DevNonce{1, 1}, true, 1},
+		{"new DevNonce", []lorawan.AES128Key{testAppKey, testAppKey}, []lorawan.DevNonce{1, 2}, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s, sent := newTestServer(t, otaaDevice)
+
+			for i, key := range tt.keys {
+				receiveTest(s, joinRequest(t, key, tt.nonces[i]))
+			}
+
+			joined := len(s.Sessions()) == 1
+			if joined != tt.wantJoined {
+				t.Fatalf("joined %v, want %v", joined, tt.wantJoined)
+			}
+
+			var joinNonce lorawan.JoinNonce
+			if ss, ok := s.sessions[testDevEUI]; ok {
+				joinNonce = ss.joinNonce
+			}
+
+			if joinNonce != tt.wantJoinNonce {
+				t.Errorf("JoinNonce %v, want %v", joinNonce, tt.wantJoinNonce)
+			}
+
+			if accepts := len(sent); accepts != int(tt.wantJoinNonce) {
+				t.Errorf("%v join accepts sent, want %v", accepts, tt.wantJoinNonce)
+			}
+
+		})
+	}
+
+}
+
+// downlinkPayload decrypts the FRMPayload of the downlink of the test ABP device
+func downlinkPayload(t *testing.T, txpk pkt.TXPK) (uint32, []byte) {
+
+	t.Helper()
+
+	var phy lorawan.PHYPayload
+	if err := phy.UnmarshalBinary(txpk.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := phy.ValidateDownlinkDataMIC(lorawan.LoRaWAN1_0, 0, testNwkSKey)
+	if err != nil || !valid {
+		t.Fatalf("downlink with an invalid MIC: %v", err)
+	}
+
+	if err := phy.DecryptFRMPayload(testAppSKey); err != nil {
+		t.Fatal(err)
+	}
+
+	macPL := phy.MACPayload.(*lorawan.MACPayload)
+	if len(macPL.FRMPayload) == 0 {
+		return macPL.FHDR.FCnt, nil
+	}
+
+	return macPL.FHDR.FCnt, macPL.FRMPayload[0].(*lorawan.DataPayload).Bytes
+}
+
+func TestRetransmission(t *testing.T) {
+
+	s, sent := newTestServer(t, abpDevice)
+
+	for _, payload := range [][]byte{{0xa}, {0xb}} {
+		if err := s.Enqueue(testDevEUI, Downlink{FPort: 2, Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		fcnt         uint32
+		wantFCntDown uint32
+		wantPayload  []byte
+	}{
+		{"first", 1, 0, []byte{0xa}},
+		{"retransmission", 1, 0, []byte{0xa}}, //the lost downlink again, not the next queued
+		{"next", 2, 1, []byte{0xb}},
+	}
+
+	for _, tt := range tests {
+
+		receiveTest(s, dataUplink(t, testNwkSKey, tt.fcnt, true))
+
+		select {
+		case txpk := <-sent:
+			fcnt, payload := downlinkPayload(t, txpk)
+			if fcnt != tt.wantFCntDown || !bytes.Equal(payload, tt.wantPayload) {
+				t.Errorf("%v: downlink %v %x, want %v %x", tt.name, fcnt, payload, tt.wantFCntDown, tt.wantPayload)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v: no downlink", tt.name)
+		}
+
+	}
+
+}
@@ -0,0 +1,214 @@
+package networkserver
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/brocaar/lorawan"
+)
+
+// errors of the queues
+var (
+	ErrDeviceNotFound = errors.New("Device not found")
+	ErrQueueFull      = errors.New("Queue full")
+)
+
+const (
+	// MaxFOptsLen is the size of the MAC commands sent in the header of a downlink
+	MaxFOptsLen = 15
+
+	// MaxQueue is the number of downlinks that can be queued for a device
+	MaxQueue = 32
+)
+
+// Device holds what the server knows of a device before its first uplink, as the
+// device profile and the keys of a network server
+type Device struct {
+	DevEUI      lorawan.EUI64
+	OTAA        bool
+	AppKey      lorawan.AES128Key // OTAA
+	DevAddr     lorawan.DevAddr   // ABP
+	NwkSKey     lorawan.AES128Key // ABP
+	AppSKey     lorawan.AES128Key // ABP
+	FCntDown    uint32            // ABP, where the session starts
+	Region      int               // code of the regional parameters
+	RX1DROffset uint8
+	RX1Delay    time.Duration
+	RX2DataRate uint8
+}
+
+// Downlink is an application payload queued for a device
+type Downlink struct {
+	FPort     uint8  `json:"fPort"`
+	Payload   []byte `json:"payload"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+// Uplink is the last uplink of a session
+type Uplink struct {
+	Time      time.Time `json:"time"`
+	FCnt      uint32    `json:"fCnt"`
+	FPort     *uint8    `json:"fPort,omitempty"`
+	Payload   string    `json:"payload,omitempty"` // decrypted, hex
+	Confirmed bool      `json:"confirmed"`
+	Gateways  int       `json:"gateways"` // that received it
+	RSSI      int16     `json:"rssi"`     // of the best gateway
+	SNR       float64   `json:"snr"`
+}
+
+// Session is the state of an activated device
+type Session struct {
+	DevEUI     lorawan.EUI64   `json:"devEUI"`
+	DevAddr    lorawan.DevAddr `json:"devAddr"`
+	OTAA       bool            `json:"otaa"`
+	Start      time.Time       `json:"start"` // join or first uplink
+	FCntUp     uint32          `json:"fCntUp"`
+	FCntDown   uint32          `json:"fCntDown"`
+	Uplinks    int             `json:"uplinks"`    // accepted in the session
+	Queue      int             `json:"queue"`      // downlinks not sent yet
+	MACQueue   int             `json:"macQueue"`   // MAC commands not sent yet
+	LastUplink *Uplink         `json:"lastUplink"` // nil before the first one
+}
+
+type session struct {
+	Session
+	device     Device
+	nwkSKey    lorawan.AES128Key
+	appSKey    lorawan.AES128Key
+	active     bool // joined or ABP
+	received   bool // an uplink, FCntUp is known
+	devNonces  map[lorawan.DevNonce]bool
+	joinNonce  lorawan.JoinNonce
+	queue      []Downlink
+	macQueue   [][]byte            // CID and payload
+	last       *lorawan.PHYPayload // the last downlink, sent again to a retransmission of its uplink
+	lastFCntUp uint32              // of the uplink answered by last
+}
+
+// Sessions returns the sessions ordered by DevEUI
+func (s *Server) Sessions() []Session {
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	sessions := []Session{}
+
+	for _, ss := range s.sessions {
+
+		if !ss.active {
+			continue //only a queue, the device has not joined yet
+		}
+
+		session := ss.Session
+		session.Queue = len(ss.queue)
+		session.MACQueue = len(ss.macQueue)
+
+		if ss.LastUplink != nil {
+			last := *ss.LastUplink
+			session.LastUplink = &last
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].DevEUI.String() < sessions[j].DevEUI.String()
+	})
+
+	return sessions
+}
+
+// Enqueue queues a downlink for the device DevEUI, it is sent in the answer to one of its next uplinks
+func (s *Server) Enqueue(DevEUI lorawan.EUI64, downlink Downlink) error {
+
+	if downlink.FPort == 0 || downlink.FPort > 223 {
+		return errors.New("FPort must be between 1 and 223")
+	}
+
+	device, ok := s.device(DevEUI)
+	if !ok {
+		return ErrDeviceNotFound
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	ss := s.sessionOf(device)
+
+	if len(ss.queue) >= MaxQueue {
+		return ErrQueueFull
+	}
+
+	ss.queue = append(ss.queue, downlink)
+
+	return nil
+}
+
+// EnqueueMACCommand queues the MAC command cid with its payload for the device DevEUI
+func (s *Server) EnqueueMACCommand(DevEUI lorawan.EUI64, cid lorawan.CID, payload []byte) error {
+
+	if 1+len(payload) > MaxFOptsLen {
+		return errors.New("MAC command too long")
+	}
+
+	device, ok := s.device(DevEUI)
+	if !ok {
+		return ErrDeviceNotFound
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	ss := s.sessionOf(device)
+
+	if len(ss.macQueue) >= MaxQueue {
+		return ErrQueueFull
+	}
+
+	ss.macQueue = append(ss.macQueue, append([]byte{byte(cid)}, payload...))
+
+	return nil
+}
+
+// sessionOf returns the session of the device, created if missing. The session of an ABP
+// device starts again when its address or its keys change. The mutex must be held
+func (s *Server) sessionOf(device Device) *session {
+
+	ss, ok := s.sessions[device.DevEUI]
+	if !ok {
+
+		ss = &session{
+			devNonces: make(map[lorawan.DevNonce]bool),
+		}
+
+		s.sessions[device.DevEUI] = ss
+	}
+
+	ss.device = device
+
+	if !device.OTAA && (!ss.active || ss.DevAddr != device.DevAddr || ss.nwkSKey != device.NwkSKey || ss.appSKey != device.AppSKey) {
+		ss.start(device.DevAddr, device.NwkSKey, device.AppSKey, device.FCntDown)
+	}
+
+	return ss
+}
+
+// start begins a new session with the keys, the queues are kept
+func (ss *session) start(DevAddr lorawan.DevAddr, NwkSKey lorawan.AES128Key, AppSKey lorawan.AES128Key, FCntDown uint32) {
+
+	ss.Session = Session{
+		DevEUI:   ss.device.DevEUI,
+		DevAddr:  DevAddr,
+		OTAA:     ss.device.OTAA,
+		Start:    time.Now(),
+		FCntDown: FCntDown,
+	}
+
+	ss.nwkSKey = NwkSKey
+	ss.appSKey = AppSKey
+	ss.active = true
+	ss.received = false
+	ss.macQueue = nil
+	ss.last = nil
+}
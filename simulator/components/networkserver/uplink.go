@@ -0,0 +1,497 @@
+package networkserver
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	act "github.com/arslab/lwnsimulator/simulator/components/device/activation"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/brocaar/lorawan"
+)
+
+// JoinAcceptDelay is the delay of the first receive window of a join accept
+const JoinAcceptDelay = 5 * time.Second
+
+// reception is a copy of an uplink received by a gateway
+type reception struct {
+	gateway lorawan.EUI64
+	rxpk    pkt.RXPK
+}
+
+// uplink gathers the copies of a frame during the deduplication
+type uplink struct {
+	phy      []byte
+	received []reception
+}
+
+// best is the reception with the highest SNR, it answers the uplink
+func (u *uplink) best() reception {
+
+	best := u.received[0]
+
+	for _, r := range u.received[1:] {
+		if r.rxpk.LSNR > best.rxpk.LSNR || (r.rxpk.LSNR == best.rxpk.LSNR && r.rxpk.RSSI > best.rxpk.RSSI) {
+			best = r
+		}
+	}
+
+	return best
+}
+
+// receive adds the rxpk to its uplink, the first copy starts the deduplication
+func (s *Server) receive(mac lorawan.EUI64, rxpk pkt.RXPK) {
+
+	phy, err := base64.StdEncoding.DecodeString(rxpk.Data)
+	if err != nil {
+		s.print("", fmt.Errorf("rxpk of %v: %w", mac, err))
+		return
+	}
+
+	key := string(phy)
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if up, ok := s.pending[key]; ok {
+		up.received = append(up.received, reception{mac, rxpk})
+		return
+	}
+
+	s.pending[key] = &uplink{
+		phy:      phy,
+		received: []reception{{mac, rxpk}},
+	}
+
+	time.AfterFunc(s.deduplication, func() {
+
+		s.Mutex.Lock()
+		up := s.pending[key]
+		delete(s.pending, key)
+		s.Mutex.Unlock()
+
+		s.process(up)
+	})
+}
+
+func (s *Server) process(up *uplink) {
+
+	var phy lorawan.PHYPayload
+	if err := phy.UnmarshalBinary(up.phy); err != nil {
+		s.print("", err)
+		return
+	}
+
+	switch phy.MHDR.MType {
+
+	case lorawan.JoinRequest:
+		s.join(up, phy)
+
+	case lorawan.UnconfirmedDataUp, lorawan.ConfirmedDataUp:
+		s.data(up, phy)
+
+	}
+
+}
+
+// join answers a join request of a known OTAA device with a new session
+func (s *Server) join(up *uplink, phy lorawan.PHYPayload) {
+
+	jr, ok := phy.MACPayload.(*lorawan.JoinRequestPayload)
+	if !ok {
+		return
+	}
+
+	device, ok := s.device(jr.DevEUI)
+	if !ok || !device.OTAA {
+		s.print(fmt.Sprintf("Join request of the unknown device %v", jr.DevEUI), nil)
+		return
+	}
+
+	valid, err := phy.ValidateUplinkJoinMIC(device.AppKey)
+	if err != nil || !valid {
+		s.print("", fmt.Errorf("Join request of %v with an invalid MIC", jr.DevEUI))
+		return
+	}
+
+	s.Mutex.Lock()
+
+	ss := s.sessionOf(device)
+
+	if ss.devNonces[jr.DevNonce] {
+		s.Mutex.Unlock()
+		s.print("", fmt.Errorf("Join request of %v with a DevNonce already used", jr.DevEUI))
+		return
+	}
+
+	ss.devNonces[jr.DevNonce] = true
+	ss.joinNonce++
+
+	devAddr := s.newDevAddr()
+
+	nwkSKey, err := act.GetKey(s.netID, ss.joinNonce, jr.DevNonce, device.AppKey, act.PadNwkSKey)
+	if err != nil {
+		s.Mutex.Unlock()
+		s.print("", err)
+		return
+	}
+
+	appSKey, err := act.GetKey(s.netID, ss.joinNonce, jr.DevNonce, device.AppKey, act.PadAppSKey)
+	if err != nil {
+		s.Mutex.Unlock()
+		s.print("", err)
+		return
+	}
+
+	ss.start(devAddr, nwkSKey, appSKey, 0)
+
+	accept := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{
+			MType: lorawan.JoinAccept,
+			Major: lorawan.LoRaWANR1,
+		},
+		MACPayload: &lorawan.JoinAcceptPayload{
+			JoinNonce: ss.joinNonce,
+			HomeNetID: s.netID,
+			DevAddr:   devAddr,
+			DLSettings: lorawan.DLSettings{
+				RX2DataRate: device.RX2DataRate,
+				RX1DROffset: device.RX1DROffset,
+			},
+			RXDelay: rxDelay(device.RX1Delay),
+		},
+	}
+
+	s.Mutex.Unlock()
+
+	if err := accept.SetDownlinkJoinMIC(lorawan.JoinRequestType, jr.JoinEUI, jr.DevNonce, device.AppKey); err != nil {
+		s.print("", err)
+		return
+	}
+
+	if err := accept.EncryptJoinAcceptPayload(device.AppKey); err != nil {
+		s.print("", err)
+		return
+	}
+
+	if err := s.transmit(up.best(), device, accept, JoinAcceptDelay); err != nil {
+		s.print("", fmt.Errorf("Join accept of %v: %w", jr.DevEUI, err))
+		return
+	}
+
+	s.print(fmt.Sprintf("Join accept of %v, DevAddr %v", jr.DevEUI, devAddr), nil)
+}
+
+// newDevAddr returns an address of the NetID not used by the other sessions, the mutex must be held
+func (s *Server) newDevAddr() lorawan.DevAddr {
+
+	for {
+
+		var devAddr lorawan.DevAddr
+		rand.Read(devAddr[:])
+		devAddr.SetAddrPrefix(s.netID)
+
+		used := false
+		for _, ss := range s.sessions {
+			if ss.active && ss.DevAddr == devAddr {
+				used = true
+				break
+			}
+		}
+
+		if !used {
+			return devAddr
+		}
+	}
+}
+
+// rxDelay is the RXDelay field of the join accept, in seconds
+func rxDelay(delay time.Duration) uint8 {
+
+	seconds := uint8(delay / time.Second)
+
+	if seconds < 1 {
+		return 1
+	}
+
+	if seconds > 15 {
+		return 15
+	}
+
+	return seconds
+}
+
+// data checks a data uplink and answers it when there is something to send
+func (s *Server) data(up *uplink, phy lorawan.PHYPayload) {
+
+	macPL, ok := phy.MACPayload.(*lorawan.MACPayload)
+	if !ok {
+		return
+	}
+
+	var abp []Device
+	if s.ABPDevices != nil {
+		abp = s.ABPDevices(macPL.FHDR.DevAddr)
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	candidates := []*session{}
+
+	for _, d := range abp {
+		candidates = append(candidates, s.sessionOf(d))
+	}
+
+	for _, ss := range s.sessions {
+		if ss.OTAA && ss.active && ss.DevAddr == macPL.FHDR.DevAddr {
+			candidates = append(candidates, ss)
+		}
+	}
+
+	fcnt16 := macPL.FHDR.FCnt
+
+	for _, ss := range candidates {
+
+		fcnt := fcnt16
+		if ss.received {
+			fcnt = fullFCnt(ss.FCntUp, fcnt16)
+		}
+
+		macPL.FHDR.FCnt = fcnt
+
+		valid, err := phy.ValidateUplinkDataMIC(lorawan.LoRaWAN1_0, 0, 0, 0, ss.nwkSKey, ss.nwkSKey)
+		if err != nil || !valid {
+			continue
+		}
+
+		s.accept(up, phy, ss, fcnt)
+
+		return
+	}
+
+	s.print("", fmt.Errorf("Uplink of DevAddr %v without session or with an invalid MIC", macPL.FHDR.DevAddr))
+}
+
+// fullFCnt is the 32 bits counter of an uplink that carries its 16 less significant bits
+func fullFCnt(last uint32, fcnt16 uint32) uint32 {
+
+	fcnt := (last &^ 0xffff) | (fcnt16 & 0xffff)
+
+	if fcnt < last && last-fcnt > 0x8000 {
+		fcnt += 0x10000
+	}
+
+	return fcnt
+}
+
+// accept handles the uplink of the session, its MIC is valid. The mutex must be held
+func (s *Server) accept(up *uplink, phy lorawan.PHYPayload, ss *session, fcnt uint32) {
+
+	macPL := phy.MACPayload.(*lorawan.MACPayload)
+	confirmed := phy.MHDR.MType == lorawan.ConfirmedDataUp
+
+	retransmission := false
+
+	if ss.received && fcnt <= ss.FCntUp {
+
+		if fcnt < ss.FCntUp {
+			s.print("", fmt.Errorf("Uplink of %v with the counter %v already received", ss.DevEUI, fcnt))
+			return
+		}
+
+		if !confirmed {
+			return //a repetition
+		}
+
+		retransmission = true //ACKed again
+	}
+
+	best := up.best()
+
+	if !retransmission {
+
+		ss.FCntUp = fcnt
+		ss.received = true
+		ss.Uplinks++
+
+		last := Uplink{
+			Time:      time.Now(),
+			FCnt:      fcnt,
+			FPort:     macPL.FPort,
+			Confirmed: confirmed,
+			Gateways:  len(up.received),
+			RSSI:      best.rxpk.RSSI,
+			SNR:       best.rxpk.LSNR,
+		}
+
+		if macPL.FPort != nil && *macPL.FPort > 0 && len(macPL.FRMPayload) > 0 {
+			if err := phy.DecryptFRMPayload(ss.appSKey); err == nil {
+				if pl, ok := macPL.FRMPayload[0].(*lorawan.DataPayload); ok {
+					last.Payload = hex.EncodeToString(pl.Bytes)
+				}
+			}
+		}
+
+		ss.LastUplink = &last
+	}
+
+	commands := s.answers(up, phy, ss)
+
+	if !retransmission {
+		commands = append(commands, s.scripted(ss)...)
+	}
+
+	var downlink lorawan.PHYPayload
+
+	if retransmission && ss.last != nil && ss.lastFCntUp == fcnt {
+
+		downlink = *ss.last //lost, sent again rather than the next queued one
+
+	} else {
+
+		if !confirmed && len(commands) == 0 && len(ss.queue) == 0 && len(ss.macQueue) == 0 {
+			return //nothing to send
+		}
+
+		var err error
+
+		downlink, err = s.downlink(ss, confirmed, commands)
+		if err != nil {
+			s.print("", err)
+			return
+		}
+
+		ss.last = &downlink
+		ss.lastFCntUp = fcnt
+	}
+
+	device := ss.device
+	delay := device.RX1Delay
+	if ss.OTAA {
+		delay = time.Duration(rxDelay(delay)) * time.Second //as in the join accept
+	}
+
+	go func() { //the mutex is held
+
+		if err := s.transmit(best, device, downlink, delay); err != nil {
+			s.print("", fmt.Errorf("Downlink of %v: %w", device.DevEUI, err))
+		}
+
+	}()
+}
+
+// answers returns the answers to the MAC commands of the uplink
+func (s *Server) answers(up *uplink, phy lorawan.PHYPayload, ss *session) [][]byte {
+
+	macPL := phy.MACPayload.(*lorawan.MACPayload)
+
+	var requests []lorawan.Payload
+
+	if err := phy.DecodeFOptsToMACCommands(); err == nil {
+		requests = append(requests, macPL.FHDR.FOpts...)
+	}
+
+	if macPL.FPort != nil && *macPL.FPort == 0 {
+		if err := phy.DecryptFRMPayload(ss.nwkSKey); err == nil {
+			requests = append(requests, macPL.FRMPayload...)
+		}
+	}
+
+	var commands [][]byte
+
+	for _, r := range requests {
+
+		command, ok := r.(*lorawan.MACCommand)
+		if !ok {
+			continue
+		}
+
+		var answer lorawan.MACCommand
+
+		switch command.CID {
+
+		case lorawan.LinkCheckReq:
+
+			best := up.best()
+
+			margin := best.rxpk.LSNR - requiredSNR(best.rxpk.DatR)
+			if margin < 0 {
+				margin = 0
+			}
+
+			answer = lorawan.MACCommand{
+				CID: lorawan.LinkCheckAns,
+				Payload: &lorawan.LinkCheckAnsPayload{
+					Margin: uint8(margin),
+					GwCnt:  uint8(len(up.received)),
+				},
+			}
+
+		case lorawan.DeviceTimeReq:
+
+			answer = lorawan.MACCommand{
+				CID: lorawan.DeviceTimeAns,
+				Payload: &lorawan.DeviceTimeAnsPayload{
+					TimeSinceGPSEpoch: gpsTime(time.Now()),
+				},
+			}
+
+		default:
+			continue //an answer of the device
+		}
+
+		data, err := answer.MarshalBinary()
+		if err != nil {
+			s.print("", err)
+			continue
+		}
+
+		commands = append(commands, data)
+	}
+
+	return commands
+}
+
+// scripted returns the MAC commands of the script due at the last uplink of the session
+func (s *Server) scripted(ss *session) [][]byte {
+
+	var commands [][]byte
+
+	for _, c := range s.script {
+
+		if c.devEUI != nil && *c.devEUI != ss.DevEUI {
+			continue
+		}
+
+		due := ss.Uplinks == c.uplink ||
+			(c.repeat > 0 && ss.Uplinks > c.uplink && (ss.Uplinks-c.uplink)%c.repeat == 0)
+
+		if due {
+			commands = append(commands, c.data)
+		}
+	}
+
+	return commands
+}
+
+// requiredSNR is the demodulation floor of the spreading factor of datr, eg. SF7BW125
+func requiredSNR(datr string) float64 {
+
+	var sf, bw int
+	if _, err := fmt.Sscanf(datr, "SF%dBW%d", &sf, &bw); err != nil {
+		return 0
+	}
+
+	return -5 - 2.5*float64(sf-6) // -7.5 dB at SF7, -20 dB at SF12
+}
+
+// gpsTime is the time since the GPS epoch, with the leap seconds
+func gpsTime(t time.Time) time.Duration {
+
+	epoch := time.Date(1980, time.January, 6, 0, 0, 0, 0, time.UTC)
+
+	return t.Sub(epoch) + 18*time.Second
+}
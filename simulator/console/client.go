@@ -0,0 +1,115 @@
+package console
+
+import (
+	"log"
+	"sync"
+
+	"github.com/arslab/lwnsimulator/socket"
+	socketio "github.com/googollee/go-socket.io"
+)
+
+// QueueSize is how many messages a client can lag behind before the next ones are dropped
+const QueueSize = 256
+
+type message struct {
+	event string
+	data  interface{}
+}
+
+// Client is a web socket connected to the console, it receives the events of the
+// devices and gateways it is subscribed to
+type Client struct {
+	Mutex    sync.Mutex
+	Conn     socketio.Conn
+	devices  map[int]bool // all if empty
+	gateways map[int]bool // all if empty
+	keys     bool         // receives the session keys
+	queue    chan message
+	Dropped  uint64
+	lagging  bool
+}
+
+func newClient(conn socketio.Conn, keys bool) *Client {
+
+	c := Client{
+		Conn:  conn,
+		keys:  keys,
+		queue: make(chan message, QueueSize),
+	}
+
+	go c.writer()
+
+	return &c
+}
+
+// writer emits the queued messages, a slow connection blocks only its own client
+func (c *Client) writer() {
+	for m := range c.queue {
+		c.Conn.Emit(m.event, m.data)
+	}
+}
+
+// send queues the message without waiting, it is dropped if the queue is full
+func (c *Client) send(event string, data interface{}) {
+
+	select {
+
+	case c.queue <- message{event, data}:
+
+		c.Mutex.Lock()
+		c.lagging = false
+		c.Mutex.Unlock()
+
+	default:
+
+		c.Mutex.Lock()
+
+		c.Dropped++
+		if !c.lagging { //once for each streak
+			log.Println("[WS]: client", c.Conn.ID(), "is too slow, messages dropped")
+		}
+		c.lagging = true
+
+		c.Mutex.Unlock()
+
+	}
+
+}
+
+func (c *Client) close() {
+	close(c.queue)
+}
+
+// SetSubscription replaces the devices and gateways the client is subscribed to
+func (c *Client) SetSubscription(sub socket.Subscription) {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	c.devices = make(map[int]bool)
+	for _, id := range sub.Devices {
+		c.devices[id] = true
+	}
+
+	c.gateways = make(map[int]bool)
+	for _, id := range sub.Gateways {
+		c.gateways[id] = true
+	}
+
+}
+
+func (c *Client) wantsDevice(id int) bool {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	return len(c.devices) == 0 || c.devices[id]
+}
+
+func (c *Client) wantsGateway(id int) bool {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	return len(c.gateways) == 0 || c.gateways[id]
+}
@@ -1,25 +1,197 @@
 package console
 
 import (
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/simulator/util"
+	"github.com/arslab/lwnsimulator/socket"
 	socketio "github.com/googollee/go-socket.io"
 )
 
+// Console prints the events of the simulator on the log and on the web sockets
 type Console struct {
-	WebSocket socketio.Conn
+	Mutex   sync.RWMutex
+	clients map[string]*Client
 }
 
-func (c *Console) PrintLog(message string) {
-	log.Println(message)
+// Subscribe registers the log and the web sockets as subscribers of bus
+func (c *Console) Subscribe(bus *events.Bus) {
+	bus.Subscribe(c.printLog)
+	bus.Subscribe(c.printSocket)
 }
 
-func (c *Console) PrintSocket(eventName string, data ...interface{}) {
-	if c.WebSocket != nil {
-		c.WebSocket.Emit(eventName, data...)
+// AddClient adds a web socket, subscribed to every device and gateway. Without keys,
+// the session keys are removed from the status of the devices
+func (c *Console) AddClient(conn socketio.Conn, keys bool) {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.clients == nil {
+		c.clients = make(map[string]*Client)
+	}
+
+	if old, ok := c.clients[conn.ID()]; ok {
+		old.close()
+	}
+
+	c.clients[conn.ID()] = newClient(conn, keys)
+}
+
+func (c *Console) RemoveClient(id string) {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	client, ok := c.clients[id]
+	if !ok {
+		return
 	}
+
+	client.close()
+	delete(c.clients, id)
+}
+
+// SetSubscription changes the devices and gateways of the client id, false if it is not connected
+func (c *Console) SetSubscription(id string, sub socket.Subscription) bool {
+
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+
+	client, ok := c.clients[id]
+	if !ok {
+		return false
+	}
+
+	client.SetSubscription(sub)
+
+	return true
 }
 
-func (c *Console) SetupWebSocket(WebSocket *socketio.Conn) {
-	c.WebSocket = *WebSocket
+func (c *Console) printLog(e events.Event) {
+
+	l, ok := e.(*events.Log)
+	if !ok || l.Output == util.PrintOnlySocket {
+		return
+	}
+
+	log.Println(l.Message)
+}
+
+func (c *Console) printSocket(e events.Event) {
+
+	switch ev := e.(type) {
+
+	case *events.Log:
+
+		if ev.Output == util.PrintOnlyConsole {
+			return
+		}
+
+		data := socket.ConsoleLog{
+			Name: ev.Name,
+			Msg:  fmt.Sprintf("[ %s ] %s", ev.Time.Format(time.Stamp), ev.Message),
+		}
+
+		c.broadcast(logFilter(ev), socketEvent(ev), data)
+
+	case *events.CommandResponse:
+		c.broadcast(nil, socket.EventResponseCommand, ev.Message)
+
+	case *events.DeviceState:
+
+		filter := func(client *Client) bool { return client.wantsDevice(ev.Id) }
+
+		if ev.Status != nil {
+
+			hidden := *ev.Status
+			hidden.NwkSKey = ""
+			hidden.AppSKey = ""
+
+			c.broadcast(func(client *Client) bool { return client.keys && filter(client) }, socket.EventSaveStatus, *ev.Status)
+			c.broadcast(func(client *Client) bool { return !client.keys && filter(client) }, socket.EventSaveStatus, hidden)
+		}
+
+		c.broadcast(filter, socket.EventResponseCommand, ev.Name+" "+turnToString(ev.Type))
+
+	case *events.DeviceLiveState:
+
+		filter := func(client *Client) bool { return client.wantsDevice(ev.Id) }
+
+		hidden := ev.State
+		hidden.NwkSKey = ""
+		hidden.AppSKey = ""
+
+		c.broadcast(func(client *Client) bool { return client.keys && filter(client) }, socket.EventLiveStateDev, ev.State)
+		c.broadcast(func(client *Client) bool { return !client.keys && filter(client) }, socket.EventLiveStateDev, hidden)
+
+	case *events.GatewayState:
+
+		filter := func(client *Client) bool { return client.wantsGateway(ev.Id) }
+		c.broadcast(filter, socket.EventResponseCommand, ev.Name+" "+turnToString(ev.Type))
+
+	case *events.GatewayLink:
+
+		filter := func(client *Client) bool { return client.wantsGateway(ev.Id) }
+		c.broadcast(filter, socket.EventConnectionGw, ev.Link)
+
+	}
+
+}
+
+// broadcast queues the message for the clients accepted by filter, all if nil
+func (c *Console) broadcast(filter func(*Client) bool, event string, data interface{}) {
+
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+
+	for _, client := range c.clients {
+		if filter == nil || filter(client) {
+			client.send(event, data)
+		}
+	}
+
+}
+
+// logFilter selects the clients subscribed to the device or gateway of the log line
+func logFilter(l *events.Log) func(*Client) bool {
+
+	switch l.Source {
+	case events.SourceDevice:
+		return func(client *Client) bool { return client.wantsDevice(l.Id) }
+	case events.SourceGateway:
+		return func(client *Client) bool { return client.wantsGateway(l.Id) }
+	}
+
+	return nil
+}
+
+func socketEvent(l *events.Log) string {
+
+	if l.Error {
+		return socket.EventError
+	}
+
+	switch l.Source {
+	case events.SourceDevice:
+		return socket.EventDev
+	case events.SourceGateway:
+		return socket.EventGw
+	}
+
+	return socket.EventLog
+}
+
+func turnToString(typeEvent string) string {
+
+	switch typeEvent {
+	case events.TypeDeviceTurnedOn, events.TypeGatewayTurnedOn:
+		return "Turn ON"
+	}
+
+	return "Turn OFF"
 }
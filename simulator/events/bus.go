@@ -0,0 +1,66 @@
+package events
+
+import (
+	"sync"
+)
+
+// Handler receives the events of the bus, it is called in the goroutine of the publisher and must not block
+type Handler func(Event)
+
+type subscriber struct {
+	id      int
+	handler Handler
+}
+
+// Bus delivers the events of the simulator to its subscribers, in order of subscription
+type Bus struct {
+	Mutex       sync.RWMutex
+	subscribers []subscriber
+	nextID      int
+}
+
+// Subscribe adds handler to the bus and returns the id to unsubscribe it
+func (b *Bus) Subscribe(handler Handler) int {
+
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	b.nextID++
+	b.subscribers = append(b.subscribers, subscriber{
+		id:      b.nextID,
+		handler: handler,
+	})
+
+	return b.nextID
+}
+
+func (b *Bus) Unsubscribe(id int) {
+
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	for i, s := range b.subscribers {
+		if s.id == id {
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+
+}
+
+// Publish hands e to every subscriber, a nil bus drops it
+func (b *Bus) Publish(e Event) {
+
+	if b == nil {
+		return
+	}
+
+	b.Mutex.RLock()
+	subscribers := b.subscribers
+	b.Mutex.RUnlock()
+
+	for _, s := range subscribers {
+		s.handler(e)
+	}
+
+}
@@ -0,0 +1,218 @@
+package events
+
+import (
+	"time"
+
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
+	"github.com/arslab/lwnsimulator/socket"
+	"github.com/brocaar/lorawan"
+)
+
+// types of the events
+const (
+	TypeLog             = "Log"
+	TypeCommandResponse = "CommandResponse"
+
+	TypeSimulatorStarted = "SimulatorStarted"
+	TypeSimulatorStopped = "SimulatorStopped"
+
+	TypeDeviceTurnedOn     = "DeviceTurnedOn"
+	TypeDeviceTurnedOff    = "DeviceTurnedOff"
+	TypeDeviceJoined       = "DeviceJoined"
+	TypeDeviceUnjoined     = "DeviceUnjoined"
+	TypeJoinFailed         = "JoinFailed"
+	TypeUplinkSent         = "UplinkSent"
+	TypeDownlinkReceived   = "DownlinkReceived"
+	TypeAckTimeout         = "AckTimeout"
+	TypeMACCommandExecuted = "MACCommandExecuted"
+	TypeDeviceLiveState    = "DeviceLiveState"
+
+	TypeGatewayTurnedOn     = "GatewayTurnedOn"
+	TypeGatewayTurnedOff    = "GatewayTurnedOff"
+	TypeGatewayConnecting   = "GatewayConnecting"
+	TypeGatewayConnected    = "GatewayConnected"
+	TypeGatewayDisconnected = "GatewayDisconnected"
+	TypeDatagramSent        = "DatagramSent"
+	TypeDatagramReceived    = "DatagramReceived"
+
+	TypeFrame = "Frame"
+)
+
+// sources of the log lines
+const (
+	SourceSimulator = "SIM"
+	SourceDevice    = "DEV"
+	SourceGateway   = "GW"
+	SourceNetwork   = "NS" // the embedded network server
+)
+
+type Event interface {
+	GetHeader() Header
+}
+
+// Header is common to every event
+type Header struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+}
+
+func NewHeader(typeEvent string) Header {
+	return Header{
+		Type: typeEvent,
+		Time: time.Now(),
+	}
+}
+
+func (h Header) GetHeader() Header {
+	return h
+}
+
+// Device identifies the device of an event
+type Device struct {
+	Id     int           `json:"id"`
+	Name   string        `json:"name"`
+	DevEUI lorawan.EUI64 `json:"devEUI"`
+	Region string        `json:"region"` // eg. EU868
+	Class  string        `json:"class"`
+}
+
+// GetDevice returns the device of the events that embed it
+func (d Device) GetDevice() Device {
+	return d
+}
+
+// Gateway identifies the gateway of an event
+type Gateway struct {
+	Id         int           `json:"id"`
+	Name       string        `json:"name"`
+	MACAddress lorawan.EUI64 `json:"macAddress"`
+}
+
+// GetGateway returns the gateway of the events that embed it
+func (g Gateway) GetGateway() Gateway {
+	return g
+}
+
+// Log is a line of the console, Output is one of util.PrintBoth, util.PrintOnlySocket and util.PrintOnlyConsole
+type Log struct {
+	Header
+	Source  string `json:"source"`
+	Id      int    `json:"id"` // of the device or gateway
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Error   bool   `json:"error"`
+	Output  int    `json:"-"`
+}
+
+// CommandResponse answers a command of the web UI
+type CommandResponse struct {
+	Header
+	Message string `json:"message"`
+}
+
+// SimulatorState is published when the simulator starts or stops
+type SimulatorState struct {
+	Header
+}
+
+// DeviceState is published when a device is turned on or off, with its status when turned off
+type DeviceState struct {
+	Header
+	Device
+	Status *socket.NewStatusDev `json:"status,omitempty"`
+}
+
+type DeviceJoined struct {
+	Header
+	Device
+	DevAddr  lorawan.DevAddr `json:"devAddr"`
+	Duration float64         `json:"duration"` // ms since the first join request
+}
+
+// DeviceUnjoined is published when an OTAA device has to join again
+type DeviceUnjoined struct {
+	Header
+	Device
+}
+
+type JoinFailed struct {
+	Header
+	Device
+	Reason string `json:"reason"`
+}
+
+type UplinkSent struct {
+	Header
+	Device
+	DevAddr        lorawan.DevAddr `json:"devAddr"`
+	MType          lorawan.MType   `json:"mType"`
+	FCnt           uint32          `json:"fCnt"`
+	FPort          *uint8          `json:"fPort,omitempty"`
+	Frequency      uint32          `json:"frequency"` // Hz
+	DataRate       string          `json:"dataRate"`  // eg. SF7BW125
+	DR             uint8           `json:"dr"`
+	TXPower        uint8           `json:"txPower"` // index of the region
+	Retransmission bool            `json:"retransmission"`
+	Payload        []byte          `json:"payload,omitempty"` // decrypted, with a FPort other than 0
+}
+
+type DownlinkReceived struct {
+	Header
+	Device
+	MType    lorawan.MType `json:"mType"`
+	FCnt     uint32        `json:"fCnt"`
+	FPort    *uint8        `json:"fPort,omitempty"`
+	ACK      bool          `json:"ack"`
+	FPending bool          `json:"fPending"`
+	Payload  []byte        `json:"payload,omitempty"` // decrypted
+	Window   string        `json:"window"`            // RX1, RX2 or RXC
+	Latency  float64       `json:"latency"`           // ms since the last uplink, 0 in RXC
+}
+
+// AckTimeout is published when no downlink is received in the receive windows
+type AckTimeout struct {
+	Header
+	Device
+	FCnt      uint32 `json:"fCnt"` // of the last uplink
+	Confirmed bool   `json:"confirmed"`
+}
+
+type MACCommandExecuted struct {
+	Header
+	Device
+	CID lorawan.CID `json:"cid"`
+}
+
+// DeviceLiveState is published after each uplink, each downlink of class C and the join
+type DeviceLiveState struct {
+	Header
+	Device
+	State socket.LiveStateDev `json:"state"`
+}
+
+// GatewayState is published when a gateway is turned on or off
+type GatewayState struct {
+	Header
+	Gateway
+}
+
+// GatewayLink is published when the connection with the network server changes,
+// the type follows the state of the link
+type GatewayLink struct {
+	Header
+	Gateway
+	Link socket.LinkGw `json:"link"`
+}
+
+// Datagram is a Semtech UDP datagram sent or received by a gateway
+type Datagram struct {
+	Header
+	Gateway
+	PacketType string `json:"packetType"` // eg. PUSH DATA
+}
+
+// Frame is a record of the frame log, published for each frame on the air
+type Frame struct {
+	Header
+	Frame framelog.Frame `json:"frame"`
+}
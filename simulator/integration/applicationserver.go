@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/brocaar/lorawan"
+)
+
+// types of the events of the application servers
+const (
+	EventUp   = "up"
+	EventJoin = "join"
+	EventAck  = "ack"
+)
+
+// ApplicationEvent is an uplink, a join or the ACK of a confirmed downlink, as posted by
+// the webhook of an application server
+type ApplicationEvent struct {
+	Type    string
+	DevEUI  lorawan.EUI64
+	FCnt    uint32
+	FPort   uint8
+	Payload []byte // decrypted
+}
+
+// chirpStackEvent is the JSON encoding of the events of the HTTP integration of ChirpStack,
+// v4 and v3
+type chirpStackEvent struct {
+	DeviceInfo *struct {
+		DevEUI string `json:"devEui"` // hex
+	} `json:"deviceInfo"`
+	DevEUI       string  `json:"devEUI"` // v3, base64
+	DevAddr      *string `json:"devAddr"`
+	FCnt         *uint32 `json:"fCnt"`
+	FPort        uint8   `json:"fPort"`
+	Data         []byte  `json:"data"`
+	Acknowledged *bool   `json:"acknowledged"`
+}
+
+// ParseChirpStack decodes body of the HTTP integration of ChirpStack, event is the value of
+// its query parameter event. The events other than up, join and ack are ignored, with an empty type
+func ParseChirpStack(event string, body []byte) (ApplicationEvent, error) {
+
+	var ev chirpStackEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return ApplicationEvent{}, err
+	}
+
+	if event == "" { //guessed from the fields
+		switch {
+		case ev.Acknowledged != nil:
+			event = EventAck
+		case ev.FCnt != nil:
+			event = EventUp
+		case ev.DevAddr != nil:
+			event = EventJoin
+		}
+	}
+
+	switch event {
+	case EventUp, EventJoin, EventAck:
+	default:
+		return ApplicationEvent{}, nil
+	}
+
+	devEUI := ev.DevEUI
+	if ev.DeviceInfo != nil {
+		devEUI = ev.DeviceInfo.DevEUI
+	}
+
+	DevEUI, err := parseEUI(devEUI)
+	if err != nil {
+		return ApplicationEvent{}, err
+	}
+
+	result := ApplicationEvent{
+		Type:    event,
+		DevEUI:  DevEUI,
+		FPort:   ev.FPort,
+		Payload: ev.Data,
+	}
+
+	if ev.FCnt != nil {
+		result.FCnt = *ev.FCnt
+	}
+
+	return result, nil
+}
+
+// ttsEvent is the JSON encoding of the messages of the webhooks of The Things Stack
+type ttsEvent struct {
+	EndDeviceIds struct {
+		DevEUI string `json:"dev_eui"` // hex
+	} `json:"end_device_ids"`
+	UplinkMessage *struct {
+		FPort      uint8  `json:"f_port"`
+		FCnt       uint32 `json:"f_cnt"`
+		FRMPayload []byte `json:"frm_payload"`
+	} `json:"uplink_message"`
+	JoinAccept  *json.RawMessage `json:"join_accept"`
+	DownlinkAck *json.RawMessage `json:"downlink_ack"`
+}
+
+// ParseTTS decodes body of a webhook of The Things Stack. The messages other than the
+// uplinks, the join accepts and the downlink ACKs are ignored, with an empty type
+func ParseTTS(body []byte) (ApplicationEvent, error) {
+
+	var ev ttsEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return ApplicationEvent{}, err
+	}
+
+	var result ApplicationEvent
+
+	switch {
+	case ev.UplinkMessage != nil:
+		result = ApplicationEvent{
+			Type:    EventUp,
+			FCnt:    ev.UplinkMessage.FCnt,
+			FPort:   ev.UplinkMessage.FPort,
+			Payload: ev.UplinkMessage.FRMPayload,
+		}
+	case ev.JoinAccept != nil:
+		result.Type = EventJoin
+	case ev.DownlinkAck != nil:
+		result.Type = EventAck
+	default:
+		return ApplicationEvent{}, nil
+	}
+
+	DevEUI, err := parseEUI(ev.EndDeviceIds.DevEUI)
+	if err != nil {
+		return ApplicationEvent{}, err
+	}
+
+	result.DevEUI = DevEUI
+
+	return result, nil
+}
+
+// parseEUI decodes a DevEUI in hex, or in base64 as in the events of ChirpStack v3
+func parseEUI(s string) (lorawan.EUI64, error) {
+
+	var EUI lorawan.EUI64
+
+	bytes, err := hex.DecodeString(s)
+	if err != nil || len(bytes) != len(EUI) {
+		bytes, err = base64.StdEncoding.DecodeString(s)
+	}
+
+	if err != nil || len(bytes) != len(EUI) {
+		return EUI, errors.New("DevEUI expected")
+	}
+
+	copy(EUI[:], bytes)
+
+	return EUI, nil
+}
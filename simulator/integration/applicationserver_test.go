@@ -0,0 +1,99 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/brocaar/lorawan"
+)
+
+var testDevEUI = lorawan.EUI64{1, 2, 3, 4, 5, 6, 7, 8}
+
+func TestParseChirpStack(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		event   string
+		body    string
+		want    ApplicationEvent
+		wantErr bool
+	}{
+		{"v4 up", "up", `{"deviceInfo": {"devEui": "0102030405060708"}, "fCnt": 7, "fPort": 2, "data": "AQID"}`,
+			ApplicationEvent{Type: EventUp, DevEUI: testDevEUI, FCnt: 7, FPort: 2, Payload: []byte{1, 2, 3}}, false},
+		{"v3 up", "up", `{"devEUI": "AQIDBAUGBwg=", "fCnt": 7, "fPort": 2, "data": "AQID"}`,
+			ApplicationEvent{Type: EventUp, DevEUI: testDevEUI, FCnt: 7, FPort: 2, Payload: []byte{1, 2, 3}}, false},
+		{"join", "join", `{"deviceInfo": {"devEui": "0102030405060708"}, "devAddr": "01020304"}`,
+			ApplicationEvent{Type: EventJoin, DevEUI: testDevEUI}, false},
+		{"ack", "ack", `{"deviceInfo": {"devEui": "0102030405060708"}, "acknowledged": true, "fCntDown": 3}`,
+			ApplicationEvent{Type: EventAck, DevEUI: testDevEUI}, false},
+		{"up guessed", "", `{"deviceInfo": {"devEui": "0102030405060708"}, "fCnt": 0, "fPort": 1}`,
+			ApplicationEvent{Type: EventUp, DevEUI: testDevEUI, FPort: 1}, false},
+		{"join guessed", "", `{"deviceInfo": {"devEui": "0102030405060708"}, "devAddr": "01020304"}`,
+			ApplicationEvent{Type: EventJoin, DevEUI: testDevEUI}, false},
+		{"ack guessed", "", `{"deviceInfo": {"devEui": "0102030405060708"}, "acknowledged": false}`,
+			ApplicationEvent{Type: EventAck, DevEUI: testDevEUI}, false},
+		{"status ignored", "status", `{"deviceInfo": {"devEui": "0102030405060708"}, "batteryLevel": 90}`,
+			ApplicationEvent{}, false},
+		{"unknown ignored", "", `{"deviceInfo": {"devEui": "0102030405060708"}}`, ApplicationEvent{}, false},
+		{"invalid DevEUI", "up", `{"deviceInfo": {"devEui": "0102"}, "fCnt": 1}`, ApplicationEvent{}, true},
+		{"invalid JSON", "up", `{"fCnt": `, ApplicationEvent{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := ParseChirpStack(tt.event, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+
+			if !equalEvents(got, tt.want) {
+				t.Errorf("ParseChirpStack = %+v, want %+v", got, tt.want)
+			}
+
+		})
+	}
+
+}
+
+func TestParseTTS(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		body    string
+		want    ApplicationEvent
+		wantErr bool
+	}{
+		{"uplink", `{"end_device_ids": {"dev_eui": "0102030405060708"}, "uplink_message": {"f_port": 2, "f_cnt": 7, "frm_payload": "AQID"}}`,
+			ApplicationEvent{Type: EventUp, DevEUI: testDevEUI, FCnt: 7, FPort: 2, Payload: []byte{1, 2, 3}}, false},
+		{"join accept", `{"end_device_ids": {"dev_eui": "0102030405060708"}, "join_accept": {"session_key_id": "AQI="}}`,
+			ApplicationEvent{Type: EventJoin, DevEUI: testDevEUI}, false},
+		{"downlink ack", `{"end_device_ids": {"dev_eui": "0102030405060708"}, "downlink_ack": {"f_port": 1}}`,
+			ApplicationEvent{Type: EventAck, DevEUI: testDevEUI}, false},
+		{"other ignored", `{"end_device_ids": {"dev_eui": "0102030405060708"}, "location_solved": {}}`,
+			ApplicationEvent{}, false},
+		{"invalid DevEUI", `{"end_device_ids": {"dev_eui": "zz"}, "join_accept": {}}`, ApplicationEvent{}, true},
+		{"invalid JSON", `[`, ApplicationEvent{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := ParseTTS([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+
+			if !equalEvents(got, tt.want) {
+				t.Errorf("ParseTTS = %+v, want %+v", got, tt.want)
+			}
+
+		})
+	}
+
+}
+
+func equalEvents(a ApplicationEvent, b ApplicationEvent) bool {
+	return a.Type == b.Type && a.DevEUI == b.DevEUI && a.FCnt == b.FCnt && a.FPort == b.FPort &&
+		bytes.Equal(a.Payload, b.Payload)
+}
@@ -0,0 +1,357 @@
+package integration
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/brocaar/lorawan"
+)
+
+const (
+	defaultDeliveryTimeout = 10 * time.Second
+	defaultDeliveryHistory = 1000
+	maxLostFrames          = 100 // listed in a report
+)
+
+// DeliveryReport compares the uplinks sent by a device with the ones delivered by the
+// application server. The frames sent less than the timeout ago and not yet delivered are pending
+type DeliveryReport struct {
+	DeviceId       int           `json:"deviceId"`
+	Name           string        `json:"name"`
+	DevEUI         lorawan.EUI64 `json:"devEUI"`
+	Sent           int           `json:"sent"` // frames with a FPort other than 0, each FCnt once
+	Delivered      int           `json:"delivered"`
+	Pending        int           `json:"pending"`
+	Lost           int           `json:"lost"`
+	PDR            float64       `json:"pdr"`           // delivered / (sent - pending)
+	Duplicates     int           `json:"duplicates"`    // deliveries of a frame already delivered
+	DuplicateRate  float64       `json:"duplicateRate"` // duplicates / deliveries
+	Mismatched     int           `json:"mismatched"`    // deliveries of a FCnt sent with another FPort or payload, not delivered
+	Unmatched      int           `json:"unmatched"`     // deliveries of a FCnt not sent
+	Latency        Latency       `json:"latency"`       // from the first transmission to the delivery
+	Joins          int           `json:"joins"`
+	JoinsDelivered int           `json:"joinsDelivered"`
+	Acks           int           `json:"acks"` // of confirmed downlinks
+	LostFrames     []LostFrame   `json:"lostFrames"`
+}
+
+// Latency is in ms
+type Latency struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// LostFrame is a frame never delivered by the application server
+type LostFrame struct {
+	FCnt  uint32    `json:"fCnt"` // 16 bits, as in the frame
+	FPort uint8     `json:"fPort"`
+	Time  time.Time `json:"time"`
+}
+
+type sentFrame struct {
+	fCnt      uint32
+	fPort     uint8
+	time      time.Time
+	payload   []byte
+	delivered int
+}
+
+type deliveryDevice struct {
+	id             int
+	name           string
+	frames         []*sentFrame // the oldest first
+	sent           int
+	delivered      int
+	lostEvicted    int // lost beyond the history or in a previous session
+	duplicates     int
+	mismatched     int
+	unmatched      int
+	latencySum     float64
+	latencyMin     float64
+	latencyMax     float64
+	joins          int
+	joinsDelivered int
+	acks           int
+}
+
+// Delivery matches the events of the application server with the uplinks sent by the
+// devices, by DevEUI, FCnt and payload
+type Delivery struct {
+	Mutex   sync.Mutex
+	timeout time.Duration
+	size    int
+	devices map[lorawan.EUI64]*deliveryDevice
+}
+
+// NewDelivery returns the verification of config
+func NewDelivery(config models.Delivery) *Delivery {
+
+	d := Delivery{
+		timeout: time.Duration(config.Timeout) * time.Millisecond,
+		size:    config.History,
+		devices: make(map[lorawan.EUI64]*deliveryDevice),
+	}
+
+	if d.timeout <= 0 {
+		d.timeout = defaultDeliveryTimeout
+	}
+
+	if d.size <= 0 {
+		d.size = defaultDeliveryHistory
+	}
+
+	return &d
+}
+
+// Subscribe records the uplinks and the joins of the devices of bus
+func (d *Delivery) Subscribe(bus *events.Bus) int {
+
+	return bus.Subscribe(func(e events.Event) {
+
+		switch ev := e.(type) {
+
+		case *events.UplinkSent:
+			if ev.FPort != nil && *ev.FPort > 0 {
+				d.sent(ev)
+			}
+
+		case *events.DeviceJoined:
+			d.joined(ev)
+
+		}
+
+	})
+
+}
+
+func (d *Delivery) device(ref events.Device) *deliveryDevice {
+
+	dev, ok := d.devices[ref.DevEUI]
+	if !ok {
+		dev = &deliveryDevice{}
+		d.devices[ref.DevEUI] = dev
+	}
+
+	dev.id = ref.Id
+	dev.name = ref.Name
+
+	return dev
+}
+
+// joined forgets the frames of the previous session, their FCnt are sent again
+func (d *Delivery) joined(ev *events.DeviceJoined) {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	dev := d.device(ev.Device)
+	dev.joins++
+
+	for _, f := range dev.frames {
+		if f.delivered == 0 {
+			dev.lostEvicted++
+		}
+	}
+
+	dev.frames = nil
+}
+
+func (d *Delivery) sent(ev *events.UplinkSent) {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	dev := d.device(ev.Device)
+
+	if n := len(dev.frames); ev.Retransmission && n > 0 && dev.frames[n-1].fCnt == ev.FCnt {
+		return
+	}
+
+	dev.frames = append(dev.frames, &sentFrame{
+		fCnt:    ev.FCnt,
+		fPort:   *ev.FPort,
+		time:    ev.Time,
+		payload: ev.Payload,
+	})
+	dev.sent++
+
+	if len(dev.frames) > d.size {
+
+		if dev.frames[0].delivered == 0 {
+			dev.lostEvicted++
+		}
+
+		dev.frames = dev.frames[1:]
+	}
+
+}
+
+// Receive records ev, delivered by the application server now
+func (d *Delivery) Receive(ev ApplicationEvent) {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	dev, ok := d.devices[ev.DevEUI]
+	if !ok { //not a device of the simulator, or yet to send
+		return
+	}
+
+	switch ev.Type {
+
+	case EventJoin:
+		dev.joinsDelivered++
+
+	case EventAck:
+		dev.acks++
+
+	case EventUp:
+
+		var frame *sentFrame
+		for i := len(dev.frames) - 1; i >= 0; i-- { //the FCnt of the frames has 16 bits
+			if dev.frames[i].fCnt&0xFFFF == ev.FCnt&0xFFFF {
+				frame = dev.frames[i]
+				break
+			}
+		}
+
+		if frame == nil {
+			dev.unmatched++
+			return
+		}
+
+		if frame.fPort != ev.FPort || !bytes.Equal(frame.payload, ev.Payload) { //not this frame
+			dev.mismatched++
+			return
+		}
+
+		frame.delivered++
+
+		if frame.delivered > 1 {
+			dev.duplicates++
+			return
+		}
+
+		dev.delivered++
+
+		latency := float64(time.Since(frame.time)) / float64(time.Millisecond)
+
+		if dev.delivered == 1 || latency < dev.latencyMin {
+			dev.latencyMin = latency
+		}
+
+		dev.latencyMax = math.Max(dev.latencyMax, latency)
+		dev.latencySum += latency
+
+	}
+
+}
+
+// Reports returns the report of each device that sent an uplink or joined, by id
+func (d *Delivery) Reports() []DeliveryReport {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	reports := []DeliveryReport{}
+	for DevEUI, dev := range d.devices {
+		reports = append(reports, d.report(DevEUI, dev))
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].DeviceId < reports[j].DeviceId
+	})
+
+	return reports
+}
+
+// Report returns the report of the device DevEUI, empty if it never sent an uplink
+func (d *Delivery) Report(DevEUI lorawan.EUI64) DeliveryReport {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	dev, ok := d.devices[DevEUI]
+	if !ok {
+		return DeliveryReport{DevEUI: DevEUI, LostFrames: []LostFrame{}}
+	}
+
+	return d.report(DevEUI, dev)
+}
+
+func (d *Delivery) report(DevEUI lorawan.EUI64, dev *deliveryDevice) DeliveryReport {
+
+	report := DeliveryReport{
+		DeviceId:       dev.id,
+		Name:           dev.name,
+		DevEUI:         DevEUI,
+		Sent:           dev.sent,
+		Delivered:      dev.delivered,
+		Lost:           dev.lostEvicted,
+		Duplicates:     dev.duplicates,
+		Mismatched:     dev.mismatched,
+		Unmatched:      dev.unmatched,
+		Joins:          dev.joins,
+		JoinsDelivered: dev.joinsDelivered,
+		Acks:           dev.acks,
+		LostFrames:     []LostFrame{},
+	}
+
+	deadline := time.Now().Add(-d.timeout)
+
+	for _, f := range dev.frames {
+
+		if f.delivered > 0 {
+			continue
+		}
+
+		if f.time.After(deadline) {
+			report.Pending++
+			continue
+		}
+
+		report.Lost++
+		report.LostFrames = append(report.LostFrames, LostFrame{
+			FCnt:  f.fCnt,
+			FPort: f.fPort,
+			Time:  f.time,
+		})
+	}
+
+	if n := len(report.LostFrames); n > maxLostFrames {
+		report.LostFrames = report.LostFrames[n-maxLostFrames:]
+	}
+
+	if settled := report.Sent - report.Pending; settled > 0 {
+		report.PDR = float64(report.Delivered) / float64(settled)
+	}
+
+	if deliveries := report.Delivered + report.Duplicates; deliveries > 0 {
+		report.DuplicateRate = float64(report.Duplicates) / float64(deliveries)
+	}
+
+	if dev.delivered > 0 {
+		report.Latency = Latency{
+			Min: dev.latencyMin,
+			Avg: dev.latencySum / float64(dev.delivered),
+			Max: dev.latencyMax,
+		}
+	}
+
+	return report
+}
+
+// Reset forgets the frames and the deliveries
+func (d *Delivery) Reset() {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	d.devices = make(map[lorawan.EUI64]*deliveryDevice)
+}
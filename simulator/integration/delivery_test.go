@@ -0,0 +1,132 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+	"github.com/arslab/lwnsimulator/simulator/events"
+)
+
+// deliveryStep is a frame sent, a join or a delivery by the application server
+type deliveryStep struct {
+	op             string // sent, joined or up
+	fcnt           uint32
+	fport          uint8
+	payload        []byte
+	age            time.Duration // of the frame sent
+	retransmission bool
+}
+
+func TestDeliveryReport(t *testing.T) {
+
+	payload := []byte{1, 2, 3}
+	old := time.Minute // beyond the timeout
+
+	tests := []struct {
+		name  string
+		steps []deliveryStep
+		want  DeliveryReport
+	}{
+		{"delivered",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload}, {op: "up", fcnt: 1, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 1, Delivered: 1, PDR: 1}},
+		{"pending",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 1, Pending: 1}},
+		{"lost",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload, age: old}, {op: "sent", fcnt: 2, fport: 2, payload: payload, age: old},
+				{op: "up", fcnt: 2, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 2, Delivered: 1, Lost: 1, PDR: 0.5}},
+		{"duplicate",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload}, {op: "up", fcnt: 1, fport: 2, payload: payload},
+				{op: "up", fcnt: 1, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 1, Delivered: 1, Duplicates: 1, PDR: 1, DuplicateRate: 0.5}},
+		{"retransmission sent once",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload}, {op: "sent", fcnt: 1, fport: 2, payload: payload, retransmission: true},
+				{op: "up", fcnt: 1, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 1, Delivered: 1, PDR: 1}},
+		{"other payload not delivered",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload, age: old}, {op: "up", fcnt: 1, fport: 2, payload: []byte{9}}},
+			DeliveryReport{Sent: 1, Lost: 1, Mismatched: 1}},
+		{"other FPort not delivered",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload}, {op: "up", fcnt: 1, fport: 3, payload: payload}},
+			DeliveryReport{Sent: 1, Pending: 1, Mismatched: 1}},
+		{"FCnt not sent",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload}, {op: "up", fcnt: 5, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 1, Pending: 1, Unmatched: 1}},
+		{"16 bits FCnt",
+			[]deliveryStep{{op: "sent", fcnt: 0x10001, fport: 2, payload: payload}, {op: "up", fcnt: 1, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 1, Delivered: 1, PDR: 1}},
+		{"frames of the previous session lost on the join",
+			[]deliveryStep{{op: "sent", fcnt: 1, fport: 2, payload: payload}, {op: "joined"},
+				{op: "sent", fcnt: 1, fport: 2, payload: []byte{4}}, {op: "up", fcnt: 1, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 2, Pending: 1, Lost: 1, Mismatched: 1, Joins: 1}},
+		{"delivered after the join",
+			[]deliveryStep{{op: "joined"}, {op: "sent", fcnt: 0, fport: 2, payload: payload}, {op: "up", fcnt: 0, fport: 2, payload: payload}},
+			DeliveryReport{Sent: 1, Delivered: 1, PDR: 1, Joins: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d := NewDelivery(models.Delivery{Timeout: 1000})
+			device := events.Device{Id: 1, Name: "device", DevEUI: testDevEUI}
+
+			for _, step := range tt.steps {
+
+				switch step.op {
+
+				case "sent":
+					fport := step.fport
+					d.sent(&events.UplinkSent{
+						Header:         events.Header{Type: events.TypeUplinkSent, Time: time.Now().Add(-step.age)},
+						Device:         device,
+						FCnt:           step.fcnt,
+						FPort:          &fport,
+						Payload:        step.payload,
+						Retransmission: step.retransmission,
+					})
+
+				case "joined":
+					d.joined(&events.DeviceJoined{Device: device})
+
+				case "up":
+					d.Receive(ApplicationEvent{
+						Type:    EventUp,
+						DevEUI:  testDevEUI,
+						FCnt:    step.fcnt,
+						FPort:   step.fport,
+						Payload: step.payload,
+					})
+
+				}
+
+			}
+
+			got := d.Report(testDevEUI)
+			want := tt.want
+
+			if got.Sent != want.Sent || got.Delivered != want.Delivered || got.Pending != want.Pending ||
+				got.Lost != want.Lost || got.Duplicates != want.Duplicates || got.Mismatched != want.Mismatched ||
+				got.Unmatched != want.Unmatched || got.Joins != want.Joins || got.PDR != want.PDR ||
+				got.DuplicateRate != want.DuplicateRate {
+				t.Errorf("report %+v, want %+v", got, want)
+			}
+
+		})
+	}
+
+}
+
+func TestDeliveryUnknownDevice(t *testing.T) {
+
+	d := NewDelivery(models.Delivery{})
+
+	d.Receive(ApplicationEvent{Type: EventUp, DevEUI: testDevEUI, FCnt: 1})
+
+	if reports := d.Reports(); len(reports) != 0 {
+		t.Errorf("%v reports, want none", len(reports))
+	}
+
+}
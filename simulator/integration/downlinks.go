@@ -0,0 +1,143 @@
+package integration
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/mqtt"
+	"github.com/arslab/lwnsimulator/simulator/resources/webhook"
+	"github.com/brocaar/lorawan"
+)
+
+// defaultHistory is the number of downlinks kept by device when none is configured
+const defaultHistory = 100
+
+// Downlink is an application downlink received by a device
+type Downlink struct {
+	Time      time.Time     `json:"time"`
+	DeviceId  int           `json:"deviceId"`
+	Name      string        `json:"name"`
+	DevEUI    lorawan.EUI64 `json:"devEUI"`
+	FCnt      uint32        `json:"fCnt"`
+	FPort     uint8         `json:"fPort"`
+	Payload   string        `json:"payload"` // hex, decrypted
+	Confirmed bool          `json:"confirmed"`
+	FPending  bool          `json:"fPending"`
+	Window    string        `json:"window"` // RX1, RX2 or RXC
+}
+
+// Downlinks keeps the last application downlinks of each device and delivers them to the
+// webhooks and to the topic <prefix>/devices/<devEUI>/downlink of the MQTT broker
+type Downlinks struct {
+	Mutex    sync.Mutex
+	size     int
+	history  map[int][]Downlink // by id of device, the oldest first
+	webhooks []*webhook.Webhook
+	mqtt     *mqtt.Client // none if nil
+}
+
+// NewDownlinks returns the delivery of config, client can be nil
+func NewDownlinks(config models.Downlinks, client *mqtt.Client) *Downlinks {
+
+	d := Downlinks{
+		size:    config.History,
+		history: make(map[int][]Downlink),
+		mqtt:    client,
+	}
+
+	if d.size <= 0 {
+		d.size = defaultHistory
+	}
+
+	for _, URL := range config.Webhooks {
+		d.webhooks = append(d.webhooks, webhook.New(models.Webhook{URL: URL}))
+	}
+
+	return &d
+}
+
+// Subscribe delivers the downlinks with a FPort other than 0 received by the devices of bus
+func (d *Downlinks) Subscribe(bus *events.Bus) int {
+
+	return bus.Subscribe(func(e events.Event) {
+
+		ev, ok := e.(*events.DownlinkReceived)
+		if !ok || ev.FPort == nil || *ev.FPort == 0 {
+			return
+		}
+
+		d.deliver(Downlink{
+			Time:      ev.Time,
+			DeviceId:  ev.Id,
+			Name:      ev.Name,
+			DevEUI:    ev.DevEUI,
+			FCnt:      ev.FCnt,
+			FPort:     *ev.FPort,
+			Payload:   hex.EncodeToString(ev.Payload),
+			Confirmed: ev.MType == lorawan.ConfirmedDataDown,
+			FPending:  ev.FPending,
+			Window:    ev.Window,
+		})
+
+	})
+
+}
+
+func (d *Downlinks) deliver(downlink Downlink) {
+
+	d.Mutex.Lock()
+
+	history := append(d.history[downlink.DeviceId], downlink)
+	if len(history) > d.size {
+		history = history[len(history)-d.size:]
+	}
+
+	d.history[downlink.DeviceId] = history
+
+	d.Mutex.Unlock()
+
+	for _, w := range d.webhooks {
+		w.Post(downlink)
+	}
+
+	if d.mqtt != nil {
+
+		payload, err := json.Marshal(downlink)
+		if err == nil {
+			d.mqtt.Publish(d.mqtt.Topic("devices", downlink.DevEUI.String(), "downlink"), payload)
+		}
+
+	}
+
+}
+
+// Close stops the webhooks
+func (d *Downlinks) Close() {
+
+	for _, w := range d.webhooks {
+		w.Close()
+	}
+
+}
+
+// History returns the last downlinks of the device Id, the oldest first
+func (d *Downlinks) History(Id int) []Downlink {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	return append([]Downlink{}, d.history[Id]...)
+}
+
+// Clear forgets the downlinks of the device Id
+func (d *Downlinks) Clear(Id int) {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	delete(d.history, Id)
+}
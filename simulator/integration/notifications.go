@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arslab/lwnsimulator/models"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/simulator/resources/webhook"
+)
+
+// notified are the types of the events posted to the webhooks
+var notified = []string{
+	events.TypeSimulatorStarted,
+	events.TypeSimulatorStopped,
+	events.TypeDeviceJoined,
+	events.TypeJoinFailed,
+	events.TypeAckTimeout, // of the confirmed uplinks only
+	events.TypeGatewayDisconnected,
+	events.TypeMACCommandExecuted,
+}
+
+type notification struct {
+	webhook *webhook.Webhook
+	events  map[string]bool // all the notified ones if empty
+}
+
+// Notifications posts the lifecycle events of the simulator, its devices and its gateways
+// to the webhooks, as JSON with their type
+type Notifications struct {
+	webhooks []notification
+}
+
+// NewNotifications returns the notifications of config, an error if it names an event not notified
+func NewNotifications(config []models.Webhook) (*Notifications, error) {
+
+	n := Notifications{}
+
+	for _, w := range config {
+
+		if w.URL == "" {
+			return nil, fmt.Errorf("webhook: url expected")
+		}
+
+		filter := make(map[string]bool)
+		for _, typeEvent := range w.Events {
+
+			if !isNotified(typeEvent) {
+				return nil, fmt.Errorf("webhook %v: event %v unknown, one of %v expected",
+					w.URL, typeEvent, strings.Join(notified, ", "))
+			}
+
+			filter[typeEvent] = true
+		}
+
+		n.webhooks = append(n.webhooks, notification{
+			webhook: webhook.New(w),
+			events:  filter,
+		})
+	}
+
+	return &n, nil
+}
+
+func isNotified(typeEvent string) bool {
+
+	for _, t := range notified {
+		if t == typeEvent {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Subscribe posts the events of bus to the webhooks that want them
+func (n *Notifications) Subscribe(bus *events.Bus) int {
+
+	return bus.Subscribe(func(e events.Event) {
+
+		typeEvent := e.GetHeader().Type
+		if !isNotified(typeEvent) {
+			return
+		}
+
+		if ev, ok := e.(*events.AckTimeout); ok && !ev.Confirmed {
+			return
+		}
+
+		for _, w := range n.webhooks {
+			if len(w.events) == 0 || w.events[typeEvent] {
+				w.webhook.Post(e)
+			}
+		}
+
+	})
+
+}
+
+// Close stops the webhooks
+func (n *Notifications) Close() {
+
+	for _, w := range n.webhooks {
+		w.webhook.Close()
+	}
+
+}
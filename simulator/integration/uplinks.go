@@ -0,0 +1,63 @@
+package integration
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	mup "github.com/arslab/lwnsimulator/simulator/components/device/frames/uplink/models"
+	"github.com/brocaar/lorawan"
+)
+
+// MaxPayload is the size of the largest FRMPayload, at the highest data rates
+const MaxPayload = 242
+
+// Uplink is an uplink of an external system, sent through the session of the device DevEUI
+type Uplink struct {
+	DevEUI    lorawan.EUI64 `json:"devEUI" binding:"required"`
+	FPort     *uint8        `json:"fPort" binding:"omitempty,min=1,max=223"` // of the device if missing, 0 is reserved to the MAC commands
+	Payload   string        `json:"payload"`
+	Encoding  string        `json:"encoding" binding:"omitempty,oneof=hex base64"` // of the payload, hex (default)
+	Confirmed bool          `json:"confirmed"`
+}
+
+// Frame returns the frame of the uplink to queue in the device
+func (u *Uplink) Frame() (mup.InfoFrame, error) {
+
+	if u.FPort != nil && (*u.FPort == 0 || *u.FPort > 223) {
+		return mup.InfoFrame{}, errors.New("fPort: 1 to 223 expected")
+	}
+
+	var payload []byte
+	var err error
+
+	switch u.Encoding {
+	case "", "hex":
+		payload, err = hex.DecodeString(u.Payload)
+	case "base64":
+		payload, err = base64.StdEncoding.DecodeString(u.Payload)
+	default:
+		return mup.InfoFrame{}, errors.New("encoding: hex or base64 expected")
+	}
+
+	if err != nil {
+		return mup.InfoFrame{}, fmt.Errorf("payload: %v", err)
+	}
+
+	if len(payload) > MaxPayload {
+		return mup.InfoFrame{}, fmt.Errorf("payload: at most %v bytes expected", MaxPayload)
+	}
+
+	frame := mup.InfoFrame{
+		MType:   lorawan.UnconfirmedDataUp,
+		Payload: &lorawan.DataPayload{Bytes: payload},
+		FPort:   u.FPort,
+	}
+
+	if u.Confirmed {
+		frame.MType = lorawan.ConfirmedDataUp
+	}
+
+	return frame, nil
+}
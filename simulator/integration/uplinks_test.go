@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/brocaar/lorawan"
+)
+
+func TestUplinkFrame(t *testing.T) {
+
+	fport := func(p uint8) *uint8 { return &p }
+
+	tests := []struct {
+		name        string
+		uplink      Uplink
+		wantErr     bool
+		wantMType   lorawan.MType
+		wantPayload []byte
+	}{
+		{"hex", Uplink{Payload: "0a0b"}, false, lorawan.UnconfirmedDataUp, []byte{0xa, 0xb}},
+		{"base64", Uplink{Payload: "Cgs=", Encoding: "base64"}, false, lorawan.UnconfirmedDataUp, []byte{0xa, 0xb}},
+		{"confirmed", Uplink{FPort: fport(2), Confirmed: true}, false, lorawan.ConfirmedDataUp, []byte{}},
+		{"FPort 1", Uplink{FPort: fport(1)}, false, lorawan.UnconfirmedDataUp, []byte{}},
+		{"FPort 223", Uplink{FPort: fport(223)}, false, lorawan.UnconfirmedDataUp, []byte{}},
+		{"FPort 0", Uplink{FPort: fport(0)}, true, 0, nil},
+		{"FPort 224", Uplink{FPort: fport(224)}, true, 0, nil},
+		{"invalid hex", Uplink{Payload: "0g"}, true, 0, nil},
+		{"unknown encoding", Uplink{Encoding: "text"}, true, 0, nil},
+		{"too long", Uplink{Payload: string(bytes.Repeat([]byte("00"), MaxPayload+1))}, true, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			frame, err := tt.uplink.Frame()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if frame.MType != tt.wantMType {
+				t.Errorf("MType %v, want %v", frame.MType, tt.wantMType)
+			}
+
+			payload, ok := frame.Payload.(*lorawan.DataPayload)
+			if !ok || !bytes.Equal(payload.Bytes, tt.wantPayload) {
+				t.Errorf("payload %v, want %x", frame.Payload, tt.wantPayload)
+			}
+
+			if frame.FPort != tt.uplink.FPort {
+				t.Errorf("FPort %v, want %v", frame.FPort, tt.uplink.FPort)
+			}
+
+		})
+	}
+
+}
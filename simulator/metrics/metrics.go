@@ -0,0 +1,320 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/brocaar/lorawan"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/arslab/lwnsimulator/models"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+)
+
+// labelOther replaces the devices and gateways beyond models.Metrics.MaxLabelValues
+const labelOther = "other"
+
+var (
+	uplinkCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_uplink_sent_total",
+		Help: "The total number of uplinks sent",
+	}, []string{"device", "region", "class", "mtype"})
+	downlinkCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_downlink_received_total",
+		Help: "The total number of downlinks received",
+	}, []string{"device", "region", "class", "mtype", "window"})
+	ackTimeoutCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_ack_timetou_total",
+		Help: "The total number of ACK timeouts",
+	}, []string{"device", "region", "class"})
+	joinCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_join_total",
+		Help: "The total number of successful joins",
+	}, []string{"device", "region"})
+	joinFailedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_join_failed_total",
+		Help: "The total number of failed join attempts",
+	}, []string{"device", "region"})
+	macCommandCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_mac_command_executed_total",
+		Help: "The total number of MAC commands executed",
+	}, []string{"device", "region", "cid"})
+
+	joinDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "device_join_duration_seconds",
+		Help:    "The time from the first join request to the join accept",
+		Buckets: []float64{5, 6, 10, 20, 30, 60, 120, 300},
+	}, []string{"region"})
+	downlinkLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "device_downlink_latency_seconds",
+		Help:    "The time from the last uplink to the downlink, by receive window",
+		Buckets: []float64{0.5, 1, 1.5, 2, 2.5, 3, 5, 10},
+	}, []string{"region", "class", "window"})
+
+	joinedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "device_joined",
+		Help: "The number of joined devices",
+	}, []string{"region"})
+	dataRateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "device_data_rate",
+		Help: "The data rate of the last uplink of the device, only with the device label",
+	}, []string{"device", "region"})
+	txPowerGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "device_tx_power",
+		Help: "The TX power index of the last uplink of the device, only with the device label",
+	}, []string{"device", "region"})
+	dataRateDevices = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "device_data_rate_devices",
+		Help: "The number of devices by data rate of their last uplink",
+	}, []string{"region", "dr"})
+	txPowerDevices = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "device_tx_power_devices",
+		Help: "The number of devices by TX power index of their last uplink",
+	}, []string{"region", "txPower"})
+
+	pushDataCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_data_sent_total",
+		Help: "The total number of gateway PUSH DATA",
+	}, []string{"gateway"})
+	pullDataCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_pull_data_total",
+		Help: "The total number of gateway PULL DATA",
+	}, []string{"gateway"})
+	pushAckCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_push_ack_total",
+		Help: "The total number of gateway PUSH ACK",
+	}, []string{"gateway"})
+	pullAckCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_pull_ack_total",
+		Help: "The total number of gateway PULL ACK",
+	}, []string{"gateway"})
+	pullRespCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_pull_resp_total",
+		Help: "The total number of gateway PULL RESP",
+	}, []string{"gateway"})
+)
+
+// device is the last state of a device seen by the metrics
+type device struct {
+	label   string
+	region  string
+	joined  bool
+	uplink  bool // dr and txPower are counted in the gauges
+	dr      uint8
+	txPower uint8
+}
+
+type collector struct {
+	Mutex    sync.Mutex
+	config   models.Metrics
+	devices  map[lorawan.EUI64]*device
+	labels   map[string]bool // devices and gateways with their own label
+	gateways int
+	nDevices int
+}
+
+// Subscribe updates the Prometheus metrics with the events of bus, labelled as config
+func Subscribe(bus *events.Bus, config models.Metrics) int {
+
+	c := collector{
+		config:  config,
+		devices: make(map[lorawan.EUI64]*device),
+		labels:  make(map[string]bool),
+	}
+
+	return bus.Subscribe(c.handle)
+}
+
+func (c *collector) handle(e events.Event) {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	switch ev := e.(type) {
+
+	case *events.UplinkSent:
+
+		d := c.device(ev.Device)
+		uplinkCounter.WithLabelValues(d.label, d.region, ev.Class, ev.MType.String()).Inc()
+
+		c.setRadio(d, ev.DR, ev.TXPower)
+
+	case *events.DownlinkReceived:
+
+		d := c.device(ev.Device)
+		downlinkCounter.WithLabelValues(d.label, d.region, ev.Class, ev.MType.String(), ev.Window).Inc()
+
+		if ev.Latency > 0 {
+			downlinkLatency.WithLabelValues(d.region, ev.Class, ev.Window).Observe(ev.Latency / 1000.0)
+		}
+
+	case *events.AckTimeout:
+
+		d := c.device(ev.Device)
+		ackTimeoutCounter.WithLabelValues(d.label, d.region, ev.Class).Inc()
+
+	case *events.DeviceJoined:
+
+		d := c.device(ev.Device)
+		joinCounter.WithLabelValues(d.label, d.region).Inc()
+		joinDuration.WithLabelValues(d.region).Observe(ev.Duration / 1000.0)
+
+		if !d.joined {
+			d.joined = true
+			joinedGauge.WithLabelValues(d.region).Inc()
+		}
+
+	case *events.JoinFailed:
+
+		d := c.device(ev.Device)
+		joinFailedCounter.WithLabelValues(d.label, d.region).Inc()
+
+	case *events.DeviceUnjoined:
+		c.unjoin(c.device(ev.Device))
+
+	case *events.MACCommandExecuted:
+
+		d := c.device(ev.Device)
+		macCommandCounter.WithLabelValues(d.label, d.region, ev.CID.String()).Inc()
+
+	case *events.DeviceState:
+
+		if ev.Type == events.TypeDeviceTurnedOff {
+			c.remove(ev.DevEUI)
+		}
+
+	case *events.SimulatorState:
+
+		if ev.Type == events.TypeSimulatorStopped {
+			for DevEUI := range c.devices {
+				c.remove(DevEUI)
+			}
+		}
+
+	case *events.Datagram:
+		c.datagram(ev)
+
+	}
+
+}
+
+// device returns the state of the device of an event, its label is empty without the device label
+func (c *collector) device(ref events.Device) *device {
+
+	d, ok := c.devices[ref.DevEUI]
+	if ok {
+		return d
+	}
+
+	d = &device{
+		region: ref.Region,
+	}
+
+	if c.config.DeviceLabel {
+		d.label = c.label(ref.DevEUI.String(), &c.nDevices)
+	}
+
+	c.devices[ref.DevEUI] = d
+
+	return d
+}
+
+// label returns value until MaxLabelValues values are used, then labelOther
+func (c *collector) label(value string, used *int) string {
+
+	if c.labels[value] {
+		return value
+	}
+
+	if c.config.MaxLabelValues > 0 && *used >= c.config.MaxLabelValues {
+		return labelOther
+	}
+
+	c.labels[value] = true
+	*used++
+
+	return value
+}
+
+func (c *collector) setRadio(d *device, dr uint8, txPower uint8) {
+
+	if d.uplink {
+		dataRateDevices.WithLabelValues(d.region, strconv.Itoa(int(d.dr))).Dec()
+		txPowerDevices.WithLabelValues(d.region, strconv.Itoa(int(d.txPower))).Dec()
+	}
+
+	d.uplink = true
+	d.dr = dr
+	d.txPower = txPower
+
+	dataRateDevices.WithLabelValues(d.region, strconv.Itoa(int(dr))).Inc()
+	txPowerDevices.WithLabelValues(d.region, strconv.Itoa(int(txPower))).Inc()
+
+	if d.label != "" && d.label != labelOther {
+		dataRateGauge.WithLabelValues(d.label, d.region).Set(float64(dr))
+		txPowerGauge.WithLabelValues(d.label, d.region).Set(float64(txPower))
+	}
+}
+
+func (c *collector) unjoin(d *device) {
+
+	if d.joined {
+		d.joined = false
+		joinedGauge.WithLabelValues(d.region).Dec()
+	}
+
+}
+
+// remove drops the device turned off from the gauges, its counters are kept
+func (c *collector) remove(DevEUI lorawan.EUI64) {
+
+	d, ok := c.devices[DevEUI]
+	if !ok {
+		return
+	}
+
+	c.unjoin(d)
+
+	if d.uplink {
+		dataRateDevices.WithLabelValues(d.region, strconv.Itoa(int(d.dr))).Dec()
+		txPowerDevices.WithLabelValues(d.region, strconv.Itoa(int(d.txPower))).Dec()
+		d.uplink = false
+	}
+
+	if d.label != "" && d.label != labelOther {
+		dataRateGauge.DeleteLabelValues(d.label, d.region)
+		txPowerGauge.DeleteLabelValues(d.label, d.region)
+	}
+}
+
+func (c *collector) datagram(ev *events.Datagram) {
+
+	gateway := ""
+	if c.config.GatewayLabel {
+		gateway = c.label(ev.MACAddress.String(), &c.gateways)
+	}
+
+	if ev.Type == events.TypeDatagramSent {
+
+		switch ev.PacketType {
+		case pkt.StringPushData:
+			pushDataCounter.WithLabelValues(gateway).Inc()
+		case pkt.StringPullData:
+			pullDataCounter.WithLabelValues(gateway).Inc()
+		}
+
+		return
+	}
+
+	switch ev.PacketType {
+	case pkt.StringPushAck:
+		pushAckCounter.WithLabelValues(gateway).Inc()
+	case pkt.StringPullAck:
+		pullAckCounter.WithLabelValues(gateway).Inc()
+	case pkt.StringPullResp:
+		pullRespCounter.WithLabelValues(gateway).Inc()
+	}
+
+}
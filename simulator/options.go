@@ -0,0 +1,246 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/arslab/lwnsimulator/models"
+	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	f "github.com/arslab/lwnsimulator/simulator/components/forwarder"
+	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	ns "github.com/arslab/lwnsimulator/simulator/components/networkserver"
+	c "github.com/arslab/lwnsimulator/simulator/console"
+	"github.com/arslab/lwnsimulator/simulator/integration"
+	"github.com/arslab/lwnsimulator/simulator/metrics"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/mqtt"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	"github.com/arslab/lwnsimulator/simulator/util"
+	"github.com/brocaar/lorawan"
+)
+
+// Option configures a simulator created by New
+type Option func(*options)
+
+type options struct {
+	store         Store
+	dataDir       string
+	bridgeAddress string
+	devices       []*dev.Device
+	gateways      []*gw.Gateway
+	dial          udp.Dialer
+	pcap          bool
+	metrics       *models.Metrics
+	tracing       models.Tracing
+	network       *ns.Server
+	mqtt          *mqtt.Client
+	downlinks     models.Downlinks
+	delivery      models.Delivery
+	webhooks      []models.Webhook
+}
+
+// WithStore persists the simulator in store instead of the memory, what it holds is loaded by New
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithDataDir writes the captures and the frame log in dir, without it the frames are only published
+func WithDataDir(dir string) Option {
+	return func(o *options) {
+		o.dataDir = dir
+	}
+}
+
+// WithBridgeAddress sets the host:port of the network server of the virtual gateways
+func WithBridgeAddress(address string) Option {
+	return func(o *options) {
+		o.bridgeAddress = address
+	}
+}
+
+// WithDevices adds the devices after the ones of the store, the simulator keeps them
+func WithDevices(devices ...*dev.Device) Option {
+	return func(o *options) {
+		o.devices = append(o.devices, devices...)
+	}
+}
+
+// WithGateways adds the gateways after the ones of the store, the simulator keeps them
+func WithGateways(gateways ...*gw.Gateway) Option {
+	return func(o *options) {
+		o.gateways = append(o.gateways, gateways...)
+	}
+}
+
+// WithTransport connects the gateways with dial instead of UDP, eg. to an in-process network server
+func WithTransport(dial udp.Dialer) Option {
+	return func(o *options) {
+		o.dial = dial
+	}
+}
+
+// WithPcap captures each run in the pcap directory of the data directory
+func WithPcap(enable bool) Option {
+	return func(o *options) {
+		o.pcap = enable
+	}
+}
+
+// WithMetrics updates the Prometheus metrics, they are shared by the simulators of the process
+func WithMetrics(config models.Metrics) Option {
+	return func(o *options) {
+		o.metrics = &config
+	}
+}
+
+// WithNetworkServer gives the keys of the devices to server and logs its lines in the console.
+// Without a bridge address the gateways use it, over UDP if it listens, otherwise in-process
+// unless a transport is given
+func WithNetworkServer(server *ns.Server) Option {
+	return func(o *options) {
+		o.network = server
+	}
+}
+
+func WithTracing(config models.Tracing) Option {
+	return func(o *options) {
+		o.tracing = config
+	}
+}
+
+// WithMQTT delivers the application downlinks on the topics of client and sends the uplinks
+// published on it, the client is not started by New
+func WithMQTT(client *mqtt.Client) Option {
+	return func(o *options) {
+		o.mqtt = client
+	}
+}
+
+// WithDownlinks keeps the application downlinks and posts them to the webhooks of config
+func WithDownlinks(config models.Downlinks) Option {
+	return func(o *options) {
+		o.downlinks = config
+	}
+}
+
+// WithDelivery matches the uplinks delivered by the application server with the frames sent,
+// within the timeout of config
+func WithDelivery(config models.Delivery) Option {
+	return func(o *options) {
+		o.delivery = config
+	}
+}
+
+// WithWebhooks posts the lifecycle events of the simulator, its devices and its gateways
+// to the webhooks of config
+func WithWebhooks(config []models.Webhook) Option {
+	return func(o *options) {
+		o.webhooks = config
+	}
+}
+
+// New returns a stopped simulator, independent of the other ones. Without options its
+// state is kept in memory and its gateways connect over UDP
+func New(opts ...Option) (*Simulator, error) {
+
+	o := options{
+		store: &MemoryStore{},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := Simulator{
+		State:          util.Stopped,
+		Devices:        make(map[int]*dev.Device),
+		byDevEUI:       make(map[lorawan.EUI64]*dev.Device),
+		byDevAddr:      make(map[lorawan.DevAddr]map[lorawan.EUI64]struct{}),
+		Gateways:       make(map[int]*gw.Gateway),
+		ActiveDevices:  make(map[int]int),
+		ActiveGateways: make(map[int]int),
+		store:          o.store,
+		dataDir:        o.dataDir,
+	}
+
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.bridgeAddress != "" {
+		s.BridgeAddress = o.bridgeAddress
+	}
+
+	if o.network != nil {
+
+		s.NetworkServer = o.network
+		s.NetworkServer.Device = s.networkDevice
+		s.NetworkServer.ABPDevices = s.networkABPDevices
+		s.NetworkServer.Print = s.printNetwork
+
+		if s.BridgeAddress == "" {
+			s.BridgeAddress = s.NetworkServer.Address()
+		}
+
+		if s.BridgeAddress == "" && o.dial == nil {
+			s.BridgeAddress = ns.InProcess
+			o.dial = s.NetworkServer.Dial
+		}
+	}
+
+	s.Forwarder = *f.Setup()
+	s.Forwarder.Capture = &s.Resources.Capture
+
+	s.Resources.Capture.Enable = o.pcap
+	s.Resources.Dial = o.dial
+
+	s.Forwarder.FrameLog = &s.Resources.FrameLog
+	s.Resources.FrameLog.Keys = s.deviceKeys
+	s.Resources.FrameLog.ABPKeys = s.abpKeys
+	s.Resources.FrameLog.Publish = s.publishFrame
+	s.Resources.FrameLog.Path = s.frameLogPath()
+
+	s.Resources.Tracer.Enable = o.tracing.Enable
+	s.Resources.Tracer.Endpoint = o.tracing.Endpoint
+	s.Forwarder.Tracer = &s.Resources.Tracer
+
+	s.Console = c.Console{}
+	s.Console.Subscribe(&s.Events)
+
+	if o.metrics != nil {
+		metrics.Subscribe(&s.Events, *o.metrics)
+	}
+
+	s.MQTT = o.mqtt
+	s.Downlinks = integration.NewDownlinks(o.downlinks, o.mqtt)
+	s.Downlinks.Subscribe(&s.Events)
+
+	s.Delivery = integration.NewDelivery(o.delivery)
+	s.Delivery.Subscribe(&s.Events)
+
+	s.Notifications, err = integration.NewNotifications(o.webhooks)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Notifications.Subscribe(&s.Events)
+
+	if s.MQTT != nil {
+		s.MQTT.Subscribe(s.MQTT.Topic("devices", "+", "uplink"), s.mqttUplink)
+	}
+
+	for _, g := range o.gateways {
+		if _, _, err := s.SetGateway(g, false); err != nil {
+			return nil, fmt.Errorf("gateway %v: %w", g.Info.Name, err)
+		}
+	}
+
+	for _, d := range o.devices {
+		if _, _, err := s.SetDevice(d, false); err != nil {
+			return nil, fmt.Errorf("device %v: %w", d.Info.Name, err)
+		}
+	}
+
+	return &s, nil
+}
@@ -51,6 +51,25 @@ func (p *BufferUplink) Pop() packets.RXPK {
 
 }
 
+// PopAll pops, without waiting, at most max uplinks
+func (p *BufferUplink) PopAll(max int) []packets.RXPK {
+
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	n := len(p.Uplinks)
+	if n > max {
+		n = max
+	}
+
+	upls := make([]packets.RXPK, n)
+	copy(upls, p.Uplinks[:n])
+	p.Uplinks = p.Uplinks[n:]
+
+	return upls
+
+}
+
 func (p *BufferUplink) Signal() {
 
 	p.Mutex.Lock()
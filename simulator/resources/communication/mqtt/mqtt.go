@@ -0,0 +1,553 @@
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+)
+
+// MQTT 3.1.1 control packets
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPuback     = 0x40
+	packetSubscribe  = 0x82 // with the reserved flags
+	packetSuback     = 0x90
+	packetPingreq    = 0xC0
+	packetDisconnect = 0xE0
+)
+
+const (
+	protocolLevel     = 4
+	keepAlive         = 60 * time.Second
+	dialTimeout       = 10 * time.Second
+	writeTimeout      = 5 * time.Second // a stalled broker doesn't block the publishers longer
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ErrNotConnected is returned by Publish while the client is not connected to the broker
+var ErrNotConnected = errors.New("MQTT broker not connected")
+
+// Handler receives the payload of a message published on topic, it is called in the
+// goroutine of the connection and must not block
+type Handler func(topic string, payload []byte)
+
+// Client is a MQTT 3.1.1 client with QoS 0, it reconnects to the broker and subscribes
+// again until it is stopped
+type Client struct {
+	Mutex         sync.Mutex
+	config        models.MQTT
+	conn          net.Conn
+	subscriptions map[string]Handler
+	packetId      uint16
+	exit          chan struct{}
+	failing       bool // only the first error of a streak is logged
+}
+
+// New returns a client of the broker of config, not connected before Start
+func New(config models.MQTT) *Client {
+
+	if config.ClientID == "" {
+		config.ClientID = "lwnsimulator"
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = "lwnsimulator"
+	}
+
+	if config.Username == "" && config.Password != "" {
+		log.Println("[MQTT] [ERROR]: password without username, not sent to the broker")
+	}
+
+	return &Client{
+		config:        config,
+		subscriptions: make(map[string]Handler),
+	}
+}
+
+// Topic joins the prefix of the topics and levels, eg. lwnsimulator/devices/<devEUI>/downlink
+func (c *Client) Topic(levels ...string) string {
+	return strings.Join(append([]string{c.config.Prefix}, levels...), "/")
+}
+
+// Start connects to the broker in the background, and again when the connection is lost
+func (c *Client) Start() {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.exit != nil {
+		return
+	}
+
+	c.exit = make(chan struct{})
+
+	go c.run(c.exit)
+}
+
+// Stop disconnects from the broker
+func (c *Client) Stop() {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.exit == nil {
+		return
+	}
+
+	close(c.exit)
+	c.exit = nil
+
+	if c.conn != nil {
+		c.conn.Write([]byte{packetDisconnect, 0})
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// Publish sends payload on topic, without waiting for the broker
+func (c *Client) Publish(topic string, payload []byte) error {
+
+	body := appendString(nil, topic)
+	body = append(body, payload...)
+
+	return c.write(packetPublish, body)
+}
+
+// Subscribe calls handler with the messages on the topics of filter, which can hold the
+// wildcards + and #. The subscription is kept across the reconnections
+func (c *Client) Subscribe(filter string, handler Handler) error {
+
+	c.Mutex.Lock()
+	c.subscriptions[filter] = handler
+	c.Mutex.Unlock()
+
+	err := c.subscribe(filter)
+	if err == ErrNotConnected { //sent on connection
+		return nil
+	}
+
+	return err
+}
+
+func (c *Client) subscribe(filter string) error {
+
+	c.Mutex.Lock()
+	c.packetId++
+	if c.packetId == 0 {
+		c.packetId++
+	}
+	id := c.packetId
+	c.Mutex.Unlock()
+
+	body := []byte{byte(id >> 8), byte(id)}
+	body = appendString(body, filter)
+	body = append(body, 0) //QoS 0
+
+	return c.write(packetSubscribe, body)
+}
+
+func (c *Client) write(header byte, body []byte) error {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	_, err := c.conn.Write(encodePacket(header, body))
+	if err != nil { //a packet may be cut, the connection is opened again by run
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	return err
+}
+
+func (c *Client) run(exit chan struct{}) {
+
+	delay := time.Second
+
+	for {
+
+		conn, err := c.connect()
+		if err == nil {
+
+			c.logError(nil)
+			delay = time.Second
+
+			err = c.serve(conn, exit)
+		}
+
+		select {
+		case <-exit:
+			return
+		default:
+		}
+
+		c.logError(err)
+
+		select {
+		case <-exit:
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+
+}
+
+func (c *Client) logError(err error) {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if err == nil {
+
+		if c.failing {
+			log.Println("[MQTT]: connected to", c.config.Broker)
+		}
+
+		c.failing = false
+		return
+	}
+
+	if !c.failing {
+		log.Println("[MQTT] [ERROR]:", err.Error())
+	}
+
+	c.failing = true
+}
+
+// connect opens the connection and waits for the CONNACK of the broker
+func (c *Client) connect() (net.Conn, error) {
+
+	address := c.config.Broker
+	secure := false
+
+	switch {
+	case strings.HasPrefix(address, "tls://"), strings.HasPrefix(address, "ssl://"), strings.HasPrefix(address, "mqtts://"):
+		secure = true
+		address = address[strings.Index(address, "://")+3:]
+	case strings.HasPrefix(address, "tcp://"), strings.HasPrefix(address, "mqtt://"):
+		address = address[strings.Index(address, "://")+3:]
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	var conn net.Conn
+	var err error
+
+	if secure {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	_, err = conn.Write(connectPacket(c.config))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	header, ack, err := readPacket(bufio.NewReader(conn))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if header&0xF0 != packetConnack || len(ack) < 2 {
+		conn.Close()
+		return nil, errors.New("CONNACK expected")
+	}
+
+	if ack[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused by the broker, code %v", ack[1])
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+// connectPacket returns the CONNECT of config, with a clean session. The password is sent
+// only with a username, as MQTT 3.1.1 requires
+func connectPacket(config models.MQTT) []byte {
+
+	seconds := uint16(keepAlive / time.Second)
+
+	flags := byte(0x02) //clean session
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel, 0, byte(seconds>>8), byte(seconds))
+	body = appendString(body, config.ClientID)
+
+	if config.Username != "" {
+
+		flags |= 0x80
+		body = appendString(body, config.Username)
+
+		if config.Password != "" {
+			flags |= 0x40
+			body = appendString(body, config.Password)
+		}
+
+	}
+
+	body[7] = flags //after the protocol name and level
+
+	return encodePacket(packetConnect, body)
+}
+
+// serve reads the packets of conn until it is lost or the client stopped
+func (c *Client) serve(conn net.Conn, exit chan struct{}) error {
+
+	c.Mutex.Lock()
+
+	select {
+	case <-exit:
+		c.Mutex.Unlock()
+		conn.Close()
+		return nil
+	default:
+	}
+
+	c.conn = conn
+
+	var filters []string
+	for filter := range c.subscriptions {
+		filters = append(filters, filter)
+	}
+
+	c.Mutex.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	defer func() {
+
+		c.Mutex.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.Mutex.Unlock()
+
+		conn.Close()
+	}()
+
+	for _, filter := range filters {
+		if err := c.subscribe(filter); err != nil {
+			return err
+		}
+	}
+
+	go c.ping(done)
+
+	reader := bufio.NewReader(conn)
+
+	for {
+
+		conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+
+		header, body, err := readPacket(reader)
+		if err != nil {
+			return err
+		}
+
+		switch header & 0xF0 {
+
+		case packetPublish:
+			c.received(header, body)
+
+		case packetSuback:
+			if len(body) > 2 && body[2] == 0x80 {
+				log.Println("[MQTT] [ERROR]: subscription refused by the broker")
+			}
+
+		}
+	}
+
+}
+
+// received hands a PUBLISH to the handlers of the matching subscriptions
+func (c *Client) received(header byte, body []byte) {
+
+	topic, rest, err := readString(body)
+	if err != nil {
+		return
+	}
+
+	qos := (header >> 1) & 0x03
+	if qos > 0 {
+
+		if len(rest) < 2 {
+			return
+		}
+
+		if qos == 1 {
+			c.write(packetPuback, rest[:2])
+		}
+
+		rest = rest[2:]
+	}
+
+	c.Mutex.Lock()
+
+	var handlers []Handler
+	for filter, handler := range c.subscriptions {
+		if Match(filter, topic) {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	c.Mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(topic, rest)
+	}
+
+}
+
+func (c *Client) ping(done chan struct{}) {
+
+	ticker := time.NewTicker(keepAlive / 2)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.write(packetPingreq, nil)
+		}
+
+	}
+
+}
+
+// Match reports whether topic matches filter, with its wildcards + and #
+func Match(filter string, topic string) bool {
+
+	filters := strings.Split(filter, "/")
+	levels := strings.Split(topic, "/")
+
+	for i, f := range filters {
+
+		if f == "#" {
+			return true
+		}
+
+		if i >= len(levels) {
+			return false
+		}
+
+		if f != "+" && f != levels[i] {
+			return false
+		}
+	}
+
+	return len(filters) == len(levels)
+}
+
+func encodePacket(header byte, body []byte) []byte {
+
+	packet := []byte{header}
+
+	length := len(body)
+	for {
+
+		b := byte(length % 128)
+		length /= 128
+
+		if length > 0 {
+			b |= 0x80
+		}
+
+		packet = append(packet, b)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	return append(packet, body...)
+}
+
+func readPacket(reader *bufio.Reader) (byte, []byte, error) {
+
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := 0
+	for multiplier, i := 1, 0; ; multiplier, i = multiplier*128, i+1 {
+
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length += int(b&0x7F) * multiplier
+
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+
+	_, err = io.ReadFull(reader, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return header, body, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+
+	if len(b) < 2 {
+		return "", nil, errors.New("malformed string")
+	}
+
+	n := int(b[0])<<8 | int(b[1])
+	if len(b) < 2+n {
+		return "", nil, errors.New("malformed string")
+	}
+
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
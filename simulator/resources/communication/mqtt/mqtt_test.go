@@ -0,0 +1,234 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		length     int
+		wantLength []byte // remaining length encoded
+	}{
+		{"empty", 0, []byte{0x00}},
+		{"one byte", 127, []byte{0x7F}},
+		{"two bytes", 128, []byte{0x80, 0x01}},
+		{"two bytes max", 16383, []byte{0xFF, 0x7F}},
+		{"three bytes", 16384, []byte{0x80, 0x80, 0x01}},
+		{"four bytes", 2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			body := bytes.Repeat([]byte{0xAB}, tt.length)
+
+			packet := encodePacket(packetPublish, body)
+			if packet[0] != packetPublish || !bytes.Equal(packet[1:1+len(tt.wantLength)], tt.wantLength) {
+				t.Fatalf("header % x, want %x % x", packet[:1+len(tt.wantLength)], packetPublish, tt.wantLength)
+			}
+
+			header, got, err := readPacket(bufio.NewReader(bytes.NewReader(packet)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if header != packetPublish || !bytes.Equal(got, body) {
+				t.Errorf("read %x with %v bytes, want %x with %v bytes", header, len(got), packetPublish, len(body))
+			}
+
+		})
+	}
+
+}
+
+func TestReadPacketMalformed(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		packet []byte
+	}{
+		{"empty", nil},
+		{"no length", []byte{packetPublish}},
+		{"length over 4 bytes", []byte{packetPublish, 0x80, 0x80, 0x80, 0x80, 0x01}},
+		{"body cut", []byte{packetPublish, 0x05, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := readPacket(bufio.NewReader(bytes.NewReader(tt.packet))); err == nil {
+				t.Error("error expected")
+			}
+		})
+	}
+
+}
+
+func TestMatch(t *testing.T) {
+
+	tests := []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{"a/b/c", "a/b/c", true},
+		{"a/b/c", "a/b/d", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/+/c", "a/b/c/d", false},
+		{"a/+", "a", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"#", "a/b", true},
+		{"lwnsimulator/devices/+/uplink", "lwnsimulator/devices/0102030405060708/uplink", true},
+		{"lwnsimulator/devices/+/uplink", "lwnsimulator/devices/0102030405060708/downlink", false},
+		{"a/b", "a/b/c", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter+" "+tt.topic, func(t *testing.T) {
+			if got := Match(tt.filter, tt.topic); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestConnectPacket(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		config    models.MQTT
+		wantFlags byte
+		wantRest  []string // client id, username, password
+	}{
+		{"anonymous", models.MQTT{ClientID: "sim"}, 0x02, []string{"sim"}},
+		{"username", models.MQTT{ClientID: "sim", Username: "user"}, 0x82, []string{"sim", "user"}},
+		{"username and password", models.MQTT{ClientID: "sim", Username: "user", Password: "secret"}, 0xC2,
+			[]string{"sim", "user", "secret"}},
+		{"password without username", models.MQTT{ClientID: "sim", Password: "secret"}, 0x02, []string{"sim"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			header, body, err := readPacket(bufio.NewReader(bytes.NewReader(connectPacket(tt.config))))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if header != packetConnect {
+				t.Fatalf("header %x, want %x", header, packetConnect)
+			}
+
+			protocol, rest, err := readString(body)
+			if err != nil || protocol != "MQTT" || len(rest) < 4 {
+				t.Fatalf("protocol %q, %v", protocol, err)
+			}
+
+			if rest[0] != protocolLevel || rest[1] != tt.wantFlags {
+				t.Errorf("level %v, flags %#x, want %v, %#x", rest[0], rest[1], protocolLevel, tt.wantFlags)
+			}
+
+			if keepAlive := int(rest[2])<<8 | int(rest[3]); keepAlive != 60 {
+				t.Errorf("keep alive %v, want 60", keepAlive)
+			}
+
+			var fields []string
+			for rest = rest[4:]; len(rest) > 0; {
+
+				var field string
+				if field, rest, err = readString(rest); err != nil {
+					t.Fatal(err)
+				}
+
+				fields = append(fields, field)
+			}
+
+			if strings.Join(fields, ",") != strings.Join(tt.wantRest, ",") {
+				t.Errorf("payload %q, want %q", fields, tt.wantRest)
+			}
+
+		})
+	}
+
+}
+
+func TestPublishAndReceive(t *testing.T) {
+
+	c := New(models.MQTT{})
+	client, broker := net.Pipe()
+	defer broker.Close()
+
+	c.conn = client
+
+	received := make(chan string, 1)
+	c.subscriptions["lwnsimulator/devices/+/uplink"] = func(topic string, payload []byte) {
+		received <- topic + " " + string(payload)
+	}
+
+	go c.Publish(c.Topic("devices", "01", "downlink"), []byte("down"))
+
+	header, body, err := readPacket(bufio.NewReader(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic, payload, err := readString(body)
+	if header != packetPublish || err != nil || topic != "lwnsimulator/devices/01/downlink" || string(payload) != "down" {
+		t.Errorf("PUBLISH %x %q %q, %v", header, topic, payload, err)
+	}
+
+	c.received(packetPublish, append(appendString(nil, "lwnsimulator/devices/02/uplink"), "up"...))
+	c.received(packetPublish, append(appendString(nil, "lwnsimulator/devices/02/other"), "other"...))
+
+	select {
+	case got := <-received:
+		if got != "lwnsimulator/devices/02/uplink up" {
+			t.Errorf("handler called with %q", got)
+		}
+	default:
+		t.Error("handler not called")
+	}
+
+	if len(received) > 0 {
+		t.Errorf("handler called for a topic not subscribed")
+	}
+
+}
+
+func TestPublishStalledBroker(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("waits for the write timeout")
+	}
+
+	c := New(models.MQTT{})
+	client, broker := net.Pipe() //nothing read on the broker side
+	defer broker.Close()
+
+	c.conn = client
+
+	start := time.Now()
+
+	if err := c.Publish("topic", []byte("payload")); err == nil {
+		t.Fatal("error expected")
+	}
+
+	if elapsed := time.Since(start); elapsed > writeTimeout+time.Second {
+		t.Errorf("Publish blocked %v", elapsed)
+	}
+
+	if err := c.Publish("topic", nil); err != ErrNotConnected {
+		t.Errorf("Publish after the timeout = %v, want %v", err, ErrNotConnected)
+	}
+
+}
@@ -0,0 +1,89 @@
+package packets
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultPreambleLoRa = 8
+	DefaultPreambleFSK  = 5
+)
+
+// TimeOnAir returns the duration of the transmission of a frame of size bytes
+func TimeOnAir(modu string, datr string, codr string, size int, preamble int, crc bool) (time.Duration, error) {
+
+	if modu == "FSK" {
+
+		datarate, err := strconv.Atoi(datr)
+		if err != nil || datarate <= 0 {
+			return 0, errors.New("Invalid FSK datarate")
+		}
+
+		if preamble == 0 {
+			preamble = DefaultPreambleFSK
+		}
+
+		bytes := preamble + 3 + 1 + size // preamble, sync word, length
+		if crc {
+			bytes += 2
+		}
+
+		return time.Duration(float64(bytes*8) / float64(datarate) * float64(time.Second)), nil
+	}
+
+	var sf, bw int
+	_, err := fmt.Sscanf(datr, "SF%dBW%d", &sf, &bw)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid LoRa datarate %v", datr)
+	}
+
+	cr := 1
+	switch codr {
+	case "4/6":
+		cr = 2
+	case "4/7":
+		cr = 3
+	case "4/8":
+		cr = 4
+	}
+
+	if preamble == 0 {
+		preamble = DefaultPreambleLoRa
+	}
+
+	tSym := math.Pow(2, float64(sf)) / float64(bw*1000) // seconds
+
+	de := 0.0
+	if tSym > 0.016 { //low data rate optimization
+		de = 1
+	}
+
+	crcBits := 0.0
+	if crc {
+		crcBits = 16
+	}
+
+	payloadSymb := math.Ceil((8*float64(size)-4*float64(sf)+28+crcBits)/(4*(float64(sf)-2*de))) * float64(cr+4)
+	payloadSymb = 8 + math.Max(payloadSymb, 0)
+
+	tPreamble := (float64(preamble) + 4.25) * tSym
+
+	return time.Duration((tPreamble + payloadSymb*tSym) * float64(time.Second)), nil
+}
+
+// GetTmst returns the value of the concentrator's internal counter (microseconds) at time t
+func GetTmst(t time.Time) uint32 {
+	return uint32(t.UnixNano() / int64(time.Microsecond))
+}
+
+// TimeFromTmst returns the time at which the concentrator's counter will be (or was) tmst
+func TimeFromTmst(tmst uint32, now time.Time) time.Time {
+
+	diff := int32(tmst - GetTmst(now)) //handles the roll-over of the counter
+
+	return now.Add(time.Duration(diff) * time.Microsecond)
+}
@@ -0,0 +1,75 @@
+package packets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeOnAir(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		modu     string
+		datr     string
+		codr     string
+		size     int
+		preamble int
+		crc      bool
+		want     time.Duration
+		wantErr  bool
+	}{
+		{"SF7BW125", "LORA", "SF7BW125", "4/5", 20, 0, true, 56576 * time.Microsecond, false},
+		{"SF7BW125 without CRC", "LORA", "SF7BW125", "4/5", 20, 0, false, 51456 * time.Microsecond, false},
+		{"SF7BW125 4/8", "LORA", "SF7BW125", "4/8", 20, 0, true, 78080 * time.Microsecond, false},
+		{"SF7BW250", "LORA", "SF7BW250", "4/5", 20, 0, true, 28288 * time.Microsecond, false},
+		{"SF10BW125", "LORA", "SF10BW125", "4/5", 51, 0, true, 616448 * time.Microsecond, false},
+		{"SF12BW125 low data rate optimization", "LORA", "SF12BW125", "4/5", 20, 0, true, 1318912 * time.Microsecond, false},
+		{"longer preamble", "LORA", "SF7BW125", "4/5", 20, 10, true, 58624 * time.Microsecond, false},
+		{"FSK", "FSK", "50000", "", 20, 0, true, 4960 * time.Microsecond, false},
+		{"invalid LoRa datarate", "LORA", "SF7", "4/5", 20, 0, true, 0, true},
+		{"invalid FSK datarate", "FSK", "fast", "", 20, 0, true, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := TimeOnAir(tt.modu, tt.datr, tt.codr, tt.size, tt.preamble, tt.crc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+
+			if diff := got - tt.want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("TimeOnAir = %v, want %v", got, tt.want)
+			}
+
+		})
+	}
+
+}
+
+func TestTimeFromTmst(t *testing.T) {
+
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		delay time.Duration
+	}{
+		{"now", 0},
+		{"in the future", time.Second},
+		{"in the past", -time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got := TimeFromTmst(GetTmst(now.Add(tt.delay)), now)
+
+			if diff := got.Sub(now.Add(tt.delay)); diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("TimeFromTmst is %v from the expected time", diff)
+			}
+
+		})
+	}
+
+}
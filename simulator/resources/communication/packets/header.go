@@ -36,6 +36,16 @@ func GetHeader(IDPacket uint8, GatewayMACAddr lorawan.EUI64, token uint16) []byt
 	return header.MarshalBinary()
 }
 
+// GetToken returns the random token of a packet
+func GetToken(packet []byte) uint16 {
+
+	if len(packet) < 3 {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint16(packet[1:3])
+}
+
 func (h *Header) MarshalBinary() []byte {
 
 	out := make([]byte, 4, SizeHeader)
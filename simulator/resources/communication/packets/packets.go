@@ -149,7 +149,7 @@ func ParseReceivePacket(p Packet) error {
 	return nil
 }
 
-func CreatePacket(id int, GatewayMACAddr lorawan.EUI64, stat Stat, info []RXPK, token uint16) ([]byte, error) {
+func CreatePacket(id int, GatewayMACAddr lorawan.EUI64, stat *Stat, info []RXPK, token uint16) ([]byte, error) {
 
 	switch id {
 
@@ -158,7 +158,7 @@ func CreatePacket(id int, GatewayMACAddr lorawan.EUI64, stat Stat, info []RXPK,
 	case TypePullData:
 		return CreatePullDataPacket(GatewayMACAddr), nil
 	case TypeTxAck:
-		return CreateTXPacket(GatewayMACAddr, token)
+		return CreateTXPacket(GatewayMACAddr, token, NONE)
 	default:
 		return []byte{}, nil
 
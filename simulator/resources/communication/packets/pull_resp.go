@@ -59,6 +59,17 @@ func GetInfoPullResp(pullResp []byte) (*lorawan.PHYPayload, *uint32, error) {
 
 }
 
+func GetTXPKPullResp(pullResp []byte) (*TXPK, error) {
+
+	var packet PullRespPacket
+
+	if err := packet.UnmarshalBinary(pullResp); err != nil {
+		return nil, err
+	}
+
+	return &packet.Payload.TXPK, nil
+}
+
 func (p *PullRespPacket) UnmarshalBinary(data []byte) error {
 
 	if len(data) < MinLenPullResp {
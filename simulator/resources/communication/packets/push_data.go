@@ -49,13 +49,14 @@ type RXPK struct {
 	Data      string  `json:"data"` // Base64 encoded RF packet payload, padded
 }
 
-func CreatePushDataPacket(GatewayMACAddr lorawan.EUI64, stat Stat, info []RXPK) ([]byte, error) {
+// CreatePushDataPacket creates a PUSH DATA with the received packets and/or the gateway's status (nil to omit it)
+func CreatePushDataPacket(GatewayMACAddr lorawan.EUI64, stat *Stat, info []RXPK) ([]byte, error) {
 
 	header := GetHeader(TypePushData, GatewayMACAddr, 0)
 
 	payload := PushDataPayload{
 		RXPK: info,
-		Stat: &stat,
+		Stat: stat,
 	}
 
 	pkt := PDPacket{
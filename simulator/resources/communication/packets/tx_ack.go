@@ -30,18 +30,18 @@ type TXPKACK struct {
 	Error string `json:"error"`
 }
 
-func SetTXACKPayload() TXACKPayload {
+func SetTXACKPayload(Error string) TXACKPayload {
 
 	var payload TXACKPayload
-	payload.TXPKACK.Error = NONE
+	payload.TXPKACK.Error = Error
 
 	return payload
 }
 
-func CreateTXPacket(GatewayMACAddr lorawan.EUI64, Token uint16) ([]byte, error) {
+func CreateTXPacket(GatewayMACAddr lorawan.EUI64, Token uint16, Error string) ([]byte, error) {
 
 	header := GetHeader(TypeTxAck, GatewayMACAddr, Token)
-	payload := SetTXACKPayload()
+	payload := SetTXACKPayload(Error)
 	packet := TxAckPacket{
 		header,
 		payload,
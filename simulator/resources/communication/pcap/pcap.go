@@ -0,0 +1,298 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// link types of the interfaces of the capture
+const (
+	LinkTypeRaw     = 101 // IPv4 or IPv6 packet, Semtech UDP datagrams
+	LinkTypeLoRaTap = 270 // LoRaTap header followed by the PHY payload
+
+	InterfaceLoRa     = 0
+	InterfaceBackhaul = 1
+
+	SyncWordLoRaWAN = 0x34 // public network, the LoRaWAN dissector of Wireshark needs it
+)
+
+// encoding of PCAP-NG blocks, LoRaTap and IP headers
+const (
+	blockSectionHeader     = 0x0A0D0D0A
+	blockInterface         = 0x00000001
+	blockEnhancedPacket    = 0x00000006
+	byteOrderMagic         = 0x1A2B3C4D
+	optionEnd              = 0
+	optionInterfaceName    = 2
+	snapLength             = 65535
+	lenLoRaTapHeader       = 15
+	defaultHopLimit        = 64
+	protocolUDP            = 17
+	lenIPv4Header          = 20
+	lenIPv6Header          = 40
+	lenUDPHeader           = 8
+	offsetRSSILoRaTap      = 139 // LoRaTap rssi = value - 139 dBm
+	stepBandwidthLoRaTap   = 125 // LoRaTap bandwidth in 125 kHz steps
+	multiplierSNRLoRaTap   = 4   // LoRaTap snr in 0.25 dB steps
+	interfaceNameLoRa      = "lora"
+	interfaceNameBackhaul  = "backhaul"
+	sectionLengthUndefined = 0xFFFFFFFFFFFFFFFF // -1, the length is not known
+)
+
+// Capture writes the radio frames and the backhaul datagrams of a run in a PCAP-NG file
+type Capture struct {
+	Mutex  sync.Mutex
+	Enable bool   // capture the next runs
+	Path   string // file of the current (or last) capture
+	file   *os.File
+}
+
+// Open creates the file at path, the frames are appended until Close
+func (c *Capture) Open(path string) error {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.file != nil {
+		c.file.Close()
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	header := sectionHeader()
+	header = append(header, interfaceDescription(LinkTypeLoRaTap, interfaceNameLoRa)...)
+	header = append(header, interfaceDescription(LinkTypeRaw, interfaceNameBackhaul)...)
+
+	_, err = file.Write(header)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	c.file = file
+	c.Path = path
+
+	return nil
+}
+
+func (c *Capture) Close() error {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+
+	err := c.file.Close()
+	c.file = nil
+
+	return err
+}
+
+func (c *Capture) IsOpen() bool {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	return c.file != nil
+}
+
+// WriteLoRa records a PHY frame sent on the air at freq (Hz) with the datarate datr (eg. SF7BW125)
+func (c *Capture) WriteLoRa(t time.Time, freq uint32, datr string, rssi int16, snr float64, phy []byte) {
+
+	if c == nil || !c.IsOpen() {
+		return
+	}
+
+	var sf, bw uint32
+	fmt.Sscanf(datr, "SF%dBW%d", &sf, &bw) //FSK frames have no spreading factor
+
+	header := make([]byte, lenLoRaTapHeader)
+
+	header[0] = 0 //version
+	binary.BigEndian.PutUint16(header[2:], lenLoRaTapHeader)
+	binary.BigEndian.PutUint32(header[4:], freq)
+	header[8] = uint8(bw / stepBandwidthLoRaTap)
+	header[9] = uint8(sf)
+	header[10] = uint8(int(rssi) + offsetRSSILoRaTap) //packet rssi
+	header[11] = header[10]                           //max rssi
+	header[12] = header[10]                           //current rssi
+	header[13] = uint8(int8(snr * multiplierSNRLoRaTap))
+	header[14] = SyncWordLoRaWAN
+
+	c.write(InterfaceLoRa, t, append(header, phy...))
+}
+
+// WriteUDP records a datagram between src and dst, encapsulated in an IP header
+func (c *Capture) WriteUDP(t time.Time, src net.Addr, dst net.Addr, payload []byte) {
+
+	if c == nil || !c.IsOpen() {
+		return
+	}
+
+	srcUDP, ok1 := src.(*net.UDPAddr)
+	dstUDP, ok2 := dst.(*net.UDPAddr)
+	if !ok1 || !ok2 {
+		return
+	}
+
+	packet, err := ipPacket(srcUDP, dstUDP, payload)
+	if err != nil {
+		return
+	}
+
+	c.write(InterfaceBackhaul, t, packet)
+}
+
+func (c *Capture) write(iface uint32, t time.Time, data []byte) {
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.file == nil {
+		return
+	}
+
+	c.file.Write(enhancedPacket(iface, t, data))
+}
+
+//*******************************blocks**************************************/
+
+func sectionHeader() []byte {
+
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint32(body[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(body[4:], 1) //major version
+	binary.LittleEndian.PutUint16(body[6:], 0) //minor version
+	binary.LittleEndian.PutUint64(body[8:], sectionLengthUndefined)
+
+	return block(blockSectionHeader, body)
+}
+
+func interfaceDescription(linkType uint16, name string) []byte {
+
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:], linkType)
+	binary.LittleEndian.PutUint32(body[4:], snapLength)
+
+	body = append(body, option(optionInterfaceName, []byte(name))...)
+	body = append(body, option(optionEnd, nil)...)
+
+	return block(blockInterface, body)
+}
+
+func enhancedPacket(iface uint32, t time.Time, data []byte) []byte {
+
+	ts := uint64(t.UnixNano() / int64(time.Microsecond)) //default resolution
+
+	body := make([]byte, 20)
+	binary.LittleEndian.PutUint32(body[0:], iface)
+	binary.LittleEndian.PutUint32(body[4:], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(body[16:], uint32(len(data)))
+
+	body = append(body, pad(data)...)
+
+	return block(blockEnhancedPacket, body)
+}
+
+func option(code uint16, value []byte) []byte {
+
+	opt := make([]byte, 4)
+	binary.LittleEndian.PutUint16(opt[0:], code)
+	binary.LittleEndian.PutUint16(opt[2:], uint16(len(value)))
+
+	return append(opt, pad(value)...)
+}
+
+// block adds type and total length around body, body is already 32-bit aligned
+func block(blockType uint32, body []byte) []byte {
+
+	length := uint32(len(body) + 12)
+
+	b := make([]byte, length)
+	binary.LittleEndian.PutUint32(b[0:], blockType)
+	binary.LittleEndian.PutUint32(b[4:], length)
+	copy(b[8:], body)
+	binary.LittleEndian.PutUint32(b[length-4:], length)
+
+	return b
+}
+
+func pad(data []byte) []byte {
+
+	padded := append([]byte{}, data...)
+	for len(padded)%4 != 0 {
+		padded = append(padded, 0)
+	}
+
+	return padded
+}
+
+//*******************************IP/UDP**************************************/
+
+func ipPacket(src *net.UDPAddr, dst *net.UDPAddr, payload []byte) ([]byte, error) {
+
+	udp := make([]byte, lenUDPHeader)
+	binary.BigEndian.PutUint16(udp[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:], uint16(lenUDPHeader+len(payload)))
+	// checksum 0: not computed
+
+	udp = append(udp, payload...)
+
+	src4, dst4 := src.IP.To4(), dst.IP.To4()
+
+	if src4 != nil && dst4 != nil {
+
+		ip := make([]byte, lenIPv4Header)
+		ip[0] = 0x45 //version 4, 5 words
+		binary.BigEndian.PutUint16(ip[2:], uint16(lenIPv4Header+len(udp)))
+		ip[8] = defaultHopLimit
+		ip[9] = protocolUDP
+		copy(ip[12:], src4)
+		copy(ip[16:], dst4)
+		binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+
+		return append(ip, udp...), nil
+	}
+
+	src6, dst6 := src.IP.To16(), dst.IP.To16()
+	if src6 == nil || dst6 == nil {
+		return nil, errors.New("Invalid IP address")
+	}
+
+	ip := make([]byte, lenIPv6Header)
+	ip[0] = 0x60 //version 6
+	binary.BigEndian.PutUint16(ip[4:], uint16(len(udp)))
+	ip[6] = protocolUDP
+	ip[7] = defaultHopLimit
+	copy(ip[8:], src6)
+	copy(ip[24:], dst6)
+
+	return append(ip, udp...), nil
+}
+
+func checksum(header []byte) uint16 {
+
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i:]))
+	}
+
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+
+	return ^uint16(sum)
+}
@@ -0,0 +1,361 @@
+package udp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/pcap"
+)
+
+// DefaultReorderDelay is the extra delay of a reordered datagram when none is configured
+const DefaultReorderDelay = 100 * time.Millisecond
+
+// Impairment describes the degradation of the link between a gateway and the network server
+type Impairment struct {
+	Latency      time.Duration `json:"-"`           // fixed delay added to every datagram
+	Jitter       time.Duration `json:"-"`           // random delay in [0, Jitter) added to every datagram
+	Loss         float64       `json:"loss"`        // percentage of datagrams dropped
+	Duplication  float64       `json:"duplication"` // percentage of datagrams delivered twice
+	Reordering   float64       `json:"reordering"`  // percentage of datagrams held back of ReorderDelay
+	ReorderDelay time.Duration `json:"-"`
+	Outages      []Outage      `json:"outages"` // windows in which the backhaul is down
+}
+
+// Outage is a window, relative to the gateway's turn on, in which every datagram is lost
+type Outage struct {
+	Start    time.Duration `json:"-"`
+	Duration time.Duration `json:"-"`
+}
+
+// IsZero reports if the impairment leaves the backhaul intact
+func (i *Impairment) IsZero() bool {
+	return i.Latency == 0 && i.Jitter == 0 && i.Loss == 0 && i.Duplication == 0 &&
+		i.Reordering == 0 && i.ReorderDelay == 0 && len(i.Outages) == 0
+}
+
+// Validate checks that the delays are not negative and the percentages are within 0-100
+func (i *Impairment) Validate() error {
+
+	if i.Latency < 0 || i.Jitter < 0 || i.ReorderDelay < 0 {
+		return errors.New("latency, jitter and reorderDelay: 0 or more expected")
+	}
+
+	percentages := []struct {
+		name  string
+		value float64
+	}{{"loss", i.Loss}, {"duplication", i.Duplication}, {"reordering", i.Reordering}}
+
+	for _, p := range percentages {
+		if p.value < 0 || p.value > 100 {
+			return fmt.Errorf("%v: percentage within 0-100 expected", p.name)
+		}
+	}
+
+	for n, o := range i.Outages {
+		if o.Start < 0 || o.Duration < 0 {
+			return fmt.Errorf("outage %v: start and duration of 0 or more expected", n)
+		}
+	}
+
+	return nil
+}
+
+// Backhaul applies an Impairment to the datagrams exchanged by a gateway
+type Backhaul struct {
+	Mutex      sync.Mutex
+	Impairment Impairment
+	Start      time.Time
+	Capture    *pcap.Capture // records the datagrams written on the connection
+	pending    []delayed     // received datagrams held back, by due time
+}
+
+// delayed is a received datagram handed to handler at due
+type delayed struct {
+	due     time.Time
+	data    []byte
+	handler func([]byte)
+}
+
+// Setup resets the reference time of the outage windows
+func (b *Backhaul) Setup(impairment Impairment) {
+
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	b.Impairment = impairment
+	b.Start = time.Now()
+	b.pending = nil
+
+}
+
+// SetImpairment changes the impairment while the gateway is running
+func (b *Backhaul) SetImpairment(impairment Impairment) {
+
+	b.Mutex.Lock()
+	b.Impairment = impairment
+	b.Mutex.Unlock()
+
+}
+
+// Send writes data on the connection after applying the impairment (upstream)
+func (b *Backhaul) Send(connection net.Conn, data []byte) (int, error) {
+	return b.SendFunc(connection, data, nil)
+}
+
+// SendFunc is Send, written is called when the first copy of data is written on the
+// connection or, with a zero time, when data is lost
+func (b *Backhaul) SendFunc(connection net.Conn, data []byte, written func(time.Time)) (int, error) {
+
+	if connection == nil {
+		return 0, net.ErrClosed
+	}
+
+	delays := b.schedule()
+	if len(delays) == 1 && delays[0] == 0 {
+
+		n, err := b.write(connection, data)
+		if written != nil {
+			if err != nil {
+				written(time.Time{})
+			} else {
+				written(time.Now())
+			}
+		}
+
+		return n, err
+	}
+
+	if len(delays) == 0 && written != nil {
+		written(time.Time{})
+	}
+
+	packet := append([]byte{}, data...)
+
+	var once sync.Once
+
+	for _, delay := range delays {
+		time.AfterFunc(delay, func() {
+
+			_, err := b.write(connection, packet)
+			if err == nil && written != nil {
+				once.Do(func() { written(time.Now()) })
+			}
+
+		})
+	}
+
+	return len(data), nil // lost or delayed datagrams look sent, as on a real backhaul
+}
+
+func (b *Backhaul) write(connection net.Conn, data []byte) (int, error) {
+
+	n, err := SendDataUDP(connection, data)
+	if err == nil {
+		b.Capture.WriteUDP(time.Now(), connection.LocalAddr(), connection.RemoteAddr(), data)
+	}
+
+	return n, err
+}
+
+// Receive hands data to handler after applying the impairment (downstream). The delayed
+// copies are handed by Deliver, so that every datagram is handled by the receiver of the gateway
+func (b *Backhaul) Receive(data []byte, handler func([]byte)) {
+
+	delays := b.schedule()
+	if len(delays) == 1 && delays[0] == 0 {
+		handler(data)
+		return
+	}
+
+	packet := append([]byte{}, data...)
+	now := time.Now()
+
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	for _, delay := range delays {
+
+		d := delayed{due: now.Add(delay), data: packet, handler: handler}
+
+		i := sort.Search(len(b.pending), func(i int) bool {
+			return b.pending[i].due.After(d.due)
+		})
+
+		b.pending = append(b.pending, delayed{})
+		copy(b.pending[i+1:], b.pending[i:])
+		b.pending[i] = d
+	}
+
+}
+
+// Deliver hands the delayed datagrams that are due to their handler, and returns when the
+// next one is due, zero if none is held back
+func (b *Backhaul) Deliver() time.Time {
+
+	for {
+
+		b.Mutex.Lock()
+
+		if len(b.pending) == 0 {
+			b.Mutex.Unlock()
+			return time.Time{}
+		}
+
+		d := b.pending[0]
+		if d.due.After(time.Now()) {
+			b.Mutex.Unlock()
+			return d.due
+		}
+
+		b.pending = b.pending[1:]
+		b.Mutex.Unlock()
+
+		d.handler(d.data)
+	}
+
+}
+
+// IsDown reports if the backhaul is inside an outage window
+func (b *Backhaul) IsDown() bool {
+
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	return b.isDown(time.Now())
+}
+
+func (b *Backhaul) isDown(now time.Time) bool {
+
+	elapsed := now.Sub(b.Start)
+
+	for _, o := range b.Impairment.Outages {
+		if elapsed >= o.Start && elapsed < o.Start+o.Duration {
+			return true
+		}
+	}
+
+	return false
+}
+
+// schedule returns the delay of every copy of the datagram, none if it is lost
+func (b *Backhaul) schedule() []time.Duration {
+
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	imp := b.Impairment
+
+	if b.isDown(time.Now()) || chance(imp.Loss) {
+		return nil
+	}
+
+	copies := 1
+	if chance(imp.Duplication) {
+		copies = 2
+	}
+
+	delays := make([]time.Duration, copies)
+	for i := range delays {
+
+		delays[i] = imp.Latency
+		if imp.Jitter > 0 {
+			delays[i] += time.Duration(rand.Int63n(int64(imp.Jitter)))
+		}
+
+		if chance(imp.Reordering) {
+
+			if imp.ReorderDelay > 0 {
+				delays[i] += imp.ReorderDelay
+			} else {
+				delays[i] += DefaultReorderDelay
+			}
+
+		}
+
+	}
+
+	return delays
+}
+
+func chance(percentage float64) bool {
+	return percentage > 0 && rand.Float64()*100 < percentage
+}
+
+//*******************************JSON**************************************/
+
+func (i *Impairment) MarshalJSON() ([]byte, error) {
+
+	type Alias Impairment
+
+	return json.Marshal(&struct {
+		Latency      int `json:"latency"`
+		Jitter       int `json:"jitter"`
+		ReorderDelay int `json:"reorderDelay"`
+		*Alias
+	}{
+		Latency:      int(i.Latency / time.Millisecond),
+		Jitter:       int(i.Jitter / time.Millisecond),
+		ReorderDelay: int(i.ReorderDelay / time.Millisecond),
+		Alias:        (*Alias)(i),
+	})
+
+}
+
+func (i *Impairment) UnmarshalJSON(data []byte) error {
+
+	type Alias Impairment
+
+	aux := &struct {
+		Latency      int `json:"latency"`
+		Jitter       int `json:"jitter"`
+		ReorderDelay int `json:"reorderDelay"`
+		*Alias
+	}{
+		Alias: (*Alias)(i),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	i.Latency = time.Duration(aux.Latency) * time.Millisecond
+	i.Jitter = time.Duration(aux.Jitter) * time.Millisecond
+	i.ReorderDelay = time.Duration(aux.ReorderDelay) * time.Millisecond
+
+	return nil
+}
+
+func (o *Outage) MarshalJSON() ([]byte, error) {
+
+	return json.Marshal(&struct {
+		Start    int `json:"start"`
+		Duration int `json:"duration"`
+	}{
+		Start:    int(o.Start / time.Second),
+		Duration: int(o.Duration / time.Second),
+	})
+
+}
+
+func (o *Outage) UnmarshalJSON(data []byte) error {
+
+	aux := struct {
+		Start    int `json:"start"`
+		Duration int `json:"duration"`
+	}{}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	o.Start = time.Duration(aux.Start) * time.Second
+	o.Duration = time.Duration(aux.Duration) * time.Second
+
+	return nil
+}
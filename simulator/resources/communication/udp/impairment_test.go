@@ -0,0 +1,124 @@
+package udp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImpairmentValidate(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		impairment Impairment
+		wantErr    bool
+		wantZero   bool
+	}{
+		{"none", Impairment{}, false, true},
+		{"valid", Impairment{Latency: time.Second, Jitter: time.Millisecond, Loss: 10, Duplication: 100, Reordering: 0.5}, false, false},
+		{"outage", Impairment{Outages: []Outage{{Start: time.Second, Duration: time.Minute}}}, false, false},
+		{"negative latency", Impairment{Latency: -1}, true, false},
+		{"negative reorder delay", Impairment{ReorderDelay: -time.Second}, true, false},
+		{"loss over 100", Impairment{Loss: 101}, true, false},
+		{"negative duplication", Impairment{Duplication: -1}, true, false},
+		{"reordering over 100", Impairment{Reordering: 100.5}, true, false},
+		{"negative outage", Impairment{Outages: []Outage{{Start: -time.Second}}}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if err := tt.impairment.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := tt.impairment.IsZero(); got != tt.wantZero {
+				t.Errorf("IsZero = %v, want %v", got, tt.wantZero)
+			}
+
+		})
+	}
+
+}
+
+func TestBackhaulReceive(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		impairment Impairment
+		wantNow    int // handed at once
+		wantLater  int // handed by Deliver
+	}{
+		{"intact", Impairment{}, 1, 0},
+		{"lost", Impairment{Loss: 100}, 0, 0},
+		{"outage", Impairment{Outages: []Outage{{Duration: time.Hour}}}, 0, 0},
+		{"latency", Impairment{Latency: 20 * time.Millisecond}, 0, 1},
+		{"duplicated", Impairment{Latency: 20 * time.Millisecond, Duplication: 100}, 0, 2},
+		{"reordered", Impairment{Reordering: 100, ReorderDelay: 20 * time.Millisecond}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var b Backhaul
+			b.Setup(tt.impairment)
+
+			handed := 0
+			b.Receive([]byte{1}, func([]byte) { handed++ })
+
+			if handed != tt.wantNow {
+				t.Fatalf("%v handed at once, want %v", handed, tt.wantNow)
+			}
+
+			next := b.Deliver()
+			if tt.wantLater == 0 {
+
+				if !next.IsZero() {
+					t.Errorf("next due at %v, want none", next)
+				}
+
+				return
+			}
+
+			if handed != tt.wantNow || next.IsZero() {
+				t.Fatalf("delayed copy handed before it is due")
+			}
+
+			time.Sleep(time.Until(next))
+			b.Deliver()
+
+			if handed-tt.wantNow != tt.wantLater {
+				t.Errorf("%v handed by Deliver, want %v", handed-tt.wantNow, tt.wantLater)
+			}
+
+		})
+	}
+
+}
+
+func TestBackhaulDeliverOrder(t *testing.T) {
+
+	var b Backhaul
+	b.Setup(Impairment{})
+
+	var order []byte
+	handler := func(data []byte) { order = append(order, data[0]) }
+
+	for _, frame := range []struct {
+		data  byte
+		delay time.Duration
+	}{{1, 30 * time.Millisecond}, {2, 10 * time.Millisecond}, {3, 20 * time.Millisecond}} {
+		b.SetImpairment(Impairment{Latency: frame.delay})
+		b.Receive([]byte{frame.data}, handler)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if next := b.Deliver(); !next.IsZero() {
+		t.Errorf("next due at %v, want none", next)
+	}
+
+	if string(order) != string([]byte{2, 3, 1}) {
+		t.Errorf("handed in the order %v, want [2 3 1]", order)
+	}
+
+}
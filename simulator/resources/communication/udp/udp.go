@@ -4,6 +4,10 @@ import (
 	"net"
 )
 
+// Dialer opens the connection of a gateway with the network server at address,
+// a datagram is read and written at once
+type Dialer func(address string) (net.Conn, error)
+
 func ConnectTo(BridgeAddress string) (*net.UDPConn, error) {
 
 	addressRS, err := net.ResolveUDPAddr("udp", BridgeAddress)
@@ -15,7 +19,18 @@ func ConnectTo(BridgeAddress string) (*net.UDPConn, error) {
 
 }
 
-func SendDataUDP(connection *net.UDPConn, data []byte) (int, error) {
+// Dial is the Dialer over UDP
+func Dial(BridgeAddress string) (net.Conn, error) {
+
+	connection, err := ConnectTo(BridgeAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return connection, nil
+}
+
+func SendDataUDP(connection net.Conn, data []byte) (int, error) {
 
 	return connection.Write(data)
 
@@ -0,0 +1,360 @@
+package framelog
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"time"
+
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/brocaar/lorawan"
+)
+
+// Keys are the keys of a device at the time of the frame
+type Keys struct {
+	DevEUI   lorawan.EUI64
+	DevAddr  lorawan.DevAddr
+	JoinEUI  lorawan.EUI64
+	DevNonce lorawan.DevNonce
+	AppKey   [16]byte
+	NwkSKey  [16]byte
+	AppSKey  [16]byte
+}
+
+// Frame is a record of the frame log
+type Frame struct {
+	Time        time.Time                   `json:"time"`
+	Type        string                      `json:"type"`
+	DevEUI      *lorawan.EUI64              `json:"devEUI,omitempty"`
+	Gateway     lorawan.EUI64               `json:"gateway"`
+	MHDR        lorawan.MHDR                `json:"mhdr"`
+	DevAddr     *lorawan.DevAddr            `json:"devAddr,omitempty"`
+	FCtrl       *lorawan.FCtrl              `json:"fCtrl,omitempty"`
+	FCnt        *uint32                     `json:"fCnt,omitempty"`
+	FPort       *uint8                      `json:"fPort,omitempty"`
+	MACCommands []lorawan.MACCommand        `json:"macCommands,omitempty"`
+	JoinRequest *lorawan.JoinRequestPayload `json:"joinRequest,omitempty"`
+	JoinAccept  *lorawan.JoinAcceptPayload  `json:"joinAccept,omitempty"`
+	Payload     string                      `json:"payload,omitempty"`  // decrypted FRMPayload, hex
+	PHYPayload  string                      `json:"phyPayload"`         // as sent on the air, hex
+	Frequency   uint32                      `json:"frequency"`          // Hz
+	DataRate    string                      `json:"dataRate"`           // eg. SF7BW125
+	CodeRate    string                      `json:"codeRate,omitempty"` // eg. 4/5
+	Channel     *uint16                     `json:"channel,omitempty"`  // IF chain of the gateway, uplink only
+	RSSI        int16                       `json:"rssi"`               // uplink: received, downlink: TX power
+	SNR         float64                     `json:"snr"`                // uplink only
+	Tmst        uint32                      `json:"tmst"`               // counter of the concentrator
+	Airtime     float64                     `json:"airtime"`            // ms
+	Error       string                      `json:"error,omitempty"`    // why the frame is not completely decoded
+}
+
+// Decode returns the record of the frame phy, decoded with the keys of the candidate devices,
+// a downlink is also decoded with those of the devices with its DevAddr
+func (l *FrameLog) Decode(phy []byte, uplink bool, candidates []lorawan.EUI64) Frame {
+
+	frame := Frame{
+		Time:       time.Now(),
+		Type:       TypeDownlink,
+		PHYPayload: hex.EncodeToString(phy),
+	}
+
+	if uplink {
+		frame.Type = TypeUplink
+	}
+
+	keys := l.keys(candidates)
+	if !uplink {
+		keys = append(keys, l.downlinkKeys(phy)...)
+	}
+
+	err := frame.decode(phy, keys)
+	if err != nil {
+		frame.Error = err.Error()
+	}
+
+	if uplink && frame.JoinRequest != nil {
+		l.joinRequested(frame.JoinRequest.DevEUI)
+	}
+
+	if frame.JoinAccept != nil && frame.DevEUI != nil {
+		l.joinAccepted(*frame.DevEUI, frame.JoinAccept.DevAddr)
+	}
+
+	return frame
+}
+
+func (l *FrameLog) keys(devices []lorawan.EUI64) []Keys {
+
+	if l.Keys == nil {
+		return nil
+	}
+
+	var keys []Keys
+	for _, DevEUI := range devices {
+		if k, ok := l.Keys(DevEUI); ok {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// downlinkKeys returns the keys of the devices with the DevAddr of the downlink phy, the ABP
+// ones and the OTAA ones whose join accept was decoded, or of those that sent a join request
+// in the last joinWindow for a join accept
+func (l *FrameLog) downlinkKeys(phy []byte) []Keys {
+
+	if len(phy) == 0 {
+		return nil
+	}
+
+	var mhdr lorawan.MHDR
+	if err := mhdr.UnmarshalBinary(phy[:1]); err != nil {
+		return nil
+	}
+
+	if mhdr.MType == lorawan.JoinAccept {
+		return l.keys(l.joining())
+	}
+
+	var DevAddr lorawan.DevAddr
+	if len(phy) < 5 || DevAddr.UnmarshalBinary(phy[1:5]) != nil {
+		return nil
+	}
+
+	var keys []Keys
+	if l.ABPKeys != nil {
+		keys = l.ABPKeys(DevAddr)
+	}
+
+	l.joinsMutex.Lock()
+	DevEUI, ok := l.sessions[DevAddr]
+	l.joinsMutex.Unlock()
+
+	if ok {
+		keys = append(keys, l.keys([]lorawan.EUI64{DevEUI})...)
+	}
+
+	return keys
+}
+
+func (l *FrameLog) joinRequested(DevEUI lorawan.EUI64) {
+
+	l.joinsMutex.Lock()
+	defer l.joinsMutex.Unlock()
+
+	if l.joins == nil {
+		l.joins = make(map[lorawan.EUI64]time.Time)
+	}
+
+	l.joins[DevEUI] = time.Now()
+}
+
+// joinAccepted moves the OTAA device DevEUI to the DevAddr of its last join accept
+func (l *FrameLog) joinAccepted(DevEUI lorawan.EUI64, DevAddr lorawan.DevAddr) {
+
+	l.joinsMutex.Lock()
+	defer l.joinsMutex.Unlock()
+
+	if l.sessions == nil {
+		l.sessions = make(map[lorawan.DevAddr]lorawan.EUI64)
+		l.devAddrs = make(map[lorawan.EUI64]lorawan.DevAddr)
+	}
+
+	if old, ok := l.devAddrs[DevEUI]; ok && l.sessions[old] == DevEUI {
+		delete(l.sessions, old)
+	}
+
+	delete(l.joins, DevEUI)
+	l.sessions[DevAddr] = DevEUI
+	l.devAddrs[DevEUI] = DevAddr
+}
+
+// joining returns the devices that sent a join request in the last joinWindow
+func (l *FrameLog) joining() []lorawan.EUI64 {
+
+	l.joinsMutex.Lock()
+	defer l.joinsMutex.Unlock()
+
+	var devices []lorawan.EUI64
+	for DevEUI, t := range l.joins {
+
+		if time.Since(t) > joinWindow {
+			delete(l.joins, DevEUI)
+			continue
+		}
+
+		devices = append(devices, DevEUI)
+	}
+
+	return devices
+}
+
+// SetUplink fills the radio fields with the rxpk received by the gateway
+func (f *Frame) SetUplink(gateway lorawan.EUI64, rxpk pkt.RXPK, airtime time.Duration) {
+
+	channel := rxpk.Channel
+
+	f.Gateway = gateway
+	f.Frequency = uint32(math.Round(rxpk.Frequency * 1000000.0))
+	f.DataRate = rxpk.DatR
+	f.CodeRate = rxpk.CodR
+	f.Channel = &channel
+	f.RSSI = rxpk.RSSI
+	f.SNR = rxpk.LSNR
+	f.Tmst = rxpk.Tmst
+	f.Airtime = float64(airtime) / float64(time.Millisecond)
+}
+
+// SetDownlink fills the radio fields with the txpk transmitted by the gateway
+func (f *Frame) SetDownlink(gateway lorawan.EUI64, txpk pkt.TXPK, airtime time.Duration) {
+
+	f.Gateway = gateway
+	f.Frequency = uint32(math.Round(txpk.Freq * 1000000.0))
+	f.DataRate = txpk.DatR
+	f.CodeRate = txpk.CodR
+	f.RSSI = int16(txpk.Powe)
+	f.Tmst = pkt.GetTmst(f.Time)
+	f.Airtime = float64(airtime) / float64(time.Millisecond)
+
+	if txpk.Tmst != nil {
+		f.Tmst = *txpk.Tmst
+	}
+}
+
+func (f *Frame) decode(data []byte, keys []Keys) error {
+
+	var phy lorawan.PHYPayload
+
+	if err := phy.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	f.MHDR = phy.MHDR
+
+	switch phy.MHDR.MType {
+
+	case lorawan.JoinRequest:
+
+		jr, ok := phy.MACPayload.(*lorawan.JoinRequestPayload)
+		if !ok {
+			return errors.New("*JoinRequestPayload expected")
+		}
+
+		f.JoinRequest = jr
+		f.DevEUI = &jr.DevEUI
+
+		return nil
+
+	case lorawan.JoinAccept:
+		return f.decodeJoinAccept(data, keys)
+
+	}
+
+	macPL, ok := phy.MACPayload.(*lorawan.MACPayload)
+	if !ok {
+		return errors.New("*MACPayload expected")
+	}
+
+	fCnt := macPL.FHDR.FCnt
+
+	f.DevAddr = &macPL.FHDR.DevAddr
+	f.FCtrl = &macPL.FHDR.FCtrl
+	f.FCnt = &fCnt
+	f.FPort = macPL.FPort
+
+	if err := phy.DecodeFOptsToMACCommands(); err != nil {
+		return err
+	}
+
+	for _, payload := range macPL.FHDR.FOpts {
+		if cmd, ok := payload.(*lorawan.MACCommand); ok {
+			f.MACCommands = append(f.MACCommands, *cmd)
+		}
+	}
+
+	var device *Keys
+	for i := range keys {
+		if keys[i].DevAddr == macPL.FHDR.DevAddr {
+			device = &keys[i]
+			break
+		}
+	}
+
+	if device == nil {
+		if len(macPL.FRMPayload) > 0 {
+			return errors.New("Unknown DevAddr, payload not decrypted")
+		}
+		return nil
+	}
+
+	f.DevEUI = &device.DevEUI
+
+	if macPL.FPort == nil || len(macPL.FRMPayload) == 0 {
+		return nil
+	}
+
+	if *macPL.FPort == 0 { //MAC commands in the FRMPayload
+
+		if err := phy.DecryptFRMPayload(device.NwkSKey); err != nil {
+			return err
+		}
+
+		if err := phy.DecodeFRMPayloadToMACCommands(); err != nil {
+			return err
+		}
+
+		for _, payload := range macPL.FRMPayload {
+			if cmd, ok := payload.(*lorawan.MACCommand); ok {
+				f.MACCommands = append(f.MACCommands, *cmd)
+			}
+		}
+
+		return nil
+	}
+
+	if err := phy.DecryptFRMPayload(device.AppSKey); err != nil {
+		return err
+	}
+
+	if pl, ok := macPL.FRMPayload[0].(*lorawan.DataPayload); ok {
+		f.Payload = hex.EncodeToString(pl.Bytes)
+	}
+
+	return nil
+}
+
+// decodeJoinAccept finds the device whose AppKey validates the MIC of the join accept
+func (f *Frame) decodeJoinAccept(data []byte, keys []Keys) error {
+
+	for i := range keys {
+
+		var phy lorawan.PHYPayload
+
+		if err := phy.UnmarshalBinary(data); err != nil {
+			return err
+		}
+
+		if err := phy.DecryptJoinAcceptPayload(keys[i].AppKey); err != nil {
+			continue
+		}
+
+		ok, err := phy.ValidateDownlinkJoinMIC(lorawan.JoinRequestType, keys[i].JoinEUI, keys[i].DevNonce, keys[i].AppKey)
+		if err != nil || !ok {
+			continue
+		}
+
+		ja, ok := phy.MACPayload.(*lorawan.JoinAcceptPayload)
+		if !ok {
+			return errors.New("*JoinAcceptPayload expected")
+		}
+
+		f.JoinAccept = ja
+		f.DevEUI = &keys[i].DevEUI
+		f.DevAddr = &ja.DevAddr
+
+		return nil
+	}
+
+	return errors.New("Unknown device, join accept not decrypted")
+}
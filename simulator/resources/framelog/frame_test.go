@@ -0,0 +1,163 @@
+package framelog
+
+import (
+	"testing"
+
+	"github.com/brocaar/lorawan"
+)
+
+var testKeys = Keys{
+	DevEUI:   lorawan.EUI64{1, 2, 3, 4, 5, 6, 7, 8},
+	DevAddr:  lorawan.DevAddr{0x26, 1, 2, 3},
+	JoinEUI:  lorawan.EUI64{8, 7, 6, 5, 4, 3, 2, 1},
+	DevNonce: 7,
+	AppKey:   [16]byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+	NwkSKey:  [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+	AppSKey:  [16]byte{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+}
+
+// newTestLog returns a frame log that only knows the device of testKeys, as ABP with abp
+func newTestLog(abp bool) *FrameLog {
+
+	return &FrameLog{
+		Keys: func(DevEUI lorawan.EUI64) (Keys, bool) {
+			return testKeys, DevEUI == testKeys.DevEUI
+		},
+		ABPKeys: func(DevAddr lorawan.DevAddr) []Keys {
+			if !abp || DevAddr != testKeys.DevAddr {
+				return nil
+			}
+			return []Keys{testKeys}
+		},
+	}
+}
+
+func marshal(t *testing.T, phy lorawan.PHYPayload) []byte {
+
+	t.Helper()
+
+	data, err := phy.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func dataDownlink(t *testing.T, DevAddr lorawan.DevAddr) []byte {
+
+	t.Helper()
+
+	fport := uint8(2)
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: lorawan.UnconfirmedDataDown, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.MACPayload{
+			FHDR:       lorawan.FHDR{DevAddr: DevAddr, FCnt: 1},
+			FPort:      &fport,
+			FRMPayload: []lorawan.Payload{&lorawan.DataPayload{Bytes: []byte{0xca, 0xfe}}},
+		},
+	}
+
+	if err := phy.EncryptFRMPayload(testKeys.AppSKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := phy.SetDownlinkDataMIC(lorawan.LoRaWAN1_0, 0, testKeys.NwkSKey); err != nil {
+		t.Fatal(err)
+	}
+
+	return marshal(t, phy)
+}
+
+func joinRequest(t *testing.T) []byte {
+
+	t.Helper()
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: lorawan.JoinRequest, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.JoinRequestPayload{
+			JoinEUI:  testKeys.JoinEUI,
+			DevEUI:   testKeys.DevEUI,
+			DevNonce: testKeys.DevNonce,
+		},
+	}
+
+	if err := phy.SetUplinkJoinMIC(testKeys.AppKey); err != nil {
+		t.Fatal(err)
+	}
+
+	return marshal(t, phy)
+}
+
+func joinAccept(t *testing.T) []byte {
+
+	t.Helper()
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: lorawan.JoinAccept, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.JoinAcceptPayload{
+			JoinNonce: 1,
+			DevAddr:   testKeys.DevAddr,
+		},
+	}
+
+	if err := phy.SetDownlinkJoinMIC(lorawan.JoinRequestType, testKeys.JoinEUI, testKeys.DevNonce, testKeys.AppKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := phy.EncryptJoinAcceptPayload(testKeys.AppKey); err != nil {
+		t.Fatal(err)
+	}
+
+	return marshal(t, phy)
+}
+
+func TestDecodeDownlink(t *testing.T) {
+
+	known := dataDownlink(t, testKeys.DevAddr)
+
+	tests := []struct {
+		name        string
+		abp         bool
+		uplinks     [][]byte // decoded before the downlink
+		downlinks   [][]byte // decoded before the downlink, after the uplinks
+		downlink    []byte
+		wantDevice  bool
+		wantPayload string
+	}{
+		{"ABP data", true, nil, nil, known, true, "cafe"},
+		{"ABP data of an unknown DevAddr", true, nil, nil, dataDownlink(t, lorawan.DevAddr{0x26, 9, 9, 9}), false, ""},
+		{"join accept after a join request", false, [][]byte{joinRequest(t)}, nil, joinAccept(t), true, ""},
+		{"join accept without a join request", false, nil, nil, joinAccept(t), false, ""},
+		{"OTAA data after the join accept", false, [][]byte{joinRequest(t)}, [][]byte{joinAccept(t)}, known, true, "cafe"},
+		{"OTAA data without a join accept", false, [][]byte{joinRequest(t)}, nil, known, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			l := newTestLog(tt.abp)
+
+			for _, phy := range tt.uplinks {
+				l.Decode(phy, true, []lorawan.EUI64{testKeys.DevEUI})
+			}
+
+			for _, phy := range tt.downlinks {
+				l.Decode(phy, false, nil)
+			}
+
+			frame := l.Decode(tt.downlink, false, nil)
+
+			if found := frame.DevEUI != nil && *frame.DevEUI == testKeys.DevEUI; found != tt.wantDevice {
+				t.Fatalf("device found %v, want %v (error %q)", found, tt.wantDevice, frame.Error)
+			}
+
+			if frame.Payload != tt.wantPayload {
+				t.Errorf("payload %q, want %q", frame.Payload, tt.wantPayload)
+			}
+
+		})
+	}
+
+}
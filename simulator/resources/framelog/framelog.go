@@ -0,0 +1,199 @@
+package framelog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brocaar/lorawan"
+)
+
+const (
+	TypeUplink   = "uplink"
+	TypeDownlink = "downlink"
+
+	maxLineLength = 1024 * 1024
+	joinWindow    = 10 * time.Second // a join accept answers a join request of the last joinWindow
+)
+
+// FrameLog writes a record for each frame on the air in a NDJSON file
+type FrameLog struct {
+	Mutex      sync.Mutex
+	Path       string
+	Keys       func(lorawan.EUI64) (Keys, bool) // keys of a device, to decode its frames
+	ABPKeys    func(lorawan.DevAddr) []Keys     // keys of the ABP devices with a DevAddr, to decode their downlinks
+	Publish    func(Frame)                      // receives each record written, may be nil
+	file       *os.File
+	open       bool
+	joinsMutex sync.Mutex                        // of joins, sessions and devAddrs
+	joins      map[lorawan.EUI64]time.Time       // time of the last join request of the devices, to decode the join accepts
+	sessions   map[lorawan.DevAddr]lorawan.EUI64 // OTAA device of each DevAddr of a join accept decoded
+	devAddrs   map[lorawan.EUI64]lorawan.DevAddr // last DevAddr of each OTAA device
+}
+
+// Filter selects the records of a query, the zero values match every record
+type Filter struct {
+	DevEUI *lorawan.EUI64
+	Type   string
+	From   time.Time
+	To     time.Time
+	Limit  int // only the last Limit records
+}
+
+// Open appends the records of the run to the file at path, without path they are only published
+func (l *FrameLog) Open(path string) error {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+
+	l.Path = path
+	l.open = true
+
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	l.file = file
+
+	return nil
+}
+
+func (l *FrameLog) Close() error {
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	l.open = false
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+
+	return err
+}
+
+func (l *FrameLog) IsOpen() bool {
+
+	if l == nil {
+		return false
+	}
+
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+
+	return l.open
+}
+
+func (l *FrameLog) Write(frame Frame) {
+
+	line, err := json.Marshal(&frame)
+	if err != nil {
+		return
+	}
+
+	l.Mutex.Lock()
+
+	if !l.open {
+		l.Mutex.Unlock()
+		return
+	}
+
+	if l.file != nil {
+		l.file.Write(append(line, '\n'))
+	}
+
+	l.Mutex.Unlock()
+
+	if l.Publish != nil {
+		l.Publish(frame)
+	}
+}
+
+// Query returns the records of the file that match filter, in chronological order
+func (l *FrameLog) Query(filter Filter) ([]json.RawMessage, error) {
+
+	records := []json.RawMessage{}
+
+	l.Mutex.Lock()
+	path := l.Path
+	l.Mutex.Unlock()
+
+	if path == "" {
+		return records, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	for scanner.Scan() {
+
+		var header struct {
+			Time   time.Time      `json:"time"`
+			Type   string         `json:"type"`
+			DevEUI *lorawan.EUI64 `json:"devEUI"`
+		}
+
+		line := scanner.Bytes()
+		if err := json.Unmarshal(line, &header); err != nil {
+			continue //partial last line, still being written
+		}
+
+		if !filter.match(header.Time, header.Type, header.DevEUI) {
+			continue
+		}
+
+		records = append(records, append(json.RawMessage{}, line...))
+
+		if filter.Limit > 0 && len(records) > filter.Limit {
+			records = records[1:]
+		}
+
+	}
+
+	return records, scanner.Err()
+}
+
+func (f *Filter) match(t time.Time, typeFrame string, DevEUI *lorawan.EUI64) bool {
+
+	if f.DevEUI != nil && (DevEUI == nil || *DevEUI != *f.DevEUI) {
+		return false
+	}
+
+	if f.Type != "" && f.Type != typeFrame {
+		return false
+	}
+
+	if !f.From.IsZero() && t.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && t.After(f.To) {
+		return false
+	}
+
+	return true
+}
@@ -3,14 +3,16 @@ package resources
 import (
 	"sync"
 
-	socketio "github.com/googollee/go-socket.io"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/pcap"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
+	"github.com/arslab/lwnsimulator/simulator/resources/tracing"
 )
 
 type Resources struct {
-	ExitGroup sync.WaitGroup `json:"-"`
-	WebSocket socketio.Conn  `json:"-"`
-}
-
-func (r *Resources) AddWebSocket(WebSocket *socketio.Conn) {
-	r.WebSocket = *WebSocket
+	ExitGroup sync.WaitGroup    `json:"-"`
+	Capture   pcap.Capture      `json:"-"`
+	FrameLog  framelog.FrameLog `json:"-"`
+	Tracer    tracing.Tracer    `json:"-"`
+	Dial      udp.Dialer        `json:"-"` // connects the gateways with the network server, UDP if nil
 }
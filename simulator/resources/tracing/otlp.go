@@ -0,0 +1,249 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+	maxBatch       = 512
+)
+
+// exporter sends the ended spans to the collector every exportInterval, and once more on exit
+func (t *Tracer) exporter(exit chan struct{}, done chan struct{}) {
+
+	defer close(done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-exit:
+			t.exportAll()
+			return
+		}
+
+		t.exportAll()
+	}
+
+}
+
+func (t *Tracer) exportAll() {
+
+	for {
+
+		t.Mutex.Lock()
+
+		n := len(t.ended)
+		if n > maxBatch {
+			n = maxBatch
+		}
+
+		spans := t.ended[:n]
+		t.ended = t.ended[n:]
+
+		t.Mutex.Unlock()
+
+		if len(spans) == 0 {
+			return
+		}
+
+		err := t.post(spans)
+
+		t.Mutex.Lock()
+		if err != nil && !t.failing { //only the first error of a streak
+			log.Println("[Tracing] [ERROR]:", err.Error())
+		}
+		t.failing = err != nil
+		t.Mutex.Unlock()
+
+	}
+
+}
+
+// post sends spans with the OTLP/HTTP JSON encoding
+func (t *Tracer) post(spans []*Span) error {
+
+	body, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.client.Post(t.Endpoint+"/v1/traces", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %v", resp.Status)
+	}
+
+	return nil
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 as string
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func encodeSpans(spans []*Span) otlpRequest {
+
+	var scope otlpScopeSpans
+	scope.Scope.Name = ServiceName
+
+	for _, s := range spans {
+		scope.Spans = append(scope.Spans, encodeSpan(s))
+	}
+
+	var resource otlpResourceSpans
+	resource.Resource.Attributes = encodeAttributes(map[string]interface{}{
+		"service.name": ServiceName,
+	})
+	resource.ScopeSpans = []otlpScopeSpans{scope}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{resource},
+	}
+}
+
+func encodeSpan(s *Span) otlpSpan {
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.SpanID[:]),
+		Name:              s.Name,
+		Kind:              1, //internal
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.Finish.UnixNano(), 10),
+		Attributes:        encodeAttributes(s.Attributes),
+		Status: otlpStatus{
+			Code:    s.Status,
+			Message: s.Message,
+		},
+	}
+
+	if s.ParentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+	}
+
+	for _, e := range s.Events {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(e.Time.UnixNano(), 10),
+			Name:         e.Name,
+			Attributes:   encodeAttributes(e.Attributes),
+		})
+	}
+
+	return span
+}
+
+// encodeAttributes returns the attributes sorted by key, unsupported types become strings
+func encodeAttributes(attributes map[string]interface{}) []otlpKeyValue {
+
+	var kvs []otlpKeyValue
+
+	for key, value := range attributes {
+
+		var v otlpValue
+
+		switch val := value.(type) {
+		case string:
+			v.StringValue = &val
+		case bool:
+			v.BoolValue = &val
+		case int:
+			i := strconv.FormatInt(int64(val), 10)
+			v.IntValue = &i
+		case int64:
+			i := strconv.FormatInt(val, 10)
+			v.IntValue = &i
+		case uint8:
+			i := strconv.FormatUint(uint64(val), 10)
+			v.IntValue = &i
+		case uint32:
+			i := strconv.FormatUint(uint64(val), 10)
+			v.IntValue = &i
+		case float64:
+			v.DoubleValue = &val
+		default:
+			str := fmt.Sprintf("%v", val)
+			v.StringValue = &str
+		}
+
+		kvs = append(kvs, otlpKeyValue{
+			Key:   key,
+			Value: v,
+		})
+	}
+
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].Key < kvs[j].Key
+	})
+
+	return kvs
+}
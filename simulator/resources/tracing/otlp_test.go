@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+var testStart = time.Unix(1700000000, 123456789)
+
+// testSpans returns an uplink with every type of attribute, an event and an error, and its child
+func testSpans() []*Span {
+
+	uplink := &Span{
+		TraceID: [16]byte{0x0a, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		SpanID:  [8]byte{0x0b, 1, 2, 3, 4, 5, 6, 7},
+		Name:    "device.uplink",
+		Start:   testStart,
+		Finish:  testStart.Add(1500 * time.Millisecond),
+		Attributes: map[string]interface{}{
+			"lora.dev_eui":    "0102030405060708",
+			"lora.confirmed":  true,
+			"lora.fcnt":       uint32(42),
+			"lora.fport":      uint8(2),
+			"lora.gateways":   3,
+			"lora.tmst":       int64(-1),
+			"lora.airtime_ms": 41.216,
+			"lora.rx_delay":   time.Second, //not supported, as a string
+		},
+		Events: []Event{{
+			Time:       testStart.Add(time.Second),
+			Name:       "rx1.open",
+			Attributes: map[string]interface{}{"lora.frequency": 868100000},
+		}},
+		Status:  StatusError,
+		Message: "ACK timeout",
+	}
+
+	forward := &Span{
+		TraceID:  uplink.TraceID,
+		SpanID:   [8]byte{0x0c, 1, 2, 3, 4, 5, 6, 7},
+		ParentID: uplink.SpanID,
+		Name:     "forwarder.fanout",
+		Start:    testStart.Add(time.Millisecond),
+		Finish:   testStart.Add(2 * time.Millisecond),
+	}
+
+	return []*Span{uplink, forward}
+}
+
+// golden returns the content of the golden file name, rewritten with got on -update
+func golden(t *testing.T, name string, got []byte) []byte {
+
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return want
+}
+
+func TestEncodeSpans(t *testing.T) {
+
+	got, err := json.MarshalIndent(encodeSpans(testSpans()), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got = append(got, '\n')
+
+	if want := golden(t, "spans.json", got); !bytes.Equal(got, want) {
+		t.Errorf("encoded spans differ from testdata/spans.json:\n%s", got)
+	}
+
+}
+
+func TestExportOnStop(t *testing.T) {
+
+	type request struct {
+		path        string
+		contentType string
+		body        []byte
+	}
+
+	requests := make(chan request, 4)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.URL.Path, r.Header.Get("Content-Type"), body}
+	}))
+	defer collector.Close()
+
+	tracer := &Tracer{Enable: true, Endpoint: collector.URL}
+	tracer.Start()
+
+	for _, span := range testSpans() {
+		span.tracer = tracer
+		span.EndAt(span.Finish)
+	}
+
+	tracer.Stop()
+
+	indented, err := os.ReadFile(filepath.Join("testdata", "spans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want bytes.Buffer
+	if err := json.Compact(&want, indented); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-requests:
+
+		if r.path != "/v1/traces" || r.contentType != "application/json" {
+			t.Errorf("POST %v %v, want /v1/traces application/json", r.path, r.contentType)
+		}
+
+		if !bytes.Equal(r.body, want.Bytes()) {
+			t.Errorf("body %s\nwant %s", r.body, want.Bytes())
+		}
+
+	default:
+		t.Fatal("no spans exported on Stop")
+	}
+
+	if len(requests) != 0 {
+		t.Errorf("%v more requests, want 1", len(requests))
+	}
+
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+)
+
+// status codes of OTLP
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+// Span is an operation of a trace, its methods do nothing on a nil Span
+type Span struct {
+	Mutex      sync.Mutex
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	Name       string
+	Start      time.Time
+	Finish     time.Time
+	Attributes map[string]interface{}
+	Events     []Event
+	Status     int
+	Message    string
+
+	tracer *Tracer
+	ended  bool
+}
+
+// Event is an instant of a span
+type Event struct {
+	Time       time.Time
+	Name       string
+	Attributes map[string]interface{}
+}
+
+// SetAttribute sets key to value, a string, bool, integer or float
+func (s *Span) SetAttribute(key string, value interface{}) {
+
+	if s == nil {
+		return
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+
+	s.Attributes[key] = value
+}
+
+func (s *Span) AddEvent(name string, attributes map[string]interface{}) {
+
+	if s == nil {
+		return
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	s.Events = append(s.Events, Event{
+		Time:       time.Now(),
+		Name:       name,
+		Attributes: attributes,
+	})
+}
+
+// SetError marks the span as failed with message
+func (s *Span) SetError(message string) {
+
+	if s == nil {
+		return
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	s.Status = StatusError
+	s.Message = message
+}
+
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt ends the span at end and hands it to the exporter, only the first call counts
+func (s *Span) EndAt(end time.Time) {
+
+	if s == nil {
+		return
+	}
+
+	s.Mutex.Lock()
+
+	if s.ended {
+		s.Mutex.Unlock()
+		return
+	}
+
+	s.ended = true
+	s.Finish = end
+
+	s.Mutex.Unlock()
+
+	s.tracer.export(s)
+}
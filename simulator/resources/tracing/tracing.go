@@ -0,0 +1,292 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brocaar/lorawan"
+)
+
+const (
+	// DefaultEndpoint is the OTLP/HTTP receiver of a local collector
+	DefaultEndpoint = "http://localhost:4318"
+
+	// ServiceName is the service.name of the exported spans
+	ServiceName = "lwnsimulator"
+
+	// hopTTL is how long a forwarded uplink waits for its downlink
+	hopTTL = 30 * time.Second
+
+	// maxRXDelay is the largest RX delay, in seconds, of a downlink answering an uplink
+	maxRXDelay = 16
+)
+
+// Tracer records a trace for each uplink, from the device to its receive windows,
+// and exports the spans to an OpenTelemetry collector. A nil or disabled Tracer records nothing
+type Tracer struct {
+	Mutex    sync.Mutex
+	Enable   bool
+	Endpoint string // OTLP/HTTP, eg. http://localhost:4318
+
+	running bool
+	active  map[lorawan.EUI64]*Span // uplink of each device, until its receive windows close
+	hops    map[hopKey]*Hop
+	ended   []*Span // to export
+	flush   chan struct{}
+	exit    chan struct{}
+	done    chan struct{}
+	client  *http.Client
+	failing bool
+}
+
+// Hop is an uplink forwarded by a gateway
+type Hop struct {
+	Mutex    sync.Mutex
+	Trace    *Span
+	Received time.Time // pushed in the buffer of the gateway
+	sent     time.Time // written on the backhaul
+}
+
+type hopKey struct {
+	gateway lorawan.EUI64
+	tmst    uint32
+}
+
+// Start begins to record and export the spans, if enabled
+func (t *Tracer) Start() {
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	if !t.Enable || t.running {
+		return
+	}
+
+	if t.Endpoint == "" {
+		t.Endpoint = DefaultEndpoint
+	}
+
+	t.running = true
+	t.active = make(map[lorawan.EUI64]*Span)
+	t.hops = make(map[hopKey]*Hop)
+	t.ended = nil
+	t.flush = make(chan struct{}, 1)
+	t.exit = make(chan struct{})
+	t.done = make(chan struct{})
+	t.client = &http.Client{Timeout: exportTimeout}
+
+	go t.exporter(t.exit, t.done)
+}
+
+// Stop exports the ended spans and stops the recording
+func (t *Tracer) Stop() {
+
+	t.Mutex.Lock()
+
+	if !t.running {
+		t.Mutex.Unlock()
+		return
+	}
+
+	t.running = false
+	exit, done := t.exit, t.done
+
+	t.Mutex.Unlock()
+
+	close(exit)
+	<-done
+}
+
+func (t *Tracer) IsRunning() bool {
+
+	if t == nil {
+		return false
+	}
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	return t.running
+}
+
+// StartUplink starts the trace of the next uplink of the device DevEUI
+func (t *Tracer) StartUplink(DevEUI lorawan.EUI64, name string) *Span {
+
+	if !t.IsRunning() {
+		return nil
+	}
+
+	span := t.newSpan(name, nil, time.Now())
+	span.SetAttribute("lorawan.dev_eui", DevEUI.String())
+
+	t.Mutex.Lock()
+	t.active[DevEUI] = span
+	t.Mutex.Unlock()
+
+	return span
+}
+
+// Uplink returns the trace of the current uplink of the device DevEUI, nil if none
+func (t *Tracer) Uplink(DevEUI lorawan.EUI64) *Span {
+
+	if t == nil {
+		return nil
+	}
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	return t.active[DevEUI]
+}
+
+// EndUplink ends the trace of the current uplink of the device DevEUI
+func (t *Tracer) EndUplink(DevEUI lorawan.EUI64) {
+
+	if t == nil {
+		return
+	}
+
+	t.Mutex.Lock()
+	span := t.active[DevEUI]
+	delete(t.active, DevEUI)
+	t.Mutex.Unlock()
+
+	span.End()
+}
+
+// StartSpan starts a child of parent, nil without parent
+func (t *Tracer) StartSpan(name string, parent *Span) *Span {
+	return t.StartSpanAt(name, parent, time.Now())
+}
+
+func (t *Tracer) StartSpanAt(name string, parent *Span, start time.Time) *Span {
+
+	if parent == nil || !t.IsRunning() {
+		return nil
+	}
+
+	return t.newSpan(name, parent, start)
+}
+
+// AddHop records that the gateway forwards the uplink of trace with tmst
+func (t *Tracer) AddHop(gateway lorawan.EUI64, tmst uint32, trace *Span) {
+
+	if trace == nil || t == nil {
+		return
+	}
+
+	now := time.Now()
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	if !t.running {
+		return
+	}
+
+	for key, hop := range t.hops {
+		if now.Sub(hop.Received) > hopTTL {
+			delete(t.hops, key)
+		}
+	}
+
+	t.hops[hopKey{gateway, tmst}] = &Hop{
+		Trace:    trace,
+		Received: now,
+	}
+}
+
+// Hop returns the uplink forwarded by the gateway with tmst, nil if not traced
+func (t *Tracer) Hop(gateway lorawan.EUI64, tmst uint32) *Hop {
+
+	if t == nil {
+		return nil
+	}
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	return t.hops[hopKey{gateway, tmst}]
+}
+
+// HopOfDownlink returns the uplink answered by a downlink transmitted at tmst,
+// the network server schedules it a whole number of seconds after the uplink
+func (t *Tracer) HopOfDownlink(gateway lorawan.EUI64, tmst uint32) *Hop {
+
+	if t == nil {
+		return nil
+	}
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	for delay := uint32(1); delay <= maxRXDelay; delay++ {
+		if hop, ok := t.hops[hopKey{gateway, tmst - delay*1000000}]; ok {
+			return hop
+		}
+	}
+
+	return nil
+}
+
+func (h *Hop) SetSent(sent time.Time) {
+
+	if h == nil {
+		return
+	}
+
+	h.Mutex.Lock()
+	h.sent = sent
+	h.Mutex.Unlock()
+}
+
+// Sent returns when the uplink was written on the backhaul, zero if it was not
+func (h *Hop) Sent() time.Time {
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	return h.sent
+}
+
+func (t *Tracer) newSpan(name string, parent *Span, start time.Time) *Span {
+
+	span := Span{
+		tracer: t,
+		Name:   name,
+		Start:  start,
+	}
+
+	rand.Read(span.SpanID[:])
+
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		rand.Read(span.TraceID[:])
+	}
+
+	return &span
+}
+
+// export queues span for the exporter
+func (t *Tracer) export(span *Span) {
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+
+	if !t.running {
+		return
+	}
+
+	t.ended = append(t.ended, span)
+
+	if len(t.ended) >= maxBatch {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+)
+
+const (
+	queueSize     = 256
+	postTimeout   = 10 * time.Second
+	firstRetry    = time.Second
+	maxRetryDelay = time.Minute
+	drainTimeout  = 5 * time.Second // to post the queued bodies on Close
+)
+
+// HeaderSignature holds sha256=<hex of the HMAC-SHA256 of the body> when the webhook has a secret
+const HeaderSignature = "X-Signature-256"
+
+// Webhook posts JSON bodies to URL in its own goroutine, in order, until it is closed. The
+// bodies posted while queueSize are waiting are dropped, so that the publishers never block
+type Webhook struct {
+	Mutex   sync.Mutex
+	URL     string
+	secret  []byte
+	retries int
+	queue   chan []byte
+	client  *http.Client
+	closing chan struct{}   // closed by Close, the queue is drained
+	ctx     context.Context // done when the drain is over, the posts in progress are interrupted
+	cancel  context.CancelFunc
+	once    sync.Once
+	done    chan struct{} // closed when run returns
+	failing bool          // only the first error of a streak is logged
+}
+
+// New returns a webhook of config ready to post, its events are not used
+func New(config models.Webhook) *Webhook {
+
+	w := &Webhook{
+		URL:     config.URL,
+		secret:  []byte(config.Secret),
+		retries: config.Retries,
+		queue:   make(chan []byte, queueSize),
+		client:  &http.Client{Timeout: postTimeout},
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	go w.run()
+
+	return w
+}
+
+// Close stops the webhook: the bodies already queued are posted once, without retries, for
+// at most drainTimeout, then the post in progress is interrupted. It returns once the
+// goroutine of the webhook has returned
+func (w *Webhook) Close() {
+
+	w.once.Do(func() { close(w.closing) })
+
+	select {
+	case <-w.done:
+	case <-time.After(drainTimeout):
+	}
+
+	w.cancel()
+	<-w.done
+}
+
+// Post queues the JSON encoding of v, false if it is dropped
+func (w *Webhook) Post(v interface{}) bool {
+
+	select {
+	case <-w.closing:
+		return false
+	default:
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.logError(err)
+		return false
+	}
+
+	select {
+	case w.queue <- body:
+		return true
+	default:
+		w.logError(fmt.Errorf("queue full, body dropped"))
+		return false
+	}
+
+}
+
+// run posts the bodies until the webhook is closed
+func (w *Webhook) run() {
+
+	defer close(w.done)
+
+	for {
+
+		select {
+		case <-w.closing:
+			w.drain()
+			return
+		case body := <-w.queue:
+			w.send(body)
+		}
+
+	}
+
+}
+
+// drain posts the bodies left in the queue, until the webhook is cancelled
+func (w *Webhook) drain() {
+
+	for w.ctx.Err() == nil {
+
+		select {
+		case body := <-w.queue:
+			if _, err := w.post(body); w.ctx.Err() == nil {
+				w.logError(err)
+			}
+		default:
+			return
+		}
+
+	}
+
+}
+
+// send posts body, again after a delay that doubles at each retry while it fails
+func (w *Webhook) send(body []byte) {
+
+	retry, err := w.post(body)
+
+	delay := firstRetry
+	for i := 0; retry && i < w.retries; i++ {
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-w.closing:
+			timer.Stop()
+			w.logError(err)
+			return
+		case <-timer.C:
+		}
+
+		retry, err = w.post(body)
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+
+	if w.ctx.Err() == nil { //not interrupted by Close
+		w.logError(err)
+	}
+
+}
+
+// post sends body, retry is false when the receiver rejected it
+func (w *Webhook) post(body []byte) (retry bool, err error) {
+
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set(HeaderSignature, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout ||
+			resp.StatusCode == http.StatusTooManyRequests
+
+		return retry, fmt.Errorf("%v answered %v", w.URL, resp.Status)
+	}
+
+	return false, nil
+}
+
+func (w *Webhook) logError(err error) {
+
+	w.Mutex.Lock()
+	defer w.Mutex.Unlock()
+
+	if err != nil && !w.failing {
+		log.Println("[Webhook] [ERROR]:", err.Error())
+	}
+
+	w.failing = err != nil
+}
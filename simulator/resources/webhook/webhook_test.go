@@ -0,0 +1,221 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arslab/lwnsimulator/models"
+)
+
+type received struct {
+	body      []byte
+	signature string
+}
+
+// newReceiver answers the posts with the statuses in turn, the last one once they are used
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, chan received) {
+
+	t.Helper()
+
+	posts := make(chan received, 16)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type %q", r.Header.Get("Content-Type"))
+		}
+
+		posts <- received{body, r.Header.Get(HeaderSignature)}
+
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, posts
+}
+
+func TestSignature(t *testing.T) {
+
+	body := `{"type":"DeviceJoined"}`
+
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"no secret", "", ""},
+		{"secret", "s3cr3t", "sha256=" + sign("s3cr3t", body)},
+		{"other secret", "other", "sha256=" + sign("other", body)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			server, posts := newReceiver(t, http.StatusOK)
+
+			w := New(models.Webhook{URL: server.URL, Secret: tt.secret})
+			defer w.Close()
+
+			if !w.Post(map[string]string{"type": "DeviceJoined"}) {
+				t.Fatal("body dropped")
+			}
+
+			post := wait(t, posts)
+
+			if string(post.body) != body {
+				t.Errorf("body %s, want %s", post.body, body)
+			}
+
+			if post.signature != tt.want {
+				t.Errorf("%v %q, want %q", HeaderSignature, post.signature, tt.want)
+			}
+
+		})
+	}
+
+}
+
+func TestRetries(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		retries   int
+		statuses  []int
+		wantPosts int
+	}{
+		{"accepted", 3, []int{http.StatusOK}, 1},
+		{"retried after an error", 3, []int{http.StatusServiceUnavailable, http.StatusOK}, 2},
+		{"too many requests retried", 3, []int{http.StatusTooManyRequests, http.StatusOK}, 2},
+		{"rejected not retried", 3, []int{http.StatusBadRequest}, 1},
+		{"no retries", 0, []int{http.StatusInternalServerError}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			server, posts := newReceiver(t, tt.statuses...)
+
+			w := New(models.Webhook{URL: server.URL, Retries: tt.retries})
+
+			w.Post("body")
+
+			for i := 0; i < tt.wantPosts; i++ {
+				wait(t, posts)
+			}
+
+			w.Close()
+
+			if n := len(posts); n != 0 {
+				t.Errorf("%v posts more than %v", n, tt.wantPosts)
+			}
+
+		})
+	}
+
+}
+
+func TestClose(t *testing.T) {
+
+	server, posts := newReceiver(t, http.StatusServiceUnavailable)
+
+	w := New(models.Webhook{URL: server.URL, Retries: 10})
+	w.Post("body")
+	wait(t, posts)
+
+	closed := make(chan struct{})
+	go func() {
+		w.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(firstRetry / 2):
+		t.Fatal("Close waits for the retries")
+	}
+
+	if w.Post("after") {
+		t.Error("body queued after Close")
+	}
+
+}
+
+func TestCloseDrain(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		statuses []int
+		posted   []string
+		want     []string // bodies received
+	}{
+		{"queued bodies posted", []int{http.StatusOK}, []string{"started", "stopped"}, []string{`"started"`, `"stopped"`}},
+		{"retry dropped, queued body posted", []int{http.StatusServiceUnavailable, http.StatusOK},
+			[]string{"failing", "stopped"}, []string{`"failing"`, `"stopped"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			server, posts := newReceiver(t, tt.statuses...)
+
+			w := New(models.Webhook{URL: server.URL, Retries: 10})
+
+			for _, body := range tt.posted {
+				w.Post(body)
+			}
+
+			w.Close()
+
+			var got []string
+			for len(posts) > 0 {
+				got = append(got, string((<-posts).body))
+			}
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("received %v, want %v", got, tt.want)
+			}
+
+		})
+	}
+
+}
+
+func sign(secret string, body string) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func wait(t *testing.T, posts chan received) received {
+
+	t.Helper()
+
+	select {
+	case post := <-posts:
+		return post
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing posted")
+	}
+
+	return received{}
+}
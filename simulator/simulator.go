@@ -4,7 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/arslab/lwnsimulator/codes"
@@ -12,8 +13,13 @@ import (
 	f "github.com/arslab/lwnsimulator/simulator/components/forwarder"
 	mfw "github.com/arslab/lwnsimulator/simulator/components/forwarder/models"
 	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	ns "github.com/arslab/lwnsimulator/simulator/components/networkserver"
 	c "github.com/arslab/lwnsimulator/simulator/console"
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/simulator/integration"
 	res "github.com/arslab/lwnsimulator/simulator/resources"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/mqtt"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
 	"github.com/arslab/lwnsimulator/simulator/util"
 	"github.com/arslab/lwnsimulator/socket"
 	"github.com/brocaar/lorawan"
@@ -21,24 +27,35 @@ import (
 
 // Simulator is a model
 type Simulator struct {
-	State                 uint8               `json:"-"`
-	Devices               map[int]*dev.Device `json:"-"`
-	ActiveDevices         map[int]int         `json:"-"`
-	ActiveGateways        map[int]int         `json:"-"`
-	ComponentsInactiveTmp int                 `json:"-"`
-	Gateways              map[int]*gw.Gateway `json:"-"`
-	Forwarder             f.Forwarder         `json:"-"`
-	NextIDDev             int                 `json:"nextIDDev"`
-	NextIDGw              int                 `json:"nextIDGw"`
-	BridgeAddress         string              `json:"bridgeAddress"`
-	Resources             res.Resources       `json:"-"`
-	Console               c.Console           `json:"-"`
+	State                 uint8                      `json:"-"`
+	Devices               map[int]*dev.Device        `json:"-"`
+	ActiveDevices         map[int]int                `json:"-"`
+	ActiveGateways        map[int]int                `json:"-"`
+	ComponentsInactiveTmp int                        `json:"-"`
+	Gateways              map[int]*gw.Gateway        `json:"-"`
+	Forwarder             f.Forwarder                `json:"-"`
+	NextIDDev             int                        `json:"nextIDDev"`
+	NextIDGw              int                        `json:"nextIDGw"`
+	BridgeAddress         string                     `json:"bridgeAddress"`
+	Resources             res.Resources              `json:"-"`
+	Console               c.Console                  `json:"-"`
+	Events                events.Bus                 `json:"-"`
+	NetworkServer         *ns.Server                 `json:"-"` // embedded, nil without
+	MQTT                  *mqtt.Client               `json:"-"` // of the integrations, nil without
+	Downlinks             *integration.Downlinks     `json:"-"`
+	Notifications         *integration.Notifications `json:"-"` // of the lifecycle events to the webhooks
+	Delivery              *integration.Delivery      `json:"-"` // of the uplinks by the application server
+	store                 Store                      // persists the simulator, its devices and its gateways
+	devicesMutex          sync.RWMutex               // of Devices and its indexes, read by the network server, the frame log and MQTT
+	byDevEUI              map[lorawan.EUI64]*dev.Device
+	byDevAddr             map[lorawan.DevAddr]map[lorawan.EUI64]struct{} // of the ABP devices
+	dataDir               string                                         // of the captures and the frame log, none if empty
 }
 
 func (s *Simulator) setup() {
 	s.setupGateways()
 	s.setupDevices()
-	s.SetupConsole()
+	s.SetupEvents()
 
 	s.Print("SETUP OK!", nil, util.PrintBoth)
 }
@@ -71,39 +88,123 @@ func (s *Simulator) setupDevices() {
 	s.Print("Setup devices OK!", nil, util.PrintOnlySocket)
 }
 
-func (s *Simulator) SetupConsole() {
+func (s *Simulator) SetupEvents() {
 	for _, d := range s.Devices {
-		s.Devices[d.Id].SetConsole(&s.Console)
+		s.Devices[d.Id].SetEvents(&s.Events)
 	}
 	for _, g := range s.Gateways {
-		s.Gateways[g.Id].SetConsole(&s.Console)
+		s.Gateways[g.Id].SetEvents(&s.Events)
 	}
 }
 
-func (s *Simulator) loadData() {
+// load recovers the simulator, its devices and its gateways from the store
+func (s *Simulator) load() error {
 
-	path, err := util.GetPath()
+	err := s.store.Load(RecordSimulator, s)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	err = util.RecoverConfigFile(path+"/simulator.json", &s)
+	err = s.store.Load(RecordGateways, &s.Gateways)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	err = util.RecoverConfigFile(path+"/gateways.json", &s.Gateways)
-	if err != nil {
-		log.Fatal(err)
+	s.devicesMutex.Lock()
+	defer s.devicesMutex.Unlock()
+
+	err = s.store.Load(RecordDevices, &s.Devices)
+
+	s.byDevEUI = make(map[lorawan.EUI64]*dev.Device)
+	s.byDevAddr = make(map[lorawan.DevAddr]map[lorawan.EUI64]struct{})
+	for _, d := range s.Devices {
+		s.index(d)
 	}
 
-	err = util.RecoverConfigFile(path+"/devices.json", &s.Devices)
-	if err != nil {
-		log.Fatal(err)
+	return err
+}
+
+// putDevice adds the device d or replaces the one with its id
+func (s *Simulator) putDevice(d *dev.Device) {
+
+	s.devicesMutex.Lock()
+	defer s.devicesMutex.Unlock()
+
+	if old, ok := s.Devices[d.Id]; ok {
+		s.unindex(old)
+	}
+
+	s.Devices[d.Id] = d
+	s.index(d)
+}
+
+// removeDevice deletes the device Id
+func (s *Simulator) removeDevice(Id int) {
+
+	s.devicesMutex.Lock()
+	defer s.devicesMutex.Unlock()
+
+	if d, ok := s.Devices[Id]; ok {
+		s.unindex(d)
+		delete(s.Devices, Id)
 	}
 
 }
 
+// index adds the device d to the indexes, caller must hold devicesMutex. Only the
+// ABP devices are indexed by DevAddr, the one of an OTAA device changes at each join
+func (s *Simulator) index(d *dev.Device) {
+
+	s.byDevEUI[d.Info.DevEUI] = d
+
+	if d.Info.Configuration.SupportedOtaa {
+		return
+	}
+
+	if s.byDevAddr[d.Info.DevAddr] == nil {
+		s.byDevAddr[d.Info.DevAddr] = make(map[lorawan.EUI64]struct{})
+	}
+
+	s.byDevAddr[d.Info.DevAddr][d.Info.DevEUI] = struct{}{}
+}
+
+// unindex removes the device d from the indexes, caller must hold devicesMutex
+func (s *Simulator) unindex(d *dev.Device) {
+
+	delete(s.byDevEUI, d.Info.DevEUI)
+
+	delete(s.byDevAddr[d.Info.DevAddr], d.Info.DevEUI)
+	if len(s.byDevAddr[d.Info.DevAddr]) == 0 {
+		delete(s.byDevAddr, d.Info.DevAddr)
+	}
+
+}
+
+// abpDevices returns the ABP devices with DevAddr, it can be called from any goroutine
+func (s *Simulator) abpDevices(DevAddr lorawan.DevAddr) []*dev.Device {
+
+	s.devicesMutex.RLock()
+	defer s.devicesMutex.RUnlock()
+
+	var devices []*dev.Device
+	for DevEUI := range s.byDevAddr[DevAddr] {
+		devices = append(devices, s.byDevEUI[DevEUI])
+	}
+
+	return devices
+}
+
+// deviceByEUI returns the device DevEUI, it can be called from any goroutine
+func (s *Simulator) deviceByEUI(DevEUI lorawan.EUI64) (*dev.Device, bool) {
+
+	s.devicesMutex.RLock()
+	defer s.devicesMutex.RUnlock()
+
+	d, ok := s.byDevEUI[DevEUI]
+
+	return d, ok
+}
+
 func (s *Simulator) searchName(Name string, Id int, gwFlag bool) (int, error) {
 
 	for _, g := range s.Gateways {
@@ -161,37 +262,47 @@ func (s *Simulator) searchAddress(address lorawan.EUI64, Id int, gwFlag bool) (i
 	return codes.CodeOK, nil
 }
 
-func (s *Simulator) saveComponent(path string, v interface{}) {
+// save persists the records in the store, its errors are printed
+func (s *Simulator) save(records ...string) error {
 
-	bytes, err := json.MarshalIndent(&v, "", "\t")
-	if err != nil {
-		log.Fatal(err)
-	}
+	var first error
 
-	err = util.WriteConfigFile(path, bytes)
-	if err != nil {
-		log.Fatal(err)
-	}
+	for _, record := range records {
 
-}
+		var err error
 
-func (s *Simulator) saveStatus() {
+		switch record {
+		case RecordSimulator:
+			err = s.store.Save(record, s)
+		case RecordDevices:
+			s.devicesMutex.RLock()
+			err = s.store.Save(record, s.Devices)
+			s.devicesMutex.RUnlock()
+		case RecordGateways:
+			err = s.store.Save(record, s.Gateways)
+		}
+
+		if err != nil {
+
+			s.Print("", err, util.PrintBoth)
+
+			if first == nil {
+				first = err
+			}
+
+		}
 
-	pathDir, err := util.GetPath()
-	if err != nil {
-		log.Fatal(err)
 	}
 
-	path := pathDir + "/simulator.json"
-	s.saveComponent(path, &s)
+	return first
+}
 
-	path = pathDir + "/devices.json"
-	s.saveComponent(path, &s.Devices)
+func (s *Simulator) saveStatus() {
 
-	path = pathDir + "/gateways.json"
-	s.saveComponent(path, &s.Gateways)
+	if s.save(RecordSimulator, RecordDevices, RecordGateways) == nil {
+		s.Print("Status saved", nil, util.PrintOnlyConsole)
+	}
 
-	s.Print("Status saved", nil, util.PrintOnlyConsole)
 }
 
 func (s *Simulator) turnONDevice(Id int) {
@@ -203,11 +314,15 @@ func (s *Simulator) turnONDevice(Id int) {
 	}
 	s.Forwarder.AddDevice(infoDev)
 
+	s.Devices[Id].SetEvents(&s.Events)
 	s.Devices[Id].Setup(&s.Resources, &s.Forwarder)
 	s.Devices[Id].TurnON()
 	s.ActiveDevices[Id] = Id
 
-	s.Console.PrintSocket(socket.EventResponseCommand, s.Devices[Id].Info.Name+" Turn ON")
+	s.Events.Publish(&events.DeviceState{
+		Header: events.NewHeader(events.TypeDeviceTurnedOn),
+		Device: s.Devices[Id].Ref(),
+	})
 }
 
 func (s *Simulator) turnOFFDevice(Id int) {
@@ -233,26 +348,39 @@ func (s *Simulator) turnOFFDevice(Id int) {
 		FCnt:     s.Devices[Id].Info.Status.DataUplink.FCnt,
 	}
 
-	s.Console.PrintSocket(socket.EventSaveStatus, status)
+	s.Events.Publish(&events.DeviceState{
+		Header: events.NewHeader(events.TypeDeviceTurnedOff),
+		Device: s.Devices[Id].Ref(),
+		Status: &status,
+	})
+}
+
+func (s *Simulator) infoGateway(Id int) mfw.InfoGateway {
+
+	return mfw.InfoGateway{
+		MACAddress:   s.Gateways[Id].Info.MACAddress,
+		Buffer:       &s.Gateways[Id].BufferUplink,
+		Location:     s.Gateways[Id].Info.Location,
+		Concentrator: s.Gateways[Id].Info.Concentrator,
+		JIT:          &s.Gateways[Id].JIT,
+	}
 
-	s.Console.PrintSocket(socket.EventResponseCommand, s.Devices[Id].Info.Name+" Turn OFF")
 }
 
 func (s *Simulator) turnONGateway(Id int) {
-	infoGw := mfw.InfoGateway{
-		MACAddress: s.Gateways[Id].Info.MACAddress,
-		Buffer:     &s.Gateways[Id].BufferUplink,
-		Location:   s.Gateways[Id].Info.Location,
-	}
 
-	s.Forwarder.AddGateway(infoGw)
+	s.Forwarder.AddGateway(s.infoGateway(Id))
 
+	s.Gateways[Id].SetEvents(&s.Events)
 	s.Gateways[Id].Setup(&s.BridgeAddress, &s.Resources, &s.Forwarder)
 	s.Gateways[Id].TurnON()
 
 	s.ActiveGateways[Id] = Id
 
-	s.Console.PrintSocket(socket.EventResponseCommand, s.Gateways[Id].Info.Name+" Turn ON")
+	s.Events.Publish(&events.GatewayState{
+		Header:  events.NewHeader(events.TypeGatewayTurnedOn),
+		Gateway: s.Gateways[Id].Ref(),
+	})
 }
 
 func (s *Simulator) turnOFFGateway(Id int) {
@@ -267,14 +395,12 @@ func (s *Simulator) turnOFFGateway(Id int) {
 	delete(s.ActiveGateways, Id)
 	s.ComponentsInactiveTmp--
 
-	infoGw := mfw.InfoGateway{
-		Buffer:   &s.Gateways[Id].BufferUplink,
-		Location: s.Gateways[Id].Info.Location,
-	}
-
-	s.Forwarder.DeleteGateway(infoGw)
+	s.Forwarder.DeleteGateway(s.infoGateway(Id))
 
-	s.Console.PrintSocket(socket.EventResponseCommand, s.Gateways[Id].Info.Name+" Turn OFF")
+	s.Events.Publish(&events.GatewayState{
+		Header:  events.NewHeader(events.TypeGatewayTurnedOff),
+		Gateway: s.Gateways[Id].Ref(),
+	})
 }
 
 func (s *Simulator) reset() {
@@ -294,34 +420,226 @@ func (s *Simulator) reset() {
 
 }
 
+// openCapture starts a new PCAP-NG file for the run in the pcap directory
+func (s *Simulator) openCapture() error {
+
+	if s.dataDir == "" {
+		err := errors.New("No data directory for the capture")
+		s.Print("", err, util.PrintBoth)
+		return err
+	}
+
+	pathDir := s.dataDir + "/pcap"
+
+	err := util.CreateConfigDir(pathDir)
+	if err != nil {
+		s.Print("", err, util.PrintBoth)
+		return err
+	}
+
+	path := fmt.Sprintf("%v/run-%v.pcapng", pathDir, time.Now().Format("20060102-150405"))
+
+	err = s.Resources.Capture.Open(path)
+	if err != nil {
+		s.Print("", err, util.PrintBoth)
+		return err
+	}
+
+	s.Print("Capture in "+path, nil, util.PrintBoth)
+
+	return nil
+}
+
+func (s *Simulator) closeCapture() {
+
+	if !s.Resources.Capture.IsOpen() {
+		return
+	}
+
+	err := s.Resources.Capture.Close()
+	if err != nil {
+		s.Print("", err, util.PrintBoth)
+		return
+	}
+
+	s.Print("Capture saved in "+s.Resources.Capture.Path, nil, util.PrintOnlyConsole)
+}
+
+// frameLogPath is empty without data directory, the frames are then only published
+func (s *Simulator) frameLogPath() string {
+
+	if s.dataDir == "" {
+		return ""
+	}
+
+	return s.dataDir + "/frames.ndjson"
+}
+
+// mqttUplink sends the uplink published on <prefix>/devices/<devEUI>/uplink, whose body
+// can omit the DevEUI
+func (s *Simulator) mqttUplink(topic string, payload []byte) {
+
+	var uplink integration.Uplink
+
+	err := json.Unmarshal(payload, &uplink)
+	if err == nil {
+
+		var DevEUI lorawan.EUI64
+		levels := strings.Split(topic, "/")
+
+		err = DevEUI.UnmarshalText([]byte(levels[len(levels)-2]))
+		if err == nil && uplink.DevEUI != (lorawan.EUI64{}) && uplink.DevEUI != DevEUI {
+			err = errors.New("DevEUI of the topic expected")
+		}
+
+		uplink.DevEUI = DevEUI
+	}
+
+	if err == nil {
+		_, _, err = s.SendExternalUplink(uplink)
+	}
+
+	if err != nil {
+		s.Print("", fmt.Errorf("uplink of %v: %v", topic, err), util.PrintBoth)
+	}
+
+}
+
+// deviceKeys returns the keys of the device DevEUI to decode its frames
+func (s *Simulator) deviceKeys(DevEUI lorawan.EUI64) (framelog.Keys, bool) {
+
+	d, ok := s.deviceByEUI(DevEUI)
+	if !ok {
+		return framelog.Keys{}, false
+	}
+
+	return framelog.Keys{
+		DevEUI:   d.Info.DevEUI,
+		DevAddr:  d.Info.DevAddr,
+		JoinEUI:  d.Info.JoinEUI,
+		DevNonce: d.Info.DevNonce,
+		AppKey:   d.Info.AppKey,
+		NwkSKey:  d.Info.NwkSKey,
+		AppSKey:  d.Info.AppSKey,
+	}, true
+}
+
+// abpKeys returns the keys of the ABP devices with DevAddr for the frame log
+func (s *Simulator) abpKeys(DevAddr lorawan.DevAddr) []framelog.Keys {
+
+	var keys []framelog.Keys
+	for _, d := range s.abpDevices(DevAddr) {
+		if k, ok := s.deviceKeys(d.Info.DevEUI); ok {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// networkDevice returns the keys of the device DevEUI for the embedded network server
+func (s *Simulator) networkDevice(DevEUI lorawan.EUI64) (ns.Device, bool) {
+
+	d, ok := s.deviceByEUI(DevEUI)
+	if !ok {
+		return ns.Device{}, false
+	}
+
+	return toNetworkDevice(d), true
+}
+
+// networkABPDevices returns the keys of the ABP devices with DevAddr for the embedded network server
+func (s *Simulator) networkABPDevices(DevAddr lorawan.DevAddr) []ns.Device {
+
+	var devices []ns.Device
+
+	for _, d := range s.abpDevices(DevAddr) {
+		devices = append(devices, toNetworkDevice(d))
+	}
+
+	return devices
+}
+
+func toNetworkDevice(d *dev.Device) ns.Device {
+
+	device := ns.Device{
+		DevEUI:      d.Info.DevEUI,
+		OTAA:        d.Info.Configuration.SupportedOtaa,
+		AppKey:      d.Info.AppKey,
+		DevAddr:     d.Info.DevAddr,
+		NwkSKey:     d.Info.NwkSKey,
+		AppSKey:     d.Info.AppSKey,
+		FCntDown:    d.Info.Status.FCntDown,
+		RX1DROffset: d.Info.Configuration.RX1DROffset,
+	}
+
+	if d.Info.Configuration.Region != nil {
+		device.Region = d.Info.Configuration.Region.GetCode()
+	}
+
+	if len(d.Info.RX) > 1 {
+		device.RX1Delay = d.Info.RX[0].Delay
+		device.RX2DataRate = d.Info.RX[1].DataRate
+	}
+
+	return device
+}
+
+// printNetwork logs a line of the embedded network server
+func (s *Simulator) printNetwork(content string, err error) {
+
+	messageLog := ""
+
+	if err == nil {
+		messageLog = fmt.Sprintf("[NS]: %s", content)
+	} else {
+		messageLog = fmt.Sprintf("[NS] [ERROR]: %s", err)
+	}
+
+	s.Events.Publish(&events.Log{
+		Header:  events.NewHeader(events.TypeLog),
+		Source:  events.SourceNetwork,
+		Name:    "NS",
+		Message: messageLog,
+		Error:   err != nil,
+		Output:  util.PrintBoth,
+	})
+}
+
+func (s *Simulator) publishFrame(frame framelog.Frame) {
+
+	s.Events.Publish(&events.Frame{
+		Header: events.NewHeader(events.TypeFrame),
+		Frame:  frame,
+	})
+}
+
 func (s *Simulator) Print(content string, err error, printType int) {
 
-	now := time.Now()
-	message := ""
 	messageLog := ""
-	event := socket.EventLog
 
 	if err == nil {
-		message = fmt.Sprintf("[ %s ] [SIM]: %s", now.Format(time.Stamp), content)
 		messageLog = fmt.Sprintf("[SIM]: %s", content)
 	} else {
-		message = fmt.Sprintf("[ %s ] [SIM] [ERROR]: %s", now.Format(time.Stamp), err)
 		messageLog = fmt.Sprintf("[SIM] [ERROR]: %s", err)
-		event = socket.EventError
 	}
 
-	data := socket.ConsoleLog{
-		Name: "SIM",
-		Msg:  message,
-	}
+	s.Events.Publish(&events.Log{
+		Header:  events.NewHeader(events.TypeLog),
+		Source:  events.SourceSimulator,
+		Name:    "SIM",
+		Message: messageLog,
+		Error:   err != nil,
+		Output:  printType,
+	})
+}
+
+// respond answers a command of the web UI
+func (s *Simulator) respond(message string) {
+
+	s.Events.Publish(&events.CommandResponse{
+		Header:  events.NewHeader(events.TypeCommandResponse),
+		Message: message,
+	})
 
-	switch printType {
-	case util.PrintBoth:
-		s.Console.PrintSocket(event, data)
-		s.Console.PrintLog(messageLog)
-	case util.PrintOnlySocket:
-		s.Console.PrintSocket(event, data)
-	case util.PrintOnlyConsole:
-		s.Console.PrintLog(messageLog)
-	}
 }
@@ -0,0 +1,267 @@
+package simulator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	"github.com/arslab/lwnsimulator/simulator/components/device/features"
+	"github.com/arslab/lwnsimulator/simulator/components/device/features/channels"
+	up "github.com/arslab/lwnsimulator/simulator/components/device/frames/uplink"
+	devm "github.com/arslab/lwnsimulator/simulator/components/device/models"
+	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
+	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	gwm "github.com/arslab/lwnsimulator/simulator/components/gateway/models"
+	pkt "github.com/arslab/lwnsimulator/simulator/resources/communication/packets"
+	"github.com/arslab/lwnsimulator/simulator/resources/location"
+	"github.com/brocaar/lorawan"
+)
+
+var testLocation = location.Location{Latitude: 41.9, Longitude: 12.5}
+
+// testDevice returns an ABP device of EU868 that sends an unconfirmed uplink every second
+func testDevice(name string, DevEUI lorawan.EUI64, DevAddr lorawan.DevAddr) *dev.Device {
+
+	fport := uint8(1)
+
+	return &dev.Device{
+		Info: devm.InformationDevice{
+			Name:     name,
+			DevEUI:   DevEUI,
+			DevAddr:  DevAddr,
+			NwkSKey:  [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			AppSKey:  [16]byte{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+			Location: testLocation,
+			Status: devm.Status{
+				Active:     true,
+				DataUplink: up.InfoUplink{FPort: &fport},
+				MType:      lorawan.UnconfirmedDataUp,
+				Payload:    &lorawan.DataPayload{Bytes: []byte{1, 2, 3}},
+			},
+			Configuration: devm.Configuration{
+				Region:          rp.GetRegionalParameters(rp.Code_Eu868),
+				SendInterval:    time.Second,
+				AckTimeout:      2 * time.Second,
+				Range:           10000,
+				DataRateInitial: 5,
+			},
+			RX: []features.Window{
+				{Delay: time.Second, DurationOpen: 100 * time.Millisecond},
+				{
+					Channel:      channels.Channel{FrequencyDownlink: 869525000},
+					Delay:        2 * time.Second,
+					DurationOpen: 100 * time.Millisecond,
+				},
+			},
+		},
+	}
+}
+
+func testGateway(name string, MACAddress lorawan.EUI64) *gw.Gateway {
+
+	return &gw.Gateway{
+		Info: gwm.InfoGateway{
+			Active:     true,
+			Name:       name,
+			MACAddress: MACAddress,
+			Location:   testLocation,
+			KeepAlive:  5 * time.Second,
+		},
+	}
+}
+
+// testNetwork is an in-process network server that acknowledges the datagrams of the
+// gateways and records the gateways and the DevAddr of the uplinks they forward
+type testNetwork struct {
+	mutex    sync.Mutex
+	gateways map[lorawan.EUI64]struct{}
+	uplinks  map[lorawan.DevAddr]int
+}
+
+func newTestNetwork() *testNetwork {
+	return &testNetwork{
+		gateways: make(map[lorawan.EUI64]struct{}),
+		uplinks:  make(map[lorawan.DevAddr]int),
+	}
+}
+
+// dial is the transport of the gateways
+func (n *testNetwork) dial(address string) (net.Conn, error) {
+
+	gateway, server := net.Pipe()
+	go n.serve(server)
+
+	return gateway, nil
+}
+
+func (n *testNetwork) serve(connection net.Conn) {
+
+	defer connection.Close()
+
+	buffer := make([]byte, 65535)
+
+	for {
+
+		size, err := connection.Read(buffer)
+		if err != nil {
+			return //closed by the gateway
+		}
+
+		if size < 12 {
+			continue
+		}
+
+		datagram := buffer[:size]
+		ack := []byte{datagram[0], datagram[1], datagram[2], pkt.TypePushAck}
+
+		switch datagram[3] {
+		case pkt.TypePushData:
+			n.record(datagram)
+		case pkt.TypePullData:
+			ack[3] = pkt.TypePullAck
+		default:
+			continue
+		}
+
+		connection.Write(ack)
+	}
+
+}
+
+func (n *testNetwork) record(pushData []byte) {
+
+	var body struct {
+		RXPK []struct {
+			Data string `json:"data"`
+		} `json:"rxpk"`
+	}
+
+	json.Unmarshal(pushData[12:], &body)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var MACAddress lorawan.EUI64
+	copy(MACAddress[:], pushData[4:12])
+	n.gateways[MACAddress] = struct{}{}
+
+	for _, rxpk := range body.RXPK {
+
+		phy, err := base64.StdEncoding.DecodeString(rxpk.Data)
+		if err != nil {
+			continue
+		}
+
+		var payload lorawan.PHYPayload
+		if payload.UnmarshalBinary(phy) != nil {
+			continue
+		}
+
+		if mac, ok := payload.MACPayload.(*lorawan.MACPayload); ok {
+			n.uplinks[mac.FHDR.DevAddr]++
+		}
+
+	}
+
+}
+
+// seen returns the gateways and the DevAddr of the uplinks received
+func (n *testNetwork) seen() ([]lorawan.EUI64, []lorawan.DevAddr) {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var gateways []lorawan.EUI64
+	for MACAddress := range n.gateways {
+		gateways = append(gateways, MACAddress)
+	}
+
+	var devAddrs []lorawan.DevAddr
+	for DevAddr := range n.uplinks {
+		devAddrs = append(devAddrs, DevAddr)
+	}
+
+	return gateways, devAddrs
+}
+
+// TestIndependentSimulators runs two simulators in the same process, each connected to its
+// network server in-process, which must only receive the gateway and the device of its simulator
+func TestIndependentSimulators(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("runs the devices for a few seconds")
+	}
+
+	tests := []struct {
+		name    string
+		gateway lorawan.EUI64
+		DevEUI  lorawan.EUI64
+		DevAddr lorawan.DevAddr
+	}{
+		{"first", lorawan.EUI64{1, 1, 1, 1, 1, 1, 1, 1}, lorawan.EUI64{1, 0, 0, 0, 0, 0, 0, 1}, lorawan.DevAddr{0x26, 0, 0, 1}},
+		{"second", lorawan.EUI64{2, 2, 2, 2, 2, 2, 2, 2}, lorawan.EUI64{2, 0, 0, 0, 0, 0, 0, 2}, lorawan.DevAddr{0x26, 0, 0, 2}},
+	}
+
+	sims := make([]*Simulator, len(tests))
+	networks := make([]*testNetwork, len(tests))
+
+	for i, tt := range tests {
+
+		network := newTestNetwork()
+
+		sim, err := New(
+			WithBridgeAddress("network-server:1700"),
+			WithTransport(network.dial),
+			WithGateways(testGateway(tt.name+" gateway", tt.gateway)),
+			WithDevices(testDevice(tt.name+" device", tt.DevEUI, tt.DevAddr)),
+		)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+
+		sim.Run()
+
+		sims[i] = sim
+		networks[i] = network
+	}
+
+	defer func() { //in parallel, each waits for the receive windows of its device
+		var wg sync.WaitGroup
+		for _, sim := range sims {
+			wg.Add(1)
+			go func(sim *Simulator) {
+				defer wg.Done()
+				sim.Stop()
+			}(sim)
+		}
+		wg.Wait()
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for i, tt := range tests {
+
+		var gateways []lorawan.EUI64
+		var devAddrs []lorawan.DevAddr
+
+		for ; time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+			if gateways, devAddrs = networks[i].seen(); len(devAddrs) > 0 {
+				break
+			}
+		}
+
+		if len(gateways) != 1 || gateways[0] != tt.gateway {
+			t.Errorf("%v: gateways %v, want %v", tt.name, gateways, tt.gateway)
+		}
+
+		if len(devAddrs) != 1 || devAddrs[0] != tt.DevAddr {
+			t.Errorf("%v: uplinks of %v, want %v", tt.name, devAddrs, tt.DevAddr)
+		}
+
+	}
+
+}
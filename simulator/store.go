@@ -0,0 +1,102 @@
+package simulator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/arslab/lwnsimulator/simulator/util"
+)
+
+// records of a Store
+const (
+	RecordSimulator = "simulator" // next ids and bridge address
+	RecordDevices   = "devices"
+	RecordGateways  = "gateways"
+)
+
+// Store persists the simulator, its devices and its gateways between the runs
+type Store interface {
+	Load(record string, v interface{}) error // leaves v unchanged if the record was never saved
+	Save(record string, v interface{}) error
+}
+
+// FileStore saves each record in a JSON file of Dir, as the web server does
+type FileStore struct {
+	Dir string
+}
+
+func (fs *FileStore) Load(record string, v interface{}) error {
+
+	data, err := ioutil.ReadFile(fs.path(record))
+	if err != nil {
+
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (fs *FileStore) Save(record string, v interface{}) error {
+
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = util.CreateConfigDir(fs.Dir)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteConfigFile(fs.path(record), data)
+}
+
+func (fs *FileStore) path(record string) string {
+	return filepath.Join(fs.Dir, record+".json")
+}
+
+// MemoryStore keeps the records in memory, the default of New. They are encoded as
+// in the files, so that a loaded record does not share memory with the saved one
+type MemoryStore struct {
+	Mutex   sync.Mutex
+	records map[string][]byte
+}
+
+func (ms *MemoryStore) Load(record string, v interface{}) error {
+
+	ms.Mutex.Lock()
+	data, ok := ms.records[record]
+	ms.Mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (ms *MemoryStore) Save(record string, v interface{}) error {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ms.Mutex.Lock()
+	defer ms.Mutex.Unlock()
+
+	if ms.records == nil {
+		ms.records = make(map[string][]byte)
+	}
+
+	ms.records[record] = data
+
+	return nil
+}
@@ -5,6 +5,7 @@ const (
 	EventError              = "console-error"
 	EventDev                = "log-dev"
 	EventGw                 = "log-gw"
+	EventConnectionGw       = "connection-gw"
 	EventToggleStateDevice  = "toggleState-dev"
 	EventToggleStateGateway = "toggleState-gw"
 	EventSaveStatus         = "save-status"
@@ -13,5 +14,8 @@ const (
 	EventChangePayload      = "change-payload"
 	EventSendUplink         = "send-uplink"
 	EventChangeLocation     = "change-location"
+	EventChangeLocationGw   = "change-location-gw"
 	EventGetParameters      = "get-regional-parameters"
+	EventSubscribe          = "subscribe"
+	EventLiveStateDev       = "live-state-dev"
 )
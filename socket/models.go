@@ -1,6 +1,10 @@
 package socket
 
-import "github.com/brocaar/lorawan"
+import (
+	"time"
+
+	"github.com/brocaar/lorawan"
+)
 
 type ConsoleLog struct {
 	Name string `json:"name"`
@@ -16,6 +20,15 @@ type NewStatusDev struct {
 	FCnt     uint32          `json:"fcnt"`
 }
 
+type LinkGw struct {
+	Id            int     `json:"id"`
+	Name          string  `json:"name"`
+	State         string  `json:"state"`
+	LastAck       string  `json:"lastAck,omitempty"`
+	Reconnections uint32  `json:"reconnections"`
+	ACKR          float64 `json:"ackr"`
+}
+
 type NewPayload struct {
 	Id      int    `json:"id"`
 	MType   string `json:"mtype"`
@@ -31,6 +44,74 @@ type NewLocation struct {
 
 type MacCommand struct {
 	Id          int    `json:"id"`
-	CID         string `json:"cid"`
+	CID         string `json:"cid"` // name of an uplink MAC command, or number for a raw command
 	Periodicity uint8  `json:"periodicity"`
+	Payload     string `json:"payload"` // hex, without the CID
+	FPort0      bool   `json:"fPort0"`  // in the payload of an uplink on FPort 0 rather than in FOpts
+}
+
+// Subscription selects the devices and gateways whose events a client receives, all if empty
+type Subscription struct {
+	Devices  []int `json:"devices"`
+	Gateways []int `json:"gateways"`
+}
+
+// LiveStateDev is the runtime state of a device, the session keys are empty without the admin role
+type LiveStateDev struct {
+	Id          int              `json:"id"`
+	Name        string           `json:"name"`
+	DevEUI      lorawan.EUI64    `json:"devEUI"`
+	Active      bool             `json:"active"` // turned on
+	Joined      bool             `json:"joined"`
+	Mode        string           `json:"mode"` // eg. Normal, Retransmission
+	Class       string           `json:"class"`
+	DevAddr     lorawan.DevAddr  `json:"devAddr"`
+	NwkSKey     string           `json:"nwkSKey,omitempty"`
+	AppSKey     string           `json:"appSKey,omitempty"`
+	FCntUp      uint32           `json:"fCntUp"` // of the next uplink
+	FCntDown    uint32           `json:"fCntDown"`
+	DataRate    uint8            `json:"dataRate"`
+	TXPower     uint8            `json:"txPower"` // index of the region
+	Channels    []LiveChannel    `json:"channels"`
+	ChannelMask []int            `json:"channelMask"` // indexes of the channels enabled for the uplinks
+	RX1         LiveWindow       `json:"rx1"`
+	RX2         LiveWindow       `json:"rx2"`
+	RX1DROffset uint8            `json:"rx1DROffset"`
+	ADR         LiveADR          `json:"adr"`
+	MACCommands []LiveMACCommand `json:"macCommands"` // answers and requests sent with the next uplink
+	Uplinks     []LiveUplink     `json:"uplinks"`     // queued, sent before the periodic payload
+	LastUplink  *time.Time       `json:"lastUplink,omitempty"`
+}
+
+type LiveChannel struct {
+	Index             int    `json:"index"`
+	Active            bool   `json:"active"`
+	EnableUplink      bool   `json:"enableUplink"`
+	FrequencyUplink   uint32 `json:"freqUplink"`
+	FrequencyDownlink uint32 `json:"freqDownlink"`
+	MinDR             uint8  `json:"minDR"`
+	MaxDR             uint8  `json:"maxDR"`
+}
+
+type LiveWindow struct {
+	Frequency    uint32 `json:"frequency"` // Hz, 0 in RX1 for the frequency of the uplink
+	DataRate     uint8  `json:"dataRate"`
+	Delay        int    `json:"delay"`        // ms
+	DurationOpen int    `json:"durationOpen"` // ms
+}
+
+type LiveADR struct {
+	Enable    bool `json:"enable"`
+	ADRACKCnt int8 `json:"adrAckCnt"`
+	ADRACKReq bool `json:"adrAckReq"`
+}
+
+type LiveMACCommand struct {
+	CID     uint8  `json:"cid"`
+	Payload string `json:"payload"` // hex, without the CID
+}
+
+type LiveUplink struct {
+	MType   string `json:"mtype"`
+	Payload string `json:"payload"` // hex
 }
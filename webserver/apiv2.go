@@ -0,0 +1,1260 @@
+package webserver
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arslab/lwnsimulator/codes"
+	"github.com/arslab/lwnsimulator/models"
+	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	mup "github.com/arslab/lwnsimulator/simulator/components/device/frames/uplink/models"
+	mac "github.com/arslab/lwnsimulator/simulator/components/device/macCommands"
+	devm "github.com/arslab/lwnsimulator/simulator/components/device/models"
+	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	ns "github.com/arslab/lwnsimulator/simulator/components/networkserver"
+	"github.com/arslab/lwnsimulator/simulator/integration"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	loc "github.com/arslab/lwnsimulator/simulator/resources/location"
+	"github.com/arslab/lwnsimulator/socket"
+	"github.com/brocaar/lorawan"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const apiV2 = "/api/v2"
+
+// apiError is the body of the failed requests of the API v2
+type apiError struct {
+	Error  string       `json:"error"`
+	Code   int          `json:"code,omitempty"` // of the simulator, see the codes package
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"` // path in the body, eg. info.devEUI
+	Message string `json:"message"`
+}
+
+type simulationState struct {
+	Running bool `json:"running"`
+}
+
+type componentState struct {
+	Active bool `json:"active"` // turned on
+}
+
+type uplinkBody struct {
+	MType   string `json:"mtype" binding:"omitempty,oneof=ConfirmedDataUp UnConfirmedDataUp"`
+	Payload string `json:"payload"`
+}
+
+type queuedUplinkBody struct {
+	MType     string     `json:"mtype" binding:"omitempty,oneof=ConfirmedDataUp UnConfirmedDataUp"`
+	Confirmed bool       `json:"confirmed"`
+	FPort     *uint8     `json:"fPort" binding:"omitempty,max=223"` // of the device if missing, 0 for MAC commands in the payload
+	Payload   string     `json:"payload"`                           // in encoding
+	Encoding  string     `json:"encoding" binding:"omitempty,oneof=text hex base64"`
+	Priority  int        `json:"priority"` // the highest is sent first
+	SendAt    *time.Time `json:"sendAt"`   // not sent before
+}
+
+type macCommandBody struct {
+	CID         string `json:"cid" binding:"required"` // name of an uplink MAC command, eg. LinkCheckReq, or number for a raw command, eg. 0x80
+	Periodicity uint8  `json:"periodicity"`            // of PingSlotInfoReq without payload
+	Payload     string `json:"payload"`                // hex, without the CID
+	FPort0      bool   `json:"fPort0"`                 // in the payload of an uplink on FPort 0 rather than in FOpts
+}
+
+type networkDownlinkBody struct {
+	FPort     uint8  `json:"fPort" binding:"min=1,max=223"`
+	Payload   string `json:"payload"` // hex
+	Confirmed bool   `json:"confirmed"`
+}
+
+type networkMACCommandBody struct {
+	CID     uint8  `json:"cid"`
+	Payload string `json:"payload"` // hex, without the CID
+}
+
+func routesV2() []route {
+
+	device := exampleDevice()
+	gateway := exampleGateway()
+	bridge := models.AddressIP{Address: "127.0.0.1", Port: "1700"}
+
+	return []route{
+		{"GET", "/simulation", models.RoleViewer, "State of the simulation", nil, nil, simulationState{}, http.StatusOK, getSimulationV2},
+		{"PUT", "/simulation", models.RoleOperator, "Start or stop the simulation", nil, simulationState{}, simulationState{}, http.StatusOK, putSimulationV2},
+		{"GET", "/bridge", models.RoleViewer, "Address of the gateway bridge", nil, nil, bridge, http.StatusOK, getBridgeV2},
+		{"PUT", "/bridge", models.RoleAdmin, "Set the address of the gateway bridge", nil, bridge, bridge, http.StatusOK, putBridgeV2},
+
+		{"GET", "/devices", models.RoleViewer, "List the devices, the keys only for admin", nil, nil, []*dev.Device{device}, http.StatusOK, listDevicesV2},
+		{"POST", "/devices", models.RoleAdmin, "Add a device", nil, device, device, http.StatusCreated, createDeviceV2},
+		{"GET", "/devices/:id", models.RoleViewer, "Get a device, the keys only for admin", nil, nil, device, http.StatusOK, getDeviceV2},
+		{"PUT", "/devices/:id", models.RoleAdmin, "Update a turned off device", nil, device, device, http.StatusOK, updateDeviceV2},
+		{"DELETE", "/devices/:id", models.RoleAdmin, "Delete a turned off device", nil, nil, nil, http.StatusNoContent, deleteDeviceV2},
+		{"PUT", "/devices/:id/state", models.RoleOperator, "Turn a device on or off", nil, componentState{}, componentState{}, http.StatusOK, putDeviceStateV2},
+		{"PUT", "/devices/:id/payload", models.RoleOperator, "Change the payload of the periodic uplinks", nil, uplinkBody{}, nil, http.StatusNoContent, putPayloadV2},
+		{"GET", "/devices/:id/uplinks", models.RoleViewer, "List the queued uplinks", nil, nil, []*mup.InfoFrame{{}}, http.StatusOK, listUplinksV2},
+		{"POST", "/devices/:id/uplinks", models.RoleOperator, "Queue an uplink", nil, queuedUplinkBody{}, &mup.InfoFrame{}, http.StatusAccepted, postUplinkV2},
+		{"DELETE", "/devices/:id/uplinks", models.RoleOperator, "Clear the uplink queue", nil, nil, nil, http.StatusNoContent, clearUplinksV2},
+		{"DELETE", "/devices/:id/uplinks/:uplinkId", models.RoleOperator, "Delete a queued uplink", nil, nil, nil, http.StatusNoContent, deleteUplinkV2},
+		{"GET", "/devices/:id/downlinks", models.RoleViewer, "Last application downlinks received by a device", nil, nil, []integration.Downlink{{}}, http.StatusOK, listDownlinksV2},
+		{"DELETE", "/devices/:id/downlinks", models.RoleOperator, "Forget the application downlinks received by a device", nil, nil, nil, http.StatusNoContent, clearDownlinksV2},
+		{"POST", "/devices/:id/mac-commands", models.RoleOperator, "Send a MAC command with the next uplink", nil, macCommandBody{}, nil, http.StatusAccepted, postMACCommandV2},
+		{"PUT", "/devices/:id/mac-schedules", models.RoleOperator, "Replace the MAC commands sent periodically", nil, []devm.MACSchedule{{}}, []devm.MACSchedule{{}}, http.StatusOK, putMACSchedulesV2},
+		{"PUT", "/devices/:id/location", models.RoleOperator, "Move a turned on device", nil, loc.Location{}, nil, http.StatusNoContent, putDeviceLocationV2},
+		{"GET", "/devices/:id/live-state", models.RoleViewer, "Runtime state of a device, the session keys only for admin", nil, nil, exampleLiveState(), http.StatusOK, getDeviceLiveStateV2},
+
+		{"POST", "/integrations/chirpstack", models.RoleOperator, "Receive the events of the HTTP integration of ChirpStack", []string{"event"}, map[string]interface{}{}, nil, http.StatusNoContent, postChirpStackV2},
+		{"POST", "/integrations/tts", models.RoleOperator, "Receive the messages of a webhook of The Things Stack", nil, map[string]interface{}{}, nil, http.StatusNoContent, postTTSV2},
+		{"GET", "/delivery", models.RoleViewer, "Uplinks delivered by the application server, by device", nil, nil, []integration.DeliveryReport{{LostFrames: []integration.LostFrame{{}}}}, http.StatusOK, listDeliveryV2},
+		{"DELETE", "/delivery", models.RoleOperator, "Forget the uplinks sent and delivered", nil, nil, nil, http.StatusNoContent, resetDeliveryV2},
+		{"GET", "/devices/:id/delivery", models.RoleViewer, "Uplinks of a device delivered by the application server", nil, nil, integration.DeliveryReport{LostFrames: []integration.LostFrame{{}}}, http.StatusOK, getDeliveryV2},
+		{"POST", "/uplinks", models.RoleOperator, "Send an uplink through the session of the device of its DevEUI", nil, integration.Uplink{}, &mup.InfoFrame{}, http.StatusAccepted, postExternalUplinkV2},
+
+		{"GET", "/gateways", models.RoleViewer, "List the gateways", nil, nil, []*gw.Gateway{gateway}, http.StatusOK, listGatewaysV2},
+		{"GET", "/gateways/links", models.RoleViewer, "Links of the gateways with the network server", nil, nil, []socket.LinkGw{{}}, http.StatusOK, listLinksV2},
+		{"POST", "/gateways", models.RoleAdmin, "Add a gateway", nil, gateway, gateway, http.StatusCreated, createGatewayV2},
+		{"GET", "/gateways/:id", models.RoleViewer, "Get a gateway", nil, nil, gateway, http.StatusOK, getGatewayV2},
+		{"PUT", "/gateways/:id", models.RoleAdmin, "Update a turned off gateway", nil, gateway, gateway, http.StatusOK, updateGatewayV2},
+		{"DELETE", "/gateways/:id", models.RoleAdmin, "Delete a turned off gateway", nil, nil, nil, http.StatusNoContent, deleteGatewayV2},
+		{"PUT", "/gateways/:id/state", models.RoleOperator, "Turn a gateway on or off", nil, componentState{}, componentState{}, http.StatusOK, putGatewayStateV2},
+		{"PUT", "/gateways/:id/location", models.RoleOperator, "Move a turned on gateway", nil, loc.Location{}, nil, http.StatusNoContent, putGatewayLocationV2},
+		{"PUT", "/gateways/:id/impairment", models.RoleAdmin, "Set the impairment of the backhaul", nil, &gateway.Info.Impairment, nil, http.StatusNoContent, putImpairmentV2},
+		{"PUT", "/gateways/:id/concentrator", models.RoleAdmin, "Import the global_conf.json of a packet forwarder", nil, map[string]interface{}{"SX130x_conf": map[string]interface{}{}}, nil, http.StatusNoContent, putConcentratorV2},
+
+		{"GET", "/pcap", models.RoleViewer, "State of the PCAP capture", nil, nil, models.Pcap{}, http.StatusOK, getPcapV2},
+		{"PUT", "/pcap", models.RoleOperator, "Enable or disable the PCAP capture", nil, models.Pcap{}, models.Pcap{}, http.StatusOK, putPcapV2},
+		{"GET", "/pcap/file", models.RoleViewer, "Download the last capture", nil, nil, nil, http.StatusOK, downloadPcapV2},
+		{"GET", "/frames", models.RoleViewer, "Query the frame log", []string{"devEUI", "type", "from", "to", "limit"}, nil, []map[string]interface{}{{}}, http.StatusOK, getFramesV2},
+
+		{"GET", "/network-server/sessions", models.RoleViewer, "Sessions of the embedded network server", nil, nil, []ns.Session{{LastUplink: &ns.Uplink{}}}, http.StatusOK, listSessionsV2},
+		{"POST", "/network-server/devices/:id/downlinks", models.RoleOperator, "Queue a downlink in the embedded network server", nil, networkDownlinkBody{}, nil, http.StatusAccepted, postNetworkDownlinkV2},
+		{"POST", "/network-server/devices/:id/mac-commands", models.RoleOperator, "Queue a MAC command in the embedded network server", nil, networkMACCommandBody{}, nil, http.StatusAccepted, postNetworkMACCommandV2},
+	}
+}
+
+// registerV2 mounts the API v2 and its OpenAPI document on router
+func registerV2(router *gin.Engine) {
+
+	routes := routesV2()
+
+	jsonFieldNames()
+
+	group := router.Group(apiV2)
+	for _, r := range routes {
+		group.Handle(r.method, r.path, authorize(r.role), r.handler)
+	}
+
+	document := openAPI(apiV2, routes)
+
+	group.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	})
+}
+
+// exampleLiveState is a live state whose encoding describes the schema of the live states
+func exampleLiveState() socket.LiveStateDev {
+
+	last := time.Time{}
+
+	return socket.LiveStateDev{
+		Channels:    []socket.LiveChannel{{}},
+		ChannelMask: []int{0},
+		MACCommands: []socket.LiveMACCommand{{}},
+		Uplinks:     []socket.LiveUplink{{}},
+		LastUplink:  &last,
+	}
+}
+
+// exampleDevice is a device whose encoding describes the schema of the devices
+func exampleDevice() *dev.Device {
+
+	var device dev.Device
+	json.Unmarshal([]byte(`{"info":{"status":{"infoUplink":{},"uplinkQueue":[{}]},"configuration":{"region":1},"rxs":[{},{}]}}`), &device)
+
+	return &device
+}
+
+func exampleGateway() *gw.Gateway {
+
+	var gateway gw.Gateway
+	json.Unmarshal([]byte(`{"info":{"impairment":{"outages":[{}]},"concentrator":{"radios":[{}],"loraStd":{"bandwidth":1,"spreadFactor":1},"fsk":{"bandwidth":1,"datarate":1}}}}`), &gateway)
+
+	return &gateway
+}
+
+//*******************************Errors**************************************/
+
+// httpStatus maps the codes of the simulator to the HTTP status
+func httpStatus(code int) int {
+
+	switch code {
+	case codes.CodeErrorNotFound:
+		return http.StatusNotFound
+	case codes.CodeErrorName, codes.CodeErrorAddress, codes.CodeErrorDeviceActive, codes.CodeErrorGatewayActive,
+		codes.CodeNoBridge, codes.CodeErrorDeviceInactive, codes.CodeErrorStopped:
+		return http.StatusConflict
+	case codes.CodeErrorConfiguration:
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+func fail(c *gin.Context, code int, err error) {
+	c.AbortWithStatusJSON(httpStatus(code), apiError{Error: err.Error(), Code: code})
+}
+
+func invalid(c *gin.Context, fields []fieldError) {
+	c.AbortWithStatusJSON(http.StatusUnprocessableEntity, apiError{Error: "Validation failed", Fields: fields})
+}
+
+// bindJSON decodes the body in v, false if it answered 400, or 422 if it breaks the rules of
+// the binding tags of v
+func bindJSON(c *gin.Context, v interface{}) bool {
+
+	err := json.NewDecoder(c.Request.Body).Decode(v)
+	if err != nil {
+
+		body := apiError{Error: "Invalid body: " + err.Error()}
+
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			body.Fields = []fieldError{{Field: typeErr.Field, Message: "expected " + typeErr.Type.String()}}
+		}
+
+		c.AbortWithStatusJSON(http.StatusBadRequest, body)
+
+		return false
+	}
+
+	var rules validator.ValidationErrors
+	if err = binding.Validator.ValidateStruct(v); errors.As(err, &rules) {
+
+		var fields []fieldError
+		for _, rule := range rules {
+			fields = append(fields, fieldError{Field: fieldPath(rule.Namespace()), Message: ruleMessage(rule)})
+		}
+
+		invalid(c, fields)
+
+		return false
+	}
+
+	return true
+}
+
+// fieldPath removes the type from the namespace of a rule, eg. queuedUplinkBody.fPort
+func fieldPath(namespace string) string {
+
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+
+	return namespace
+}
+
+func ruleMessage(rule validator.FieldError) string {
+
+	switch rule.Tag() {
+	case "required":
+		return "required"
+	case "oneof":
+		return strings.ReplaceAll(rule.Param(), " ", ", ") + " expected"
+	case "min":
+		return "at least " + rule.Param() + " expected"
+	case "max":
+		return "at most " + rule.Param() + " expected"
+	}
+
+	return rule.Tag() + " " + rule.Param() + " expected"
+}
+
+// jsonFieldNames names the fields of the validation errors as in the bodies
+func jsonFieldNames() {
+
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+}
+
+// paramID returns the id of the path, false if it answered 400
+func paramID(c *gin.Context) (int, bool) {
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, apiError{
+			Error:  "Invalid id",
+			Fields: []fieldError{{Field: "id", Message: "non-negative integer expected"}},
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+//*******************************Simulation**************************************/
+
+func getSimulationV2(c *gin.Context) {
+	c.JSON(http.StatusOK, simulationState{Running: simulatorController.Status()})
+}
+
+func putSimulationV2(c *gin.Context) {
+
+	var state simulationState
+	if !bindJSON(c, &state) {
+		return
+	}
+
+	if state.Running {
+		simulatorController.Run()
+	} else {
+		simulatorController.Stop()
+	}
+
+	c.JSON(http.StatusOK, simulationState{Running: simulatorController.Status()})
+}
+
+func getBridgeV2(c *gin.Context) {
+	c.JSON(http.StatusOK, simulatorController.GetBridgeAddress())
+}
+
+func putBridgeV2(c *gin.Context) {
+
+	var bridge models.AddressIP
+	if !bindJSON(c, &bridge) {
+		return
+	}
+
+	var fields []fieldError
+
+	if bridge.Address == "" {
+		fields = append(fields, fieldError{"ip", "required"})
+	}
+
+	if port, err := strconv.Atoi(bridge.Port); err != nil || port < 1 || port > 65535 {
+		fields = append(fields, fieldError{"port", "port number expected"})
+	}
+
+	if len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	if err := simulatorController.SaveBridgeAddress(bridge); err != nil {
+		fail(c, codes.CodeSaving, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, simulatorController.GetBridgeAddress())
+}
+
+//*******************************Devices**************************************/
+
+func listDevicesV2(c *gin.Context) {
+
+	devices := simulatorController.GetDevices()
+	if !canReadKeys(c) {
+		hideKeys(devices)
+	}
+
+	if devices == nil {
+		devices = []dev.Device{}
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+// findDevice answers 404 if the device Id does not exist
+func findDevice(c *gin.Context, Id int) (*dev.Device, bool) {
+
+	devices := simulatorController.GetDevices()
+	if !canReadKeys(c) {
+		hideKeys(devices)
+	}
+
+	for i := range devices {
+		if devices[i].Id == Id {
+			return &devices[i], true
+		}
+	}
+
+	fail(c, codes.CodeErrorNotFound, errors.New("Device not found"))
+
+	return nil, false
+}
+
+func getDeviceV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	device, ok := findDevice(c, id)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func getDeviceLiveStateV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	state, code, err := simulatorController.GetDeviceLiveState(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	if !canReadKeys(c) {
+		state.NwkSKey = ""
+		state.AppSKey = ""
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func createDeviceV2(c *gin.Context) {
+
+	var device dev.Device
+	if !bindJSON(c, &device) {
+		return
+	}
+
+	if fields := validateDevice(&device); len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	code, id, err := simulatorController.AddDevice(&device)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	created, ok := findDevice(c, id)
+	if !ok {
+		return
+	}
+
+	c.Header("Location", apiV2+"/devices/"+strconv.Itoa(id))
+	c.JSON(http.StatusCreated, created)
+}
+
+func updateDeviceV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var device dev.Device
+	if !bindJSON(c, &device) {
+		return
+	}
+
+	device.Id = id
+
+	if fields := validateDevice(&device); len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	code, err := simulatorController.UpdateDevice(&device)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	updated, ok := findDevice(c, id)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteDeviceV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	code, err := simulatorController.DeleteDevice(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func putDeviceStateV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var state componentState
+	if !bindJSON(c, &state) {
+		return
+	}
+
+	code, err := simulatorController.SetStateDevice(id, state.Active)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// bindUplink decodes and validates an uplink of the device id
+func bindUplink(c *gin.Context) (socket.NewPayload, bool) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return socket.NewPayload{}, false
+	}
+
+	var body uplinkBody
+	if !bindJSON(c, &body) {
+		return socket.NewPayload{}, false
+	}
+
+	return socket.NewPayload{
+		Id:      id,
+		MType:   body.MType,
+		Payload: body.Payload,
+	}, true
+}
+
+func putPayloadV2(c *gin.Context) {
+
+	payload, ok := bindUplink(c)
+	if !ok {
+		return
+	}
+
+	_, code, err := simulatorController.ChangePayload(payload)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func postUplinkV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var body queuedUplinkBody
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	var fields []fieldError
+
+	var payload []byte
+	var err error
+
+	switch body.Encoding {
+	case "", "text":
+		payload = []byte(body.Payload)
+	case "hex":
+		payload, err = hex.DecodeString(body.Payload)
+	case "base64":
+		payload, err = base64.StdEncoding.DecodeString(body.Payload)
+	}
+
+	if err != nil {
+		fields = append(fields, fieldError{"payload", body.Encoding + " expected"})
+	} else if len(payload) > integration.MaxPayload {
+		fields = append(fields, fieldError{"payload", "at most " + strconv.Itoa(integration.MaxPayload) + " bytes expected"})
+	}
+
+	if len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	frame := mup.InfoFrame{
+		MType:    lorawan.UnconfirmedDataUp,
+		Payload:  &lorawan.DataPayload{Bytes: payload},
+		FPort:    body.FPort,
+		Priority: body.Priority,
+		SendAt:   body.SendAt,
+	}
+
+	if body.Confirmed || body.MType == "ConfirmedDataUp" {
+		frame.MType = lorawan.ConfirmedDataUp
+	}
+
+	frame, code, err := simulatorController.QueueUplink(id, frame)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, &frame)
+}
+
+func postExternalUplinkV2(c *gin.Context) {
+
+	var uplink integration.Uplink
+	if !bindJSON(c, &uplink) {
+		return
+	}
+
+	frame, code, err := simulatorController.SendExternalUplink(uplink)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, &frame)
+}
+
+// receiveApplicationEvent records the event of the body decoded by parse, the events of the
+// application servers are answered 204 even when they are ignored
+func receiveApplicationEvent(c *gin.Context, parse func([]byte) (integration.ApplicationEvent, error)) {
+
+	body, err := c.GetRawData()
+	if err == nil {
+
+		var ev integration.ApplicationEvent
+
+		ev, err = parse(body)
+		if err == nil {
+
+			if ev.Type != "" {
+				simulatorController.ReceiveApplicationEvent(ev)
+			}
+
+			c.Status(http.StatusNoContent)
+			return
+		}
+	}
+
+	c.AbortWithStatusJSON(http.StatusBadRequest, apiError{Error: "Invalid body: " + err.Error()})
+}
+
+func postChirpStackV2(c *gin.Context) {
+	receiveApplicationEvent(c, func(body []byte) (integration.ApplicationEvent, error) {
+		return integration.ParseChirpStack(c.Query("event"), body)
+	})
+}
+
+func postTTSV2(c *gin.Context) {
+	receiveApplicationEvent(c, integration.ParseTTS)
+}
+
+func listDeliveryV2(c *gin.Context) {
+	c.JSON(http.StatusOK, simulatorController.GetDeliveryReports())
+}
+
+func resetDeliveryV2(c *gin.Context) {
+
+	simulatorController.ResetDelivery()
+
+	c.Status(http.StatusNoContent)
+}
+
+func getDeliveryV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	report, code, err := simulatorController.GetDeliveryReport(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func listUplinksV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	frames, code, err := simulatorController.GetUplinkQueue(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	list := make([]*mup.InfoFrame, len(frames))
+	for i := range frames {
+		list[i] = &frames[i]
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func deleteUplinkV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	uplinkId, err := strconv.ParseUint(c.Param("uplinkId"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, apiError{
+			Error:  "Invalid uplinkId",
+			Fields: []fieldError{{Field: "uplinkId", Message: "non-negative integer expected"}},
+		})
+		return
+	}
+
+	code, err := simulatorController.DeleteUplink(id, uplinkId)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func clearUplinksV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	code, err := simulatorController.ClearUplinkQueue(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func listDownlinksV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	downlinks, code, err := simulatorController.GetDownlinks(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, downlinks)
+}
+
+func clearDownlinksV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	code, err := simulatorController.ClearDownlinks(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func postMACCommandV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var body macCommandBody
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	cid, _, err := mac.ParseCID(body.CID)
+	if err != nil {
+		invalid(c, []fieldError{{"cid", err.Error()}})
+		return
+	}
+
+	if _, err := hex.DecodeString(body.Payload); err != nil {
+		invalid(c, []fieldError{{"payload", "hex expected"}})
+		return
+	}
+
+	code, err := simulatorController.SendMACCommand(cid, socket.MacCommand{
+		Id:          id,
+		CID:         body.CID,
+		Periodicity: body.Periodicity,
+		Payload:     body.Payload,
+		FPort0:      body.FPort0,
+	})
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func putMACSchedulesV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var schedules []devm.MACSchedule
+	if !bindJSON(c, &schedules) {
+		return
+	}
+
+	if fields := validateMACSchedules("", schedules); len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	code, err := simulatorController.SetMACSchedules(id, schedules)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	if schedules == nil {
+		schedules = []devm.MACSchedule{}
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// bindLocation decodes and validates a location of the component id
+func bindLocation(c *gin.Context) (socket.NewLocation, bool) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return socket.NewLocation{}, false
+	}
+
+	var location loc.Location
+	if !bindJSON(c, &location) {
+		return socket.NewLocation{}, false
+	}
+
+	if fields := validateLocation("", location); len(fields) > 0 {
+		invalid(c, fields)
+		return socket.NewLocation{}, false
+	}
+
+	return socket.NewLocation{
+		Id:        id,
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Altitude:  location.Altitude,
+	}, true
+}
+
+func putDeviceLocationV2(c *gin.Context) {
+
+	location, ok := bindLocation(c)
+	if !ok {
+		return
+	}
+
+	code, err := simulatorController.ChangeLocation(location)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+//*******************************Gateways**************************************/
+
+func listGatewaysV2(c *gin.Context) {
+
+	gateways := simulatorController.GetGateways()
+	if gateways == nil {
+		gateways = []gw.Gateway{}
+	}
+
+	c.JSON(http.StatusOK, gateways)
+}
+
+func listLinksV2(c *gin.Context) {
+
+	links := simulatorController.GetGatewaysConnection()
+	if links == nil {
+		links = []socket.LinkGw{}
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// findGateway answers 404 if the gateway Id does not exist
+func findGateway(c *gin.Context, Id int) (*gw.Gateway, bool) {
+
+	gateways := simulatorController.GetGateways()
+
+	for i := range gateways {
+		if gateways[i].Id == Id {
+			return &gateways[i], true
+		}
+	}
+
+	fail(c, codes.CodeErrorNotFound, errors.New("Gateway not found"))
+
+	return nil, false
+}
+
+func getGatewayV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	gateway, ok := findGateway(c, id)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gateway)
+}
+
+func createGatewayV2(c *gin.Context) {
+
+	var gateway gw.Gateway
+	if !bindJSON(c, &gateway) {
+		return
+	}
+
+	if fields := validateGateway(&gateway); len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	code, id, err := simulatorController.AddGateway(&gateway)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	created, ok := findGateway(c, id)
+	if !ok {
+		return
+	}
+
+	c.Header("Location", apiV2+"/gateways/"+strconv.Itoa(id))
+	c.JSON(http.StatusCreated, created)
+}
+
+func updateGatewayV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var gateway gw.Gateway
+	if !bindJSON(c, &gateway) {
+		return
+	}
+
+	gateway.Id = id
+
+	if fields := validateGateway(&gateway); len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	code, err := simulatorController.UpdateGateway(&gateway)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	updated, ok := findGateway(c, id)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteGatewayV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	code, err := simulatorController.DeleteGateway(id)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func putGatewayStateV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var state componentState
+	if !bindJSON(c, &state) {
+		return
+	}
+
+	code, err := simulatorController.SetStateGateway(id, state.Active)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func putGatewayLocationV2(c *gin.Context) {
+
+	location, ok := bindLocation(c)
+	if !ok {
+		return
+	}
+
+	code, err := simulatorController.ChangeLocationGateway(location)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func putImpairmentV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var impairment udp.Impairment
+	if !bindJSON(c, &impairment) {
+		return
+	}
+
+	if fields := validateImpairment("", &impairment); len(fields) > 0 {
+		invalid(c, fields)
+		return
+	}
+
+	code, err := simulatorController.SetImpairmentGateway(id, impairment)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func putConcentratorV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var globalConf json.RawMessage
+	if !bindJSON(c, &globalConf) {
+		return
+	}
+
+	code, err := simulatorController.ImportConcentratorGateway(id, globalConf)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+//*******************************Resources**************************************/
+
+func getPcapV2(c *gin.Context) {
+	c.JSON(http.StatusOK, simulatorController.GetPcap())
+}
+
+func putPcapV2(c *gin.Context) {
+
+	var pcap models.Pcap
+	if !bindJSON(c, &pcap) {
+		return
+	}
+
+	if err := simulatorController.SetPcap(pcap.Enable); err != nil {
+		fail(c, codes.CodeSaving, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, simulatorController.GetPcap())
+}
+
+func downloadPcapV2(c *gin.Context) {
+
+	pcap := simulatorController.GetPcap()
+	if pcap.File == "" {
+		fail(c, codes.CodeErrorNotFound, errors.New("No capture"))
+		return
+	}
+
+	downloadPcap(c)
+}
+
+func getFramesV2(c *gin.Context) {
+
+	filter, field := parseFrameFilter(c)
+	if field != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, apiError{
+			Error:  "Invalid " + field.Field,
+			Fields: []fieldError{*field},
+		})
+		return
+	}
+
+	frames, err := simulatorController.GetFrames(filter)
+	if err != nil {
+		fail(c, codes.CodeSaving, err)
+		return
+	}
+
+	if frames == nil {
+		frames = []json.RawMessage{}
+	}
+
+	c.JSON(http.StatusOK, frames)
+}
+
+//*******************************Network server**************************************/
+
+func listSessionsV2(c *gin.Context) {
+
+	sessions, code, err := simulatorController.NetworkSessions()
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func postNetworkDownlinkV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var body networkDownlinkBody
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	payload, err := hex.DecodeString(body.Payload)
+	if err != nil {
+		invalid(c, []fieldError{{"payload", "hex expected"}})
+		return
+	}
+
+	code, err := simulatorController.QueueNetworkDownlink(id, ns.Downlink{
+		FPort:     body.FPort,
+		Payload:   payload,
+		Confirmed: body.Confirmed,
+	})
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func postNetworkMACCommandV2(c *gin.Context) {
+
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var body networkMACCommandBody
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	payload, err := hex.DecodeString(body.Payload)
+	if err != nil {
+		invalid(c, []fieldError{{"payload", "hex expected"}})
+		return
+	}
+
+	if 1+len(payload) > ns.MaxFOptsLen {
+		invalid(c, []fieldError{{"payload", "at most 14 bytes expected"}})
+		return
+	}
+
+	code, err := simulatorController.QueueNetworkMACCommand(id, lorawan.CID(body.CID), payload)
+	if err != nil {
+		fail(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
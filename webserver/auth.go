@@ -0,0 +1,205 @@
+package webserver
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/arslab/lwnsimulator/models"
+	"github.com/arslab/lwnsimulator/socket"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	socketio "github.com/googollee/go-socket.io"
+)
+
+const (
+	realm   = "LWN Simulator"
+	roleKey = "role" // of the gin context
+)
+
+var levels = map[string]int{
+	models.RoleViewer:   1,
+	models.RoleOperator: 2,
+	models.RoleAdmin:    3,
+}
+
+// defaultPassword is the password of the admin of the former sample configuration
+const defaultPassword = "changeme"
+
+// checkAuth stops the server if the authentication configured is not valid
+func checkAuth(auth models.Auth) {
+	if err := validateAuth(auth); err != nil {
+		log.Fatal("[WS] [ERROR]: ", err)
+	}
+}
+
+// validateAuth fails if a user or a token has no valid role, or if the authentication is
+// enabled without credentials or with a user without password or with the default one
+func validateAuth(auth models.Auth) error {
+
+	if auth.Enable && len(auth.Users) == 0 && len(auth.Tokens) == 0 {
+		return errors.New("authentication enabled without users nor tokens")
+	}
+
+	for _, user := range auth.Users {
+
+		if _, ok := levels[user.Role]; !ok || user.Username == "" {
+			return fmt.Errorf("user %q has no valid role", user.Username)
+		}
+
+		if auth.Enable && (user.Password == "" || user.Password == defaultPassword) {
+			return fmt.Errorf("user %q has no password or the default one, change it", user.Username)
+		}
+
+	}
+
+	for _, token := range auth.Tokens {
+		if _, ok := levels[token.Role]; !ok || token.Token == "" {
+			return fmt.Errorf("token %q has no valid role", token.Name)
+		}
+	}
+
+	return nil
+}
+
+// authenticate returns the role of the credentials of the request, admin if the authentication is disabled
+func authenticate(header http.Header, query url.Values) (string, bool) {
+
+	if !configuration.Auth.Enable {
+		return models.RoleAdmin, true
+	}
+
+	token := query.Get("token")
+	authorization := header.Get("Authorization")
+
+	if strings.HasPrefix(authorization, "Bearer ") {
+		token = strings.TrimPrefix(authorization, "Bearer ")
+	}
+
+	if token != "" {
+
+		for _, t := range configuration.Auth.Tokens {
+			if equal(t.Token, token) {
+				return t.Role, true
+			}
+		}
+
+		return "", false
+	}
+
+	r := http.Request{Header: header}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+
+	for _, user := range configuration.Auth.Users {
+		if equal(user.Username, username) && equal(user.Password, password) {
+			return user.Role, true
+		}
+	}
+
+	return "", false
+}
+
+func equal(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func allowed(role string, required string) bool {
+	return levels[role] >= levels[required]
+}
+
+// authorize answers 401 without valid credentials and 403 if their role is lower than required
+func authorize(required string) gin.HandlerFunc {
+	return authorizeRequest(required, false)
+}
+
+// authorizeHandshake is authorize accepting also the token parameter of the query, for the
+// handshake of the socket whose browser client can't set the headers
+func authorizeHandshake(required string) gin.HandlerFunc {
+	return authorizeRequest(required, true)
+}
+
+// authorizeRequest never reads the query without withQuery, to keep the tokens out of the
+// access logs and the Referer headers
+func authorizeRequest(required string, withQuery bool) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		query := url.Values{}
+		if withQuery {
+			query = c.Request.URL.Query()
+		}
+
+		role, ok := authenticate(c.Request.Header, query)
+		if !ok {
+			c.Header("WWW-Authenticate", "Basic realm=\""+realm+"\"")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "Unauthorized"})
+			return
+		}
+
+		if !allowed(role, required) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "Forbidden"})
+			return
+		}
+
+		c.Set(roleKey, role)
+		c.Next()
+	}
+
+}
+
+// authorizeSocket tells the client if its role is lower than required
+func authorizeSocket(s socketio.Conn, required string) bool {
+
+	role, _ := s.Context().(string)
+	if allowed(role, required) {
+		return true
+	}
+
+	s.Emit(socket.EventError, socket.ConsoleLog{
+		Msg: "Forbidden: " + required + " role required",
+	})
+
+	return false
+}
+
+// canReadKeys is true if the role of the request can see the keys of the devices
+func canReadKeys(c *gin.Context) bool {
+	return allowed(c.GetString(roleKey), models.RoleAdmin)
+}
+
+// newCors allows the configured origins, nil if only the same origin is allowed
+func newCors(config models.Cors) gin.HandlerFunc {
+
+	if len(config.AllowOrigins) == 0 {
+		return nil
+	}
+
+	configCors := cors.DefaultConfig()
+	configCors.AllowHeaders = []string{"Origin", "Access-Control-Allow-Origin",
+		"Access-Control-Allow-Headers", "Content-type", "Authorization"}
+	configCors.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			configCors.AllowAllOrigins = true
+		}
+	}
+
+	if configCors.AllowAllOrigins {
+		if config.AllowCredentials {
+			log.Println("[WS]: CORS credentials are not allowed with all origins")
+		}
+	} else {
+		configCors.AllowOrigins = config.AllowOrigins
+		configCors.AllowCredentials = config.AllowCredentials
+	}
+
+	return cors.New(configCors)
+}
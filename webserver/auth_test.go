@@ -0,0 +1,140 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arslab/lwnsimulator/models"
+	"github.com/gin-gonic/gin"
+)
+
+var testAuth = models.Auth{
+	Enable: true,
+	Users: []models.User{
+		{Username: "viewer", Password: "viewer-pw", Role: models.RoleViewer},
+		{Username: "operator", Password: "operator-pw", Role: models.RoleOperator},
+		{Username: "admin", Password: "admin-pw", Role: models.RoleAdmin},
+	},
+	Tokens: []models.Token{
+		{Name: "ci", Token: "operator-token", Role: models.RoleOperator},
+	},
+}
+
+// withAuth sets the configuration of the server to auth for the test
+func withAuth(t *testing.T, auth models.Auth) {
+
+	t.Helper()
+
+	previous := configuration
+	configuration = &models.ServerConfig{Auth: auth}
+
+	t.Cleanup(func() { configuration = previous })
+}
+
+func TestValidateAuth(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		auth    models.Auth
+		wantErr bool
+	}{
+		{"valid", testAuth, false},
+		{"disabled without credentials", models.Auth{}, false},
+		{"enabled without credentials", models.Auth{Enable: true}, true},
+		{"enabled with a token only", models.Auth{Enable: true, Tokens: testAuth.Tokens}, false},
+		{"unknown role", models.Auth{Users: []models.User{{Username: "a", Password: "b", Role: "root"}}}, true},
+		{"user without name", models.Auth{Users: []models.User{{Password: "b", Role: models.RoleAdmin}}}, true},
+		{"enabled without password", models.Auth{Enable: true, Users: []models.User{{Username: "a", Role: models.RoleAdmin}}}, true},
+		{"enabled with the default password", models.Auth{Enable: true, Users: []models.User{{Username: "a", Password: defaultPassword, Role: models.RoleAdmin}}}, true},
+		{"disabled without password", models.Auth{Users: []models.User{{Username: "a", Role: models.RoleAdmin}}}, false},
+		{"token without value", models.Auth{Tokens: []models.Token{{Name: "ci", Role: models.RoleViewer}}}, true},
+		{"token with an unknown role", models.Auth{Tokens: []models.Token{{Name: "ci", Token: "t", Role: "root"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAuth(tt.auth); (err != nil) != tt.wantErr {
+				t.Errorf("validateAuth() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+}
+
+func TestAuthorizeRequest(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	basic := func(username, password string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(username, password) }
+	}
+
+	bearer := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		required  string
+		withQuery bool
+		target    string
+		setup     func(*http.Request)
+		want      int
+		wantRole  string
+	}{
+		{"disabled is admin", true, models.RoleAdmin, false, "/", nil, http.StatusOK, models.RoleAdmin},
+		{"no credentials", false, models.RoleViewer, false, "/", nil, http.StatusUnauthorized, ""},
+		{"viewer reads", false, models.RoleViewer, false, "/", basic("viewer", "viewer-pw"), http.StatusOK, models.RoleViewer},
+		{"viewer can't operate", false, models.RoleOperator, false, "/", basic("viewer", "viewer-pw"), http.StatusForbidden, ""},
+		{"operator operates", false, models.RoleOperator, false, "/", basic("operator", "operator-pw"), http.StatusOK, models.RoleOperator},
+		{"operator can't administer", false, models.RoleAdmin, false, "/", basic("operator", "operator-pw"), http.StatusForbidden, ""},
+		{"admin operates", false, models.RoleOperator, false, "/", basic("admin", "admin-pw"), http.StatusOK, models.RoleAdmin},
+		{"wrong password", false, models.RoleViewer, false, "/", basic("admin", "viewer-pw"), http.StatusUnauthorized, ""},
+		{"unknown user", false, models.RoleViewer, false, "/", basic("root", "admin-pw"), http.StatusUnauthorized, ""},
+		{"bearer token", false, models.RoleOperator, false, "/", bearer("operator-token"), http.StatusOK, models.RoleOperator},
+		{"bearer token below the role", false, models.RoleAdmin, false, "/", bearer("operator-token"), http.StatusForbidden, ""},
+		{"unknown bearer token", false, models.RoleViewer, false, "/", bearer("wrong"), http.StatusUnauthorized, ""},
+		{"query token on a request", false, models.RoleViewer, false, "/?token=operator-token", nil, http.StatusUnauthorized, ""},
+		{"query token on the handshake", false, models.RoleViewer, true, "/?token=operator-token", nil, http.StatusOK, models.RoleOperator},
+		{"unknown query token on the handshake", false, models.RoleViewer, true, "/?token=wrong", nil, http.StatusUnauthorized, ""},
+		{"bearer token before the query", false, models.RoleViewer, true, "/?token=wrong", bearer("operator-token"), http.StatusOK, models.RoleOperator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			auth := testAuth
+			auth.Enable = !tt.disabled
+			withAuth(t, auth)
+
+			router := gin.New()
+			router.GET("/", authorizeRequest(tt.required, tt.withQuery), func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString(roleKey))
+			})
+
+			request := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.setup != nil {
+				tt.setup(request)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.want {
+				t.Fatalf("status %v, want %v", recorder.Code, tt.want)
+			}
+
+			if tt.want == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+
+			if tt.want == http.StatusOK && recorder.Body.String() != tt.wantRole {
+				t.Errorf("role %q, want %q", recorder.Body.String(), tt.wantRole)
+			}
+
+		})
+	}
+
+}
@@ -0,0 +1,491 @@
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/arslab/lwnsimulator/codes"
+	"github.com/arslab/lwnsimulator/models"
+	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	mac "github.com/arslab/lwnsimulator/simulator/components/device/macCommands"
+	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	"github.com/arslab/lwnsimulator/socket"
+	"github.com/arslab/lwnsimulator/webserver/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	gcodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// grpcServer implements the gRPC API on the controller, as the handlers of the API v2
+type grpcServer struct {
+	pb.UnimplementedSimulatorServer
+}
+
+type grpcRoleKey struct{} // of the context of the calls
+
+// roles required by the methods, the others require admin
+var grpcRoles = map[string]string{
+	pb.Simulator_GetSimulation_FullMethodName:   models.RoleViewer,
+	pb.Simulator_Start_FullMethodName:           models.RoleOperator,
+	pb.Simulator_Stop_FullMethodName:            models.RoleOperator,
+	pb.Simulator_ListDevices_FullMethodName:     models.RoleViewer,
+	pb.Simulator_GetDevice_FullMethodName:       models.RoleViewer,
+	pb.Simulator_CreateDevice_FullMethodName:    models.RoleAdmin,
+	pb.Simulator_UpdateDevice_FullMethodName:    models.RoleAdmin,
+	pb.Simulator_DeleteDevice_FullMethodName:    models.RoleAdmin,
+	pb.Simulator_SetDeviceState_FullMethodName:  models.RoleOperator,
+	pb.Simulator_SendUplink_FullMethodName:      models.RoleOperator,
+	pb.Simulator_SendMACCommand_FullMethodName:  models.RoleOperator,
+	pb.Simulator_ListGateways_FullMethodName:    models.RoleViewer,
+	pb.Simulator_GetGateway_FullMethodName:      models.RoleViewer,
+	pb.Simulator_CreateGateway_FullMethodName:   models.RoleAdmin,
+	pb.Simulator_UpdateGateway_FullMethodName:   models.RoleAdmin,
+	pb.Simulator_DeleteGateway_FullMethodName:   models.RoleAdmin,
+	pb.Simulator_SetGatewayState_FullMethodName: models.RoleOperator,
+	pb.Simulator_StreamEvents_FullMethodName:    models.RoleViewer,
+}
+
+// runGRPC serves the gRPC API with the credentials and the certificate of the web server
+func (ws *WebServer) runGRPC() {
+
+	address := ws.Address + ":" + strconv.Itoa(configuration.GRPC.Port)
+
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpcUnaryAuth),
+		grpc.StreamInterceptor(grpcStreamAuth),
+	}
+
+	if configuration.TLS.Enable {
+
+		tlsConfig, err := configuration.TLS.Config()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cert, err := tls.LoadX509KeyPair(configuration.TLS.CertFile, configuration.TLS.KeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Println("[gRPC] [ERROR]:", err.Error())
+		return
+	}
+
+	server := grpc.NewServer(options...)
+	pb.RegisterSimulatorServer(server, &grpcServer{})
+
+	log.Println("[gRPC]: Listen [", address, "]")
+
+	err = server.Serve(listener)
+	if err != nil {
+		log.Println("[gRPC] [ERROR]:", err.Error())
+	}
+
+}
+
+//*******************************Authentication**************************************/
+
+// grpcAuthenticate checks the authorization metadata and adds the role to the context
+func grpcAuthenticate(ctx context.Context, method string) (context.Context, error) {
+
+	header := http.Header{}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		header.Add("Authorization", value)
+	}
+
+	role, ok := authenticate(header, url.Values{})
+	if !ok {
+		return nil, status.Error(gcodes.Unauthenticated, "Unauthorized")
+	}
+
+	required, ok := grpcRoles[method]
+	if !ok {
+		required = models.RoleAdmin
+	}
+
+	if !allowed(role, required) {
+		return nil, status.Error(gcodes.PermissionDenied, "Forbidden: "+required+" role required")
+	}
+
+	return context.WithValue(ctx, grpcRoleKey{}, role), nil
+}
+
+func grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	ctx, err := grpcAuthenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func grpcStreamAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	ctx, err := grpcAuthenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &grpcStream{ServerStream: stream, ctx: ctx})
+}
+
+// grpcStream carries the context with the role to the stream handlers
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcStream) Context() context.Context {
+	return s.ctx
+}
+
+func grpcCanReadKeys(ctx context.Context) bool {
+	role, _ := ctx.Value(grpcRoleKey{}).(string)
+	return allowed(role, models.RoleAdmin)
+}
+
+//*******************************Errors**************************************/
+
+// grpcCode maps the codes of the simulator to the gRPC codes, as httpStatus
+func grpcCode(code int) gcodes.Code {
+
+	switch code {
+	case codes.CodeErrorNotFound:
+		return gcodes.NotFound
+	case codes.CodeErrorName, codes.CodeErrorAddress:
+		return gcodes.AlreadyExists
+	case codes.CodeErrorDeviceActive, codes.CodeErrorGatewayActive, codes.CodeNoBridge,
+		codes.CodeErrorDeviceInactive, codes.CodeErrorStopped:
+		return gcodes.FailedPrecondition
+	case codes.CodeErrorConfiguration:
+		return gcodes.InvalidArgument
+	}
+
+	return gcodes.Internal
+}
+
+func grpcFail(code int, err error) error {
+	return status.Error(grpcCode(code), err.Error())
+}
+
+// grpcInvalid returns the invalid fields as the BadRequest details of the error
+func grpcInvalid(fields []fieldError) error {
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(fields))
+	for i, field := range fields {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Message,
+		}
+	}
+
+	st, err := status.New(gcodes.InvalidArgument, "Validation failed").
+		WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(gcodes.InvalidArgument, "Validation failed")
+	}
+
+	return st.Err()
+}
+
+func grpcDecode(data string, v interface{}) error {
+
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return status.Error(gcodes.InvalidArgument, "Invalid json: "+err.Error())
+	}
+
+	return nil
+}
+
+//*******************************Simulation**************************************/
+
+func (s *grpcServer) GetSimulation(ctx context.Context, _ *emptypb.Empty) (*pb.SimulationState, error) {
+	return &pb.SimulationState{Running: simulatorController.Status()}, nil
+}
+
+func (s *grpcServer) Start(ctx context.Context, _ *emptypb.Empty) (*pb.SimulationState, error) {
+	simulatorController.Run()
+	return &pb.SimulationState{Running: simulatorController.Status()}, nil
+}
+
+func (s *grpcServer) Stop(ctx context.Context, _ *emptypb.Empty) (*pb.SimulationState, error) {
+	simulatorController.Stop()
+	return &pb.SimulationState{Running: simulatorController.Status()}, nil
+}
+
+//*******************************Devices**************************************/
+
+func grpcDevice(device *dev.Device) (*pb.Device, error) {
+
+	data, err := json.Marshal(device)
+	if err != nil {
+		return nil, status.Error(gcodes.Internal, err.Error())
+	}
+
+	return &pb.Device{Id: int32(device.Id), Json: string(data)}, nil
+}
+
+func (s *grpcServer) ListDevices(ctx context.Context, _ *emptypb.Empty) (*pb.DeviceList, error) {
+
+	devices := simulatorController.GetDevices()
+	if !grpcCanReadKeys(ctx) {
+		hideKeys(devices)
+	}
+
+	list := pb.DeviceList{}
+
+	for i := range devices {
+
+		device, err := grpcDevice(&devices[i])
+		if err != nil {
+			return nil, err
+		}
+
+		list.Devices = append(list.Devices, device)
+	}
+
+	return &list, nil
+}
+
+func (s *grpcServer) GetDevice(ctx context.Context, req *pb.ComponentId) (*pb.Device, error) {
+
+	devices := simulatorController.GetDevices()
+	if !grpcCanReadKeys(ctx) {
+		hideKeys(devices)
+	}
+
+	for i := range devices {
+		if devices[i].Id == int(req.Id) {
+			return grpcDevice(&devices[i])
+		}
+	}
+
+	return nil, grpcFail(codes.CodeErrorNotFound, errors.New("Device not found"))
+}
+
+func (s *grpcServer) CreateDevice(ctx context.Context, req *pb.Device) (*pb.Device, error) {
+
+	var device dev.Device
+	if err := grpcDecode(req.Json, &device); err != nil {
+		return nil, err
+	}
+
+	if fields := validateDevice(&device); len(fields) > 0 {
+		return nil, grpcInvalid(fields)
+	}
+
+	code, id, err := simulatorController.AddDevice(&device)
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return s.GetDevice(ctx, &pb.ComponentId{Id: int32(id)})
+}
+
+func (s *grpcServer) UpdateDevice(ctx context.Context, req *pb.Device) (*pb.Device, error) {
+
+	var device dev.Device
+	if err := grpcDecode(req.Json, &device); err != nil {
+		return nil, err
+	}
+
+	device.Id = int(req.Id)
+
+	if fields := validateDevice(&device); len(fields) > 0 {
+		return nil, grpcInvalid(fields)
+	}
+
+	code, err := simulatorController.UpdateDevice(&device)
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return s.GetDevice(ctx, &pb.ComponentId{Id: req.Id})
+}
+
+func (s *grpcServer) DeleteDevice(ctx context.Context, req *pb.ComponentId) (*emptypb.Empty, error) {
+
+	code, err := simulatorController.DeleteDevice(int(req.Id))
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *grpcServer) SetDeviceState(ctx context.Context, req *pb.ComponentState) (*pb.ComponentState, error) {
+
+	code, err := simulatorController.SetStateDevice(int(req.Id), req.Active)
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return req, nil
+}
+
+func (s *grpcServer) SendUplink(ctx context.Context, req *pb.Uplink) (*emptypb.Empty, error) {
+
+	payload := socket.NewPayload{
+		Id:      int(req.Id),
+		MType:   "UnConfirmedDataUp",
+		Payload: req.Payload,
+	}
+
+	if req.Confirmed {
+		payload.MType = "ConfirmedDataUp"
+	}
+
+	code, err := simulatorController.SendUplink(payload)
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *grpcServer) SendMACCommand(ctx context.Context, req *pb.MACCommand) (*emptypb.Empty, error) {
+
+	cid, _, err := mac.ParseCID(req.Cid)
+	if err != nil {
+		return nil, grpcInvalid([]fieldError{{"cid", err.Error()}})
+	}
+
+	if req.Periodicity > 7 {
+		return nil, grpcInvalid([]fieldError{{"periodicity", "between 0 and 7 expected"}})
+	}
+
+	code, err := simulatorController.SendMACCommand(cid, socket.MacCommand{
+		Id:          int(req.Id),
+		CID:         req.Cid,
+		Periodicity: uint8(req.Periodicity),
+	})
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+//*******************************Gateways**************************************/
+
+func grpcGateway(gateway *gw.Gateway) (*pb.Gateway, error) {
+
+	data, err := json.Marshal(gateway)
+	if err != nil {
+		return nil, status.Error(gcodes.Internal, err.Error())
+	}
+
+	return &pb.Gateway{Id: int32(gateway.Id), Json: string(data)}, nil
+}
+
+func (s *grpcServer) ListGateways(ctx context.Context, _ *emptypb.Empty) (*pb.GatewayList, error) {
+
+	gateways := simulatorController.GetGateways()
+
+	list := pb.GatewayList{}
+
+	for i := range gateways {
+
+		gateway, err := grpcGateway(&gateways[i])
+		if err != nil {
+			return nil, err
+		}
+
+		list.Gateways = append(list.Gateways, gateway)
+	}
+
+	return &list, nil
+}
+
+func (s *grpcServer) GetGateway(ctx context.Context, req *pb.ComponentId) (*pb.Gateway, error) {
+
+	gateways := simulatorController.GetGateways()
+
+	for i := range gateways {
+		if gateways[i].Id == int(req.Id) {
+			return grpcGateway(&gateways[i])
+		}
+	}
+
+	return nil, grpcFail(codes.CodeErrorNotFound, errors.New("Gateway not found"))
+}
+
+func (s *grpcServer) CreateGateway(ctx context.Context, req *pb.Gateway) (*pb.Gateway, error) {
+
+	var gateway gw.Gateway
+	if err := grpcDecode(req.Json, &gateway); err != nil {
+		return nil, err
+	}
+
+	if fields := validateGateway(&gateway); len(fields) > 0 {
+		return nil, grpcInvalid(fields)
+	}
+
+	code, id, err := simulatorController.AddGateway(&gateway)
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return s.GetGateway(ctx, &pb.ComponentId{Id: int32(id)})
+}
+
+func (s *grpcServer) UpdateGateway(ctx context.Context, req *pb.Gateway) (*pb.Gateway, error) {
+
+	var gateway gw.Gateway
+	if err := grpcDecode(req.Json, &gateway); err != nil {
+		return nil, err
+	}
+
+	gateway.Id = int(req.Id)
+
+	if fields := validateGateway(&gateway); len(fields) > 0 {
+		return nil, grpcInvalid(fields)
+	}
+
+	code, err := simulatorController.UpdateGateway(&gateway)
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return s.GetGateway(ctx, &pb.ComponentId{Id: req.Id})
+}
+
+func (s *grpcServer) DeleteGateway(ctx context.Context, req *pb.ComponentId) (*emptypb.Empty, error) {
+
+	code, err := simulatorController.DeleteGateway(int(req.Id))
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *grpcServer) SetGatewayState(ctx context.Context, req *pb.ComponentState) (*pb.ComponentState, error) {
+
+	code, err := simulatorController.SetStateGateway(int(req.Id), req.Active)
+	if err != nil {
+		return nil, grpcFail(code, err)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,243 @@
+package webserver
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/arslab/lwnsimulator/simulator/events"
+	"github.com/arslab/lwnsimulator/webserver/pb"
+	"github.com/brocaar/lorawan"
+	gcodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// events queued for each stream, a stream that falls behind is closed
+const grpcQueueSize = 1024
+
+// eventFilter selects the events of a stream, the empty sets match everything
+type eventFilter struct {
+	types    map[string]bool
+	devices  map[int]bool
+	gateways map[int]bool
+	devEUIs  map[lorawan.EUI64]bool // of the devices, for the frames
+	macs     map[lorawan.EUI64]bool // of the gateways, for the frames
+}
+
+func newEventFilter(req *pb.StreamRequest) (*eventFilter, error) {
+
+	filter := eventFilter{
+		types:    make(map[string]bool),
+		devices:  make(map[int]bool),
+		gateways: make(map[int]bool),
+		devEUIs:  make(map[lorawan.EUI64]bool),
+		macs:     make(map[lorawan.EUI64]bool),
+	}
+
+	for _, t := range req.Types {
+		filter.types[t] = true
+	}
+
+	if len(req.Devices) > 0 {
+
+		devices := simulatorController.GetDevices()
+
+		for _, id := range req.Devices {
+
+			found := false
+
+			for i := range devices {
+				if devices[i].Id == int(id) {
+					filter.devices[devices[i].Id] = true
+					filter.devEUIs[devices[i].Info.DevEUI] = true
+					found = true
+				}
+			}
+
+			if !found {
+				return nil, status.Error(gcodes.NotFound, "Device "+strconv.Itoa(int(id))+" not found")
+			}
+
+		}
+	}
+
+	if len(req.Gateways) > 0 {
+
+		gateways := simulatorController.GetGateways()
+
+		for _, id := range req.Gateways {
+
+			found := false
+
+			for i := range gateways {
+				if gateways[i].Id == int(id) {
+					filter.gateways[gateways[i].Id] = true
+					filter.macs[gateways[i].Info.MACAddress] = true
+					found = true
+				}
+			}
+
+			if !found {
+				return nil, status.Error(gcodes.NotFound, "Gateway "+strconv.Itoa(int(id))+" not found")
+			}
+
+		}
+	}
+
+	return &filter, nil
+}
+
+func (f *eventFilter) match(e events.Event) bool {
+
+	if len(f.types) > 0 && !f.types[e.GetHeader().Type] {
+		return false
+	}
+
+	switch ev := e.(type) {
+
+	case *events.Log:
+
+		switch ev.Source {
+		case events.SourceDevice:
+			return f.wantsDevice(ev.Id)
+		case events.SourceGateway:
+			return f.wantsGateway(ev.Id)
+		}
+
+	case *events.Frame:
+
+		if len(f.devices) > 0 && (ev.Frame.DevEUI == nil || !f.devEUIs[*ev.Frame.DevEUI]) {
+			return false
+		}
+
+		return len(f.gateways) == 0 || f.macs[ev.Frame.Gateway]
+
+	case interface{ GetDevice() events.Device }:
+		return f.wantsDevice(ev.GetDevice().Id)
+
+	case interface{ GetGateway() events.Gateway }:
+		return f.wantsGateway(ev.GetGateway().Id)
+
+	}
+
+	return true
+}
+
+func (f *eventFilter) wantsDevice(id int) bool {
+	return len(f.devices) == 0 || f.devices[id]
+}
+
+func (f *eventFilter) wantsGateway(id int) bool {
+	return len(f.gateways) == 0 || f.gateways[id]
+}
+
+// grpcEvent encodes e, without keys the session keys are removed from the status of the devices
+func grpcEvent(e events.Event, keys bool) (*pb.Event, error) {
+
+	if ev, ok := e.(*events.DeviceState); ok && ev.Status != nil && !keys {
+
+		hidden := *ev
+		status := *ev.Status
+		status.NwkSKey = ""
+		status.AppSKey = ""
+		hidden.Status = &status
+
+		e = &hidden
+	}
+
+	if ev, ok := e.(*events.DeviceLiveState); ok && !keys {
+
+		hidden := *ev
+		hidden.State.NwkSKey = ""
+		hidden.State.AppSKey = ""
+
+		e = &hidden
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	header := e.GetHeader()
+
+	return &pb.Event{
+		Type: header.Type,
+		Time: timestamppb.New(header.Time),
+		Json: string(data),
+	}, nil
+}
+
+// StreamEvents sends the events in order until the client cancels. The bus must not block,
+// so the events are queued and a client that falls behind is closed rather than skipped
+func (s *grpcServer) StreamEvents(req *pb.StreamRequest, stream pb.Simulator_StreamEventsServer) error {
+
+	filter, err := newEventFilter(req)
+	if err != nil {
+		return err
+	}
+
+	keys := grpcCanReadKeys(stream.Context())
+
+	queue := make(chan events.Event, grpcQueueSize)
+	overflow := make(chan struct{})
+
+	var mutex sync.Mutex //the events are published by several goroutines
+	full := false
+
+	id := simulatorController.SubscribeEvents(func(e events.Event) {
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if full || !filter.match(e) {
+			return
+		}
+
+		select {
+		case queue <- e:
+		default:
+			full = true
+			close(overflow)
+		}
+
+	})
+	defer simulatorController.UnsubscribeEvents(id)
+
+	send := func(e events.Event) error {
+
+		event, err := grpcEvent(e, keys)
+		if err != nil {
+			return nil //not encodable, eg. NaN in a location
+		}
+
+		return stream.Send(event)
+	}
+
+	for {
+
+		select {
+
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+
+		case e := <-queue:
+			if err := send(e); err != nil {
+				return err
+			}
+
+		case <-overflow:
+
+			for len(queue) > 0 {
+				if err := send(<-queue); err != nil {
+					return err
+				}
+			}
+
+			return status.Error(gcodes.ResourceExhausted, "Too slow, the events would be dropped")
+		}
+
+	}
+
+}
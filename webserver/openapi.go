@@ -0,0 +1,519 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// route is an operation of the API v2, the OpenAPI document is generated from the routes
+type route struct {
+	method   string
+	path     string // gin syntax, eg. /devices/:id
+	role     string
+	summary  string
+	query    []string    // query parameters
+	body     interface{} // of the type of the request body, nil without body
+	response interface{} // of the type of the response body, nil without body
+	status   int         // of the success
+	handler  gin.HandlerFunc
+}
+
+// openAPI returns the OpenAPI 3 document of routes mounted on prefix
+func openAPI(prefix string, routes []route) map[string]interface{} {
+
+	paths := make(map[string]map[string]interface{})
+	schemas := make(map[string]interface{})
+
+	for _, r := range routes {
+
+		path, params := openAPIPath(prefix + r.path)
+
+		operation := map[string]interface{}{
+			"summary":     r.summary,
+			"description": "Requires the " + r.role + " role.",
+			"responses":   openAPIResponses(r, schemas),
+		}
+
+		var parameters []interface{}
+
+		for _, param := range params {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     param,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "integer"},
+			})
+		}
+
+		for _, param := range r.query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":   param,
+				"in":     "query",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		if r.body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaRef(r.body, schemas)},
+				},
+			}
+		}
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		paths[path][strings.ToLower(r.method)] = operation
+	}
+
+	schemas["Error"] = structSchema(reflect.TypeOf(apiError{}), reflect.Value{}, schemas)
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "LWN Simulator API",
+			"version": "2",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"basic":  map[string]interface{}{"type": "http", "scheme": "basic"},
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"basic": []string{}},
+			map[string]interface{}{"bearer": []string{}},
+		},
+	}
+}
+
+// openAPIPath converts the parameters of a gin path, eg. :id to {id}
+func openAPIPath(path string) (string, []string) {
+
+	var params []string
+
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+
+	return strings.Join(parts, "/"), params
+}
+
+func openAPIResponses(r route, schemas map[string]interface{}) map[string]interface{} {
+
+	success := map[string]interface{}{
+		"description": http.StatusText(r.status),
+	}
+
+	if r.response != nil {
+		success["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemaRef(r.response, schemas)},
+		}
+	}
+
+	failure := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		strconv.Itoa(r.status): success,
+		"default":              failure,
+	}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaRef returns the schema of the type of example, the named structs are added to the
+// components and referred to. example only completes the types with a custom JSON encoding
+func schemaRef(example interface{}, schemas map[string]interface{}) map[string]interface{} {
+	return schemaOfType(reflect.TypeOf(example), reflect.ValueOf(example), schemas)
+}
+
+// schemaOfType describes t, v is a value of t or invalid
+func schemaOfType(t reflect.Type, v reflect.Value, schemas map[string]interface{}) map[string]interface{} {
+
+	nullable := false
+
+	for t.Kind() == reflect.Ptr {
+
+		t = t.Elem()
+		nullable = true
+
+		if v.IsValid() {
+			if v.IsNil() {
+				v = reflect.Value{}
+			} else {
+				v = v.Elem()
+			}
+		}
+
+	}
+
+	schema := schemaOfKind(t, v, schemas)
+	if nullable && schema["$ref"] == nil {
+		schema["nullable"] = true
+	}
+
+	return schema
+}
+
+func schemaOfKind(t reflect.Type, v reflect.Value, schemas map[string]interface{}) map[string]interface{} {
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	if implements(t, textMarshalerType) && !implements(t, jsonMarshalerType) {
+		return map[string]interface{}{"type": "string"} //eg. the EUIs and the keys, in hex
+	}
+
+	switch t.Kind() {
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return map[string]interface{}{"type": "string", "format": "byte"} //base64
+		}
+
+		var item reflect.Value
+		if v.IsValid() && v.Len() > 0 {
+			item = v.Index(0)
+		}
+
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaOfType(t.Elem(), item, schemas),
+		}
+
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaOfType(t.Elem(), reflect.Value{}, schemas),
+		}
+
+	case reflect.Struct:
+
+		if t.Name() == "" {
+			return structSchema(t, v, schemas)
+		}
+
+		name := t.Name()
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = map[string]interface{}{} //while a field refers to it
+			schemas[name] = structSchema(t, v, schemas)
+		}
+
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+
+	}
+
+	return map[string]interface{}{} //any
+}
+
+func implements(t reflect.Type, i reflect.Type) bool {
+	return t.Implements(i) || reflect.PtrTo(t).Implements(i)
+}
+
+// structSchema describes the fields of t by their json tag, with the constraints of their
+// binding tag: required, oneof, min and max
+func structSchema(t reflect.Type, v reflect.Value, schemas map[string]interface{}) map[string]interface{} {
+
+	properties := make(map[string]interface{})
+	optional := make(map[string]bool) // omitted from the encoding when empty
+	var required []string
+
+	var fields func(t reflect.Type, v reflect.Value)
+	fields = func(t reflect.Type, v reflect.Value) {
+
+		for i := 0; i < t.NumField(); i++ {
+
+			field := t.Field(i)
+
+			var fv reflect.Value
+			if v.IsValid() {
+				fv = v.Field(i)
+			}
+
+			tag := field.Tag.Get("json")
+			name := strings.Split(tag, ",")[0]
+
+			if field.Anonymous && name == "" {
+
+				ft := field.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+					if fv.IsValid() && !fv.IsNil() {
+						fv = fv.Elem()
+					} else {
+						fv = reflect.Value{}
+					}
+				}
+
+				if ft.Kind() == reflect.Struct {
+					fields(ft, fv) //promoted fields
+					continue
+				}
+
+			}
+
+			if field.PkgPath != "" || name == "-" {
+				continue //unexported or not encoded
+			}
+
+			if name == "" {
+				name = field.Name
+			}
+
+			property := schemaOfType(field.Type, fv, schemas)
+			optional[name] = strings.Contains(tag, ",omitempty")
+
+			for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+
+				key, param := rule, ""
+				if j := strings.Index(rule, "="); j >= 0 {
+					key, param = rule[:j], rule[j+1:]
+				}
+
+				switch key {
+				case "required":
+					required = append(required, name)
+				case "oneof":
+					property["enum"] = enumValues(property["type"], param)
+				case "min", "max":
+					if n, err := strconv.ParseFloat(param, 64); err == nil {
+						property[bound(key, property["type"])] = n
+					}
+				}
+
+			}
+
+			properties[name] = property
+		}
+
+	}
+
+	fields(t, v)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	if implements(t, jsonMarshalerType) {
+		return withEncoding(schema, optional, t, v)
+	}
+
+	return schema
+}
+
+// enumValues converts the values of a oneof rule to the type of the property
+func enumValues(typeSchema interface{}, param string) []interface{} {
+
+	var values []interface{}
+
+	for _, value := range strings.Fields(param) {
+
+		if typeSchema == "integer" {
+			if n, err := strconv.Atoi(value); err == nil {
+				values = append(values, n)
+				continue
+			}
+		}
+
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// bound returns the keyword of a min or max rule, a length for the strings and the arrays
+func bound(rule string, typeSchema interface{}) string {
+
+	switch typeSchema {
+	case "string":
+		return rule + "Length"
+	case "array":
+		return rule + "Items"
+	}
+
+	if rule == "min" {
+		return "minimum"
+	}
+
+	return "maximum"
+}
+
+// withEncoding corrects the schema of a type with a custom JSON encoding by the encoding of
+// v, or of its zero value: the fields encoded with another type, eg. a key in hex or a
+// duration in seconds, take the type of their encoding
+func withEncoding(schema map[string]interface{}, optional map[string]bool, t reflect.Type, v reflect.Value) map[string]interface{} {
+
+	encoded, ok := encode(t, v)
+	if !ok {
+		return schema
+	}
+
+	object, ok := encoded.(map[string]interface{})
+	if !ok {
+		return schemaOfValue(encoded) //eg. a queue encoded as its list
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+
+	for name := range properties {
+		if _, ok := object[name]; !ok && !optional[name] {
+			delete(properties, name)
+		}
+	}
+
+	for name, value := range object {
+
+		inferred := schemaOfValue(value)
+
+		property, ok := properties[name].(map[string]interface{})
+		if ok && (value == nil || sameType(property, inferred)) {
+			continue
+		}
+
+		properties[name] = inferred
+	}
+
+	return schema
+}
+
+// encode returns the JSON encoding of v, of the zero value of t if v is invalid, decoded
+// with the numbers as json.Number. False if the encoding fails, or panics on a zero value
+func encode(t reflect.Type, v reflect.Value) (value interface{}, ok bool) {
+
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	ptr := reflect.New(t)
+	if v.IsValid() {
+		ptr.Elem().Set(v)
+	}
+
+	data, err := json.Marshal(ptr.Interface())
+	if err != nil {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+func sameType(schema map[string]interface{}, inferred map[string]interface{}) bool {
+
+	if schema["$ref"] != nil {
+		return inferred["type"] == "object"
+	}
+
+	switch schema["type"] {
+	case "integer", "number":
+		return inferred["type"] == "integer" || inferred["type"] == "number"
+	}
+
+	return schema["type"] == inferred["type"]
+}
+
+// schemaOfValue infers the schema of a decoded JSON value
+func schemaOfValue(value interface{}) map[string]interface{} {
+
+	switch v := value.(type) {
+
+	case map[string]interface{}:
+
+		properties := make(map[string]interface{})
+		for key, field := range v {
+			properties[key] = schemaOfValue(field)
+		}
+
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+
+	case []interface{}:
+
+		items := map[string]interface{}{}
+		if len(v) > 0 {
+			items = schemaOfValue(v[0])
+		}
+
+		return map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		}
+
+	case string:
+		return map[string]interface{}{"type": "string"}
+
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return map[string]interface{}{"type": "number"}
+		}
+		return map[string]interface{}{"type": "integer"}
+
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	}
+
+	return map[string]interface{}{"nullable": true}
+}
@@ -0,0 +1,805 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: simulator.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Simulator_GetSimulation_FullMethodName   = "/lwnsimulator.v1.Simulator/GetSimulation"
+	Simulator_Start_FullMethodName           = "/lwnsimulator.v1.Simulator/Start"
+	Simulator_Stop_FullMethodName            = "/lwnsimulator.v1.Simulator/Stop"
+	Simulator_ListDevices_FullMethodName     = "/lwnsimulator.v1.Simulator/ListDevices"
+	Simulator_GetDevice_FullMethodName       = "/lwnsimulator.v1.Simulator/GetDevice"
+	Simulator_CreateDevice_FullMethodName    = "/lwnsimulator.v1.Simulator/CreateDevice"
+	Simulator_UpdateDevice_FullMethodName    = "/lwnsimulator.v1.Simulator/UpdateDevice"
+	Simulator_DeleteDevice_FullMethodName    = "/lwnsimulator.v1.Simulator/DeleteDevice"
+	Simulator_SetDeviceState_FullMethodName  = "/lwnsimulator.v1.Simulator/SetDeviceState"
+	Simulator_SendUplink_FullMethodName      = "/lwnsimulator.v1.Simulator/SendUplink"
+	Simulator_SendMACCommand_FullMethodName  = "/lwnsimulator.v1.Simulator/SendMACCommand"
+	Simulator_ListGateways_FullMethodName    = "/lwnsimulator.v1.Simulator/ListGateways"
+	Simulator_GetGateway_FullMethodName      = "/lwnsimulator.v1.Simulator/GetGateway"
+	Simulator_CreateGateway_FullMethodName   = "/lwnsimulator.v1.Simulator/CreateGateway"
+	Simulator_UpdateGateway_FullMethodName   = "/lwnsimulator.v1.Simulator/UpdateGateway"
+	Simulator_DeleteGateway_FullMethodName   = "/lwnsimulator.v1.Simulator/DeleteGateway"
+	Simulator_SetGatewayState_FullMethodName = "/lwnsimulator.v1.Simulator/SetGatewayState"
+	Simulator_StreamEvents_FullMethodName    = "/lwnsimulator.v1.Simulator/StreamEvents"
+)
+
+// SimulatorClient is the client API for Simulator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SimulatorClient interface {
+	// Requires the viewer role
+	GetSimulation(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SimulationState, error)
+	// Requires the operator role
+	Start(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SimulationState, error)
+	// Requires the operator role
+	Stop(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SimulationState, error)
+	// Requires the viewer role, the keys only for admin
+	ListDevices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DeviceList, error)
+	// Requires the viewer role, the keys only for admin
+	GetDevice(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*Device, error)
+	// Requires the admin role
+	CreateDevice(ctx context.Context, in *Device, opts ...grpc.CallOption) (*Device, error)
+	// Requires the admin role, the device must be turned off
+	UpdateDevice(ctx context.Context, in *Device, opts ...grpc.CallOption) (*Device, error)
+	// Requires the admin role, the device must be turned off
+	DeleteDevice(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Requires the operator role
+	SetDeviceState(ctx context.Context, in *ComponentState, opts ...grpc.CallOption) (*ComponentState, error)
+	// Requires the operator role, queues an uplink of a turned on device
+	SendUplink(ctx context.Context, in *Uplink, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Requires the operator role, the command is sent with the next uplink
+	SendMACCommand(ctx context.Context, in *MACCommand, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Requires the viewer role
+	ListGateways(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GatewayList, error)
+	// Requires the viewer role
+	GetGateway(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*Gateway, error)
+	// Requires the admin role
+	CreateGateway(ctx context.Context, in *Gateway, opts ...grpc.CallOption) (*Gateway, error)
+	// Requires the admin role, the gateway must be turned off
+	UpdateGateway(ctx context.Context, in *Gateway, opts ...grpc.CallOption) (*Gateway, error)
+	// Requires the admin role, the gateway must be turned off
+	DeleteGateway(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Requires the operator role
+	SetGatewayState(ctx context.Context, in *ComponentState, opts ...grpc.CallOption) (*ComponentState, error)
+	// Requires the viewer role, streams the events and the frames on the air until the client cancels.
+	// A client that does not keep up is disconnected with RESOURCE_EXHAUSTED instead of losing events.
+	StreamEvents(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Simulator_StreamEventsClient, error)
+}
+
+type simulatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSimulatorClient(cc grpc.ClientConnInterface) SimulatorClient {
+	return &simulatorClient{cc}
+}
+
+func (c *simulatorClient) GetSimulation(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SimulationState, error) {
+	out := new(SimulationState)
+	err := c.cc.Invoke(ctx, Simulator_GetSimulation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) Start(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SimulationState, error) {
+	out := new(SimulationState)
+	err := c.cc.Invoke(ctx, Simulator_Start_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) Stop(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SimulationState, error) {
+	out := new(SimulationState)
+	err := c.cc.Invoke(ctx, Simulator_Stop_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) ListDevices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DeviceList, error) {
+	out := new(DeviceList)
+	err := c.cc.Invoke(ctx, Simulator_ListDevices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) GetDevice(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*Device, error) {
+	out := new(Device)
+	err := c.cc.Invoke(ctx, Simulator_GetDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) CreateDevice(ctx context.Context, in *Device, opts ...grpc.CallOption) (*Device, error) {
+	out := new(Device)
+	err := c.cc.Invoke(ctx, Simulator_CreateDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) UpdateDevice(ctx context.Context, in *Device, opts ...grpc.CallOption) (*Device, error) {
+	out := new(Device)
+	err := c.cc.Invoke(ctx, Simulator_UpdateDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) DeleteDevice(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Simulator_DeleteDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) SetDeviceState(ctx context.Context, in *ComponentState, opts ...grpc.CallOption) (*ComponentState, error) {
+	out := new(ComponentState)
+	err := c.cc.Invoke(ctx, Simulator_SetDeviceState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) SendUplink(ctx context.Context, in *Uplink, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Simulator_SendUplink_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) SendMACCommand(ctx context.Context, in *MACCommand, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Simulator_SendMACCommand_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) ListGateways(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GatewayList, error) {
+	out := new(GatewayList)
+	err := c.cc.Invoke(ctx, Simulator_ListGateways_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) GetGateway(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*Gateway, error) {
+	out := new(Gateway)
+	err := c.cc.Invoke(ctx, Simulator_GetGateway_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) CreateGateway(ctx context.Context, in *Gateway, opts ...grpc.CallOption) (*Gateway, error) {
+	out := new(Gateway)
+	err := c.cc.Invoke(ctx, Simulator_CreateGateway_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) UpdateGateway(ctx context.Context, in *Gateway, opts ...grpc.CallOption) (*Gateway, error) {
+	out := new(Gateway)
+	err := c.cc.Invoke(ctx, Simulator_UpdateGateway_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) DeleteGateway(ctx context.Context, in *ComponentId, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Simulator_DeleteGateway_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) SetGatewayState(ctx context.Context, in *ComponentState, opts ...grpc.CallOption) (*ComponentState, error) {
+	out := new(ComponentState)
+	err := c.cc.Invoke(ctx, Simulator_SetGatewayState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) StreamEvents(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Simulator_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Simulator_ServiceDesc.Streams[0], Simulator_StreamEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &simulatorStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Simulator_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type simulatorStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *simulatorStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SimulatorServer is the server API for Simulator service.
+// All implementations must embed UnimplementedSimulatorServer
+// for forward compatibility
+type SimulatorServer interface {
+	// Requires the viewer role
+	GetSimulation(context.Context, *emptypb.Empty) (*SimulationState, error)
+	// Requires the operator role
+	Start(context.Context, *emptypb.Empty) (*SimulationState, error)
+	// Requires the operator role
+	Stop(context.Context, *emptypb.Empty) (*SimulationState, error)
+	// Requires the viewer role, the keys only for admin
+	ListDevices(context.Context, *emptypb.Empty) (*DeviceList, error)
+	// Requires the viewer role, the keys only for admin
+	GetDevice(context.Context, *ComponentId) (*Device, error)
+	// Requires the admin role
+	CreateDevice(context.Context, *Device) (*Device, error)
+	// Requires the admin role, the device must be turned off
+	UpdateDevice(context.Context, *Device) (*Device, error)
+	// Requires the admin role, the device must be turned off
+	DeleteDevice(context.Context, *ComponentId) (*emptypb.Empty, error)
+	// Requires the operator role
+	SetDeviceState(context.Context, *ComponentState) (*ComponentState, error)
+	// Requires the operator role, queues an uplink of a turned on device
+	SendUplink(context.Context, *Uplink) (*emptypb.Empty, error)
+	// Requires the operator role, the command is sent with the next uplink
+	SendMACCommand(context.Context, *MACCommand) (*emptypb.Empty, error)
+	// Requires the viewer role
+	ListGateways(context.Context, *emptypb.Empty) (*GatewayList, error)
+	// Requires the viewer role
+	GetGateway(context.Context, *ComponentId) (*Gateway, error)
+	// Requires the admin role
+	CreateGateway(context.Context, *Gateway) (*Gateway, error)
+	// Requires the admin role, the gateway must be turned off
+	UpdateGateway(context.Context, *Gateway) (*Gateway, error)
+	// Requires the admin role, the gateway must be turned off
+	DeleteGateway(context.Context, *ComponentId) (*emptypb.Empty, error)
+	// Requires the operator role
+	SetGatewayState(context.Context, *ComponentState) (*ComponentState, error)
+	// Requires the viewer role, streams the events and the frames on the air until the client cancels.
+	// A client that does not keep up is disconnected with RESOURCE_EXHAUSTED instead of losing events.
+	StreamEvents(*StreamRequest, Simulator_StreamEventsServer) error
+	mustEmbedUnimplementedSimulatorServer()
+}
+
+// UnimplementedSimulatorServer must be embedded to have forward compatible implementations.
+type UnimplementedSimulatorServer struct {
+}
+
+func (UnimplementedSimulatorServer) GetSimulation(context.Context, *emptypb.Empty) (*SimulationState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSimulation not implemented")
+}
+func (UnimplementedSimulatorServer) Start(context.Context, *emptypb.Empty) (*SimulationState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedSimulatorServer) Stop(context.Context, *emptypb.Empty) (*SimulationState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedSimulatorServer) ListDevices(context.Context, *emptypb.Empty) (*DeviceList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedSimulatorServer) GetDevice(context.Context, *ComponentId) (*Device, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDevice not implemented")
+}
+func (UnimplementedSimulatorServer) CreateDevice(context.Context, *Device) (*Device, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDevice not implemented")
+}
+func (UnimplementedSimulatorServer) UpdateDevice(context.Context, *Device) (*Device, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateDevice not implemented")
+}
+func (UnimplementedSimulatorServer) DeleteDevice(context.Context, *ComponentId) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDevice not implemented")
+}
+func (UnimplementedSimulatorServer) SetDeviceState(context.Context, *ComponentState) (*ComponentState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetDeviceState not implemented")
+}
+func (UnimplementedSimulatorServer) SendUplink(context.Context, *Uplink) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendUplink not implemented")
+}
+func (UnimplementedSimulatorServer) SendMACCommand(context.Context, *MACCommand) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendMACCommand not implemented")
+}
+func (UnimplementedSimulatorServer) ListGateways(context.Context, *emptypb.Empty) (*GatewayList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGateways not implemented")
+}
+func (UnimplementedSimulatorServer) GetGateway(context.Context, *ComponentId) (*Gateway, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGateway not implemented")
+}
+func (UnimplementedSimulatorServer) CreateGateway(context.Context, *Gateway) (*Gateway, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateGateway not implemented")
+}
+func (UnimplementedSimulatorServer) UpdateGateway(context.Context, *Gateway) (*Gateway, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateGateway not implemented")
+}
+func (UnimplementedSimulatorServer) DeleteGateway(context.Context, *ComponentId) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGateway not implemented")
+}
+func (UnimplementedSimulatorServer) SetGatewayState(context.Context, *ComponentState) (*ComponentState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetGatewayState not implemented")
+}
+func (UnimplementedSimulatorServer) StreamEvents(*StreamRequest, Simulator_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedSimulatorServer) mustEmbedUnimplementedSimulatorServer() {}
+
+// UnsafeSimulatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SimulatorServer will
+// result in compilation errors.
+type UnsafeSimulatorServer interface {
+	mustEmbedUnimplementedSimulatorServer()
+}
+
+func RegisterSimulatorServer(s grpc.ServiceRegistrar, srv SimulatorServer) {
+	s.RegisterService(&Simulator_ServiceDesc, srv)
+}
+
+func _Simulator_GetSimulation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).GetSimulation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_GetSimulation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).GetSimulation(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_Start_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_Start_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).Start(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_Stop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).Stop(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).ListDevices(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_GetDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComponentId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).GetDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_GetDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).GetDevice(ctx, req.(*ComponentId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_CreateDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Device)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).CreateDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_CreateDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).CreateDevice(ctx, req.(*Device))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_UpdateDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Device)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).UpdateDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_UpdateDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).UpdateDevice(ctx, req.(*Device))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_DeleteDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComponentId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).DeleteDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_DeleteDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).DeleteDevice(ctx, req.(*ComponentId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_SetDeviceState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComponentState)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).SetDeviceState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_SetDeviceState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).SetDeviceState(ctx, req.(*ComponentState))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_SendUplink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Uplink)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).SendUplink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_SendUplink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).SendUplink(ctx, req.(*Uplink))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_SendMACCommand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MACCommand)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).SendMACCommand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_SendMACCommand_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).SendMACCommand(ctx, req.(*MACCommand))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_ListGateways_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).ListGateways(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_ListGateways_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).ListGateways(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_GetGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComponentId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).GetGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_GetGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).GetGateway(ctx, req.(*ComponentId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_CreateGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Gateway)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).CreateGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_CreateGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).CreateGateway(ctx, req.(*Gateway))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_UpdateGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Gateway)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).UpdateGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_UpdateGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).UpdateGateway(ctx, req.(*Gateway))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_DeleteGateway_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComponentId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).DeleteGateway(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_DeleteGateway_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).DeleteGateway(ctx, req.(*ComponentId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_SetGatewayState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComponentState)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).SetGatewayState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_SetGatewayState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).SetGatewayState(ctx, req.(*ComponentState))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SimulatorServer).StreamEvents(m, &simulatorStreamEventsServer{stream})
+}
+
+type Simulator_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type simulatorStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *simulatorStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Simulator_ServiceDesc is the grpc.ServiceDesc for Simulator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Simulator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lwnsimulator.v1.Simulator",
+	HandlerType: (*SimulatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSimulation",
+			Handler:    _Simulator_GetSimulation_Handler,
+		},
+		{
+			MethodName: "Start",
+			Handler:    _Simulator_Start_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _Simulator_Stop_Handler,
+		},
+		{
+			MethodName: "ListDevices",
+			Handler:    _Simulator_ListDevices_Handler,
+		},
+		{
+			MethodName: "GetDevice",
+			Handler:    _Simulator_GetDevice_Handler,
+		},
+		{
+			MethodName: "CreateDevice",
+			Handler:    _Simulator_CreateDevice_Handler,
+		},
+		{
+			MethodName: "UpdateDevice",
+			Handler:    _Simulator_UpdateDevice_Handler,
+		},
+		{
+			MethodName: "DeleteDevice",
+			Handler:    _Simulator_DeleteDevice_Handler,
+		},
+		{
+			MethodName: "SetDeviceState",
+			Handler:    _Simulator_SetDeviceState_Handler,
+		},
+		{
+			MethodName: "SendUplink",
+			Handler:    _Simulator_SendUplink_Handler,
+		},
+		{
+			MethodName: "SendMACCommand",
+			Handler:    _Simulator_SendMACCommand_Handler,
+		},
+		{
+			MethodName: "ListGateways",
+			Handler:    _Simulator_ListGateways_Handler,
+		},
+		{
+			MethodName: "GetGateway",
+			Handler:    _Simulator_GetGateway_Handler,
+		},
+		{
+			MethodName: "CreateGateway",
+			Handler:    _Simulator_CreateGateway_Handler,
+		},
+		{
+			MethodName: "UpdateGateway",
+			Handler:    _Simulator_UpdateGateway_Handler,
+		},
+		{
+			MethodName: "DeleteGateway",
+			Handler:    _Simulator_DeleteGateway_Handler,
+		},
+		{
+			MethodName: "SetGatewayState",
+			Handler:    _Simulator_SetGatewayState_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Simulator_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "simulator.proto",
+}
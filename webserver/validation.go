@@ -0,0 +1,205 @@
+package webserver
+
+import (
+	"fmt"
+	"strconv"
+
+	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	devm "github.com/arslab/lwnsimulator/simulator/components/device/models"
+	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
+	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	loc "github.com/arslab/lwnsimulator/simulator/resources/location"
+	"github.com/brocaar/lorawan"
+)
+
+// validateDevice returns the invalid fields of the device, the uniqueness is checked by the simulator
+func validateDevice(device *dev.Device) []fieldError {
+
+	var fields []fieldError
+
+	info := &device.Info
+	conf := &info.Configuration
+
+	if info.Name == "" {
+		fields = append(fields, fieldError{"info.name", "required"})
+	}
+
+	if info.DevEUI == (lorawan.EUI64{}) {
+		fields = append(fields, fieldError{"info.devEUI", "required"})
+	}
+
+	if conf.SupportedOtaa {
+
+		if info.AppKey == [16]byte{} {
+			fields = append(fields, fieldError{"info.appKey", "required with OTAA"})
+		}
+
+	} else {
+
+		if info.DevAddr == (lorawan.DevAddr{}) {
+			fields = append(fields, fieldError{"info.devAddr", "required with ABP"})
+		}
+
+		if info.NwkSKey == [16]byte{} {
+			fields = append(fields, fieldError{"info.nwkSKey", "required with ABP"})
+		}
+
+		if info.AppSKey == [16]byte{} {
+			fields = append(fields, fieldError{"info.appSKey", "required with ABP"})
+		}
+
+	}
+
+	if conf.Region == nil {
+
+		fields = append(fields, fieldError{"info.configuration.region", "unknown region"})
+
+	} else {
+
+		region := rp.GetRegionalParameters(conf.Region.GetCode())
+		region.Setup()
+
+		if err := region.DataRateSupported(conf.DataRateInitial); err != nil {
+			fields = append(fields, fieldError{"info.configuration.dataRate", err.Error()})
+		}
+
+		if err := region.RX1DROffsetSupported(conf.RX1DROffset); err != nil {
+			fields = append(fields, fieldError{"info.configuration.rx1DROffset", err.Error()})
+		}
+
+	}
+
+	if conf.SendInterval <= 0 {
+		fields = append(fields, fieldError{"info.configuration.sendInterval", "positive number of seconds expected"})
+	}
+
+	if conf.AckTimeout < 0 {
+		fields = append(fields, fieldError{"info.configuration.ackTimeout", "non-negative number of seconds expected"})
+	}
+
+	if conf.Range <= 0 {
+		fields = append(fields, fieldError{"info.configuration.range", "positive number of meters expected"})
+	}
+
+	if conf.NbRepConfirmedDataUp < 0 {
+		fields = append(fields, fieldError{"info.configuration.nbRetransmission", "non-negative number expected"})
+	}
+
+	if len(info.RX) < 2 {
+		fields = append(fields, fieldError{"info.rxs", "RX1 and RX2 expected"})
+	}
+
+	fields = append(fields, validateLocation("info.location.", info.Location)...)
+	fields = append(fields, validateMACSchedules("info.configuration.macSchedules", conf.MACSchedules)...)
+
+	return fields
+}
+
+// validateMACSchedules checks the MAC commands of the schedules, prefix names the list
+func validateMACSchedules(prefix string, schedules []devm.MACSchedule) []fieldError {
+
+	var fields []fieldError
+
+	for i := range schedules {
+		if _, err := schedules[i].Command(); err != nil {
+			fields = append(fields, fieldError{fmt.Sprintf("%v[%v]", prefix, i), err.Error()})
+		}
+	}
+
+	return fields
+}
+
+func validateGateway(gateway *gw.Gateway) []fieldError {
+
+	var fields []fieldError
+
+	info := &gateway.Info
+
+	if info.Name == "" {
+		fields = append(fields, fieldError{"info.name", "required"})
+	}
+
+	if info.MACAddress == (lorawan.EUI64{}) {
+		fields = append(fields, fieldError{"info.macAddress", "required"})
+	}
+
+	if info.TypeGateway { //real
+
+		if info.AddrIP == "" {
+			fields = append(fields, fieldError{"info.ip", "required with a real gateway"})
+		}
+
+		if port, err := strconv.Atoi(info.Port); err != nil || port < 1 || port > 65535 {
+			fields = append(fields, fieldError{"info.port", "port number expected"})
+		}
+
+	} else {
+
+		if info.KeepAlive <= 0 {
+			fields = append(fields, fieldError{"info.keepAlive", "positive number of seconds expected"})
+		}
+
+	}
+
+	if info.StatInterval < 0 {
+		fields = append(fields, fieldError{"info.statInterval", "non-negative number of seconds expected"})
+	}
+
+	if info.BatchWindow < 0 {
+		fields = append(fields, fieldError{"info.batchWindow", "non-negative number of milliseconds expected"})
+	}
+
+	fields = append(fields, validateLocation("info.location.", info.Location)...)
+	fields = append(fields, validateImpairment("info.impairment.", &info.Impairment)...)
+
+	return fields
+}
+
+// validateLocation checks the coordinates, prefix is the path of the location in the body
+func validateLocation(prefix string, location loc.Location) []fieldError {
+
+	var fields []fieldError
+
+	if location.Latitude < -90 || location.Latitude > 90 {
+		fields = append(fields, fieldError{prefix + "latitude", "between -90 and 90 expected"})
+	}
+
+	if location.Longitude < -180 || location.Longitude > 180 {
+		fields = append(fields, fieldError{prefix + "longitude", "between -180 and 180 expected"})
+	}
+
+	return fields
+}
+
+func validateImpairment(prefix string, impairment *udp.Impairment) []fieldError {
+
+	var fields []fieldError
+
+	percentages := []struct {
+		name  string
+		value float64
+	}{
+		{"loss", impairment.Loss},
+		{"duplication", impairment.Duplication},
+		{"reordering", impairment.Reordering},
+	}
+
+	for _, p := range percentages {
+		if p.value < 0 || p.value > 100 {
+			fields = append(fields, fieldError{prefix + p.name, "percentage between 0 and 100 expected"})
+		}
+	}
+
+	if impairment.Latency < 0 || impairment.Jitter < 0 || impairment.ReorderDelay < 0 {
+		fields = append(fields, fieldError{prefix + "latency", "non-negative delays expected"})
+	}
+
+	for i, outage := range impairment.Outages {
+		if outage.Start < 0 || outage.Duration <= 0 {
+			fields = append(fields, fieldError{prefix + "outages." + strconv.Itoa(i), "non-negative start and positive duration expected"})
+		}
+	}
+
+	return fields
+}
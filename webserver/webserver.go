@@ -1,21 +1,29 @@
 package webserver
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strconv"
+	"time"
 
 	cnt "github.com/arslab/lwnsimulator/controllers"
 	"github.com/arslab/lwnsimulator/models"
 	dev "github.com/arslab/lwnsimulator/simulator/components/device"
+	mac "github.com/arslab/lwnsimulator/simulator/components/device/macCommands"
 	rp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters"
 	mrp "github.com/arslab/lwnsimulator/simulator/components/device/regional_parameters/models_rp"
 	gw "github.com/arslab/lwnsimulator/simulator/components/gateway"
+	"github.com/arslab/lwnsimulator/simulator/resources/communication/udp"
+	"github.com/arslab/lwnsimulator/simulator/resources/framelog"
 	"github.com/arslab/lwnsimulator/socket"
 	_ "github.com/arslab/lwnsimulator/webserver/statik"
 	"github.com/brocaar/lorawan"
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	socketio "github.com/googollee/go-socket.io"
 	"github.com/rakyll/statik/fs"
@@ -36,11 +44,13 @@ var (
 
 func NewWebServer(config *models.ServerConfig, controller cnt.SimulatorController) *WebServer {
 
-	serverSocket := newServerSocket()
-
 	configuration = config
 	simulatorController = controller
 
+	checkAuth(configuration.Auth)
+
+	serverSocket := newServerSocket()
+
 	go func() {
 
 		err := serverSocket.Serve()
@@ -54,13 +64,9 @@ func NewWebServer(config *models.ServerConfig, controller cnt.SimulatorControlle
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	configCors := cors.DefaultConfig()
-	configCors.AllowAllOrigins = true
-	configCors.AllowHeaders = []string{"Origin", "Access-Control-Allow-Origin",
-		"Access-Control-Allow-Headers", "Content-type"}
-	configCors.AllowMethods = []string{"GET", "POST", "DELETE", "OPTIONS"}
-	configCors.AllowCredentials = true
-	router.Use(cors.New(configCors))
+	if handler := newCors(configuration.Cors); handler != nil {
+		router.Use(handler)
+	}
 
 	router.Use(gin.Recovery())
 
@@ -76,29 +82,38 @@ func NewWebServer(config *models.ServerConfig, controller cnt.SimulatorControlle
 		log.Fatal(err)
 	}
 
-	staticGroup := router.Group("/dashboard")
+	staticGroup := router.Group("/dashboard", authorize(models.RoleViewer))
 	staticGroup.StaticFS("/", staticFS)
 	//router.Use(static.Serve("/", staticFS))
 
 	apiRoutes := router.Group("/api")
 	{
-		apiRoutes.GET("/start", startSimulator)
-		apiRoutes.GET("/stop", stopSimulator)
-		apiRoutes.GET("/status", simulatorStatus)
-		apiRoutes.GET("/bridge", getRemoteAddress)
-		apiRoutes.GET("/gateways", getGateways)
-		apiRoutes.GET("/devices", getDevices)
-		apiRoutes.POST("/add-device", addDevice)
-		apiRoutes.POST("/up-device", updateDevice)
-		apiRoutes.POST("/del-device", deleteDevice)
-		apiRoutes.POST("/del-gateway", deleteGateway)
-		apiRoutes.POST("/add-gateway", addGateway)
-		apiRoutes.POST("/up-gateway", updateGateway)
-		apiRoutes.POST("/bridge/save", saveInfoBridge)
+		apiRoutes.GET("/start", authorize(models.RoleOperator), startSimulator)
+		apiRoutes.GET("/stop", authorize(models.RoleOperator), stopSimulator)
+		apiRoutes.GET("/status", authorize(models.RoleViewer), simulatorStatus)
+		apiRoutes.GET("/bridge", authorize(models.RoleViewer), getRemoteAddress)
+		apiRoutes.GET("/gateways", authorize(models.RoleViewer), getGateways)
+		apiRoutes.GET("/gateways/connection", authorize(models.RoleViewer), getGatewaysConnection)
+		apiRoutes.GET("/devices", authorize(models.RoleViewer), getDevices)
+		apiRoutes.POST("/add-device", authorize(models.RoleAdmin), addDevice)
+		apiRoutes.POST("/up-device", authorize(models.RoleAdmin), updateDevice)
+		apiRoutes.POST("/del-device", authorize(models.RoleAdmin), deleteDevice)
+		apiRoutes.POST("/del-gateway", authorize(models.RoleAdmin), deleteGateway)
+		apiRoutes.POST("/add-gateway", authorize(models.RoleAdmin), addGateway)
+		apiRoutes.POST("/up-gateway", authorize(models.RoleAdmin), updateGateway)
+		apiRoutes.POST("/bridge/save", authorize(models.RoleAdmin), saveInfoBridge)
+		apiRoutes.POST("/impairment-gateway", authorize(models.RoleAdmin), setImpairmentGateway)
+		apiRoutes.POST("/concentrator-gateway", authorize(models.RoleAdmin), importConcentratorGateway)
+		apiRoutes.GET("/pcap", authorize(models.RoleViewer), getPcap)
+		apiRoutes.POST("/pcap", authorize(models.RoleOperator), setPcap)
+		apiRoutes.GET("/pcap/download", authorize(models.RoleViewer), downloadPcap)
+		apiRoutes.GET("/frames", authorize(models.RoleViewer), getFrames)
 	}
 
-	router.GET("/socket.io/*any", gin.WrapH(serverSocket))
-	router.POST("/socket.io/*any", gin.WrapH(serverSocket))
+	registerV2(router)
+
+	router.GET("/socket.io/*any", authorizeHandshake(models.RoleViewer), gin.WrapH(serverSocket))
+	router.POST("/socket.io/*any", authorizeHandshake(models.RoleViewer), gin.WrapH(serverSocket))
 
 	router.GET("/", func(context *gin.Context) { context.Redirect(http.StatusMovedPermanently, "/dashboard") })
 
@@ -135,6 +150,10 @@ func getGateways(c *gin.Context) {
 	c.JSON(http.StatusOK, gws)
 }
 
+func getGatewaysConnection(c *gin.Context) {
+	c.JSON(http.StatusOK, simulatorController.GetGatewaysConnection())
+}
+
 func addGateway(c *gin.Context) {
 
 	var g gw.Gateway
@@ -167,12 +186,153 @@ func deleteGateway(c *gin.Context) {
 
 	c.BindJSON(&Identifier)
 
-	c.JSON(http.StatusOK, gin.H{"status": simulatorController.DeleteGateway(Identifier.Id)})
+	_, err := simulatorController.DeleteGateway(Identifier.Id)
+
+	c.JSON(http.StatusOK, gin.H{"status": err == nil})
+
+}
+
+func setImpairmentGateway(c *gin.Context) {
+
+	data := struct {
+		Id         int            `json:"id"`
+		Impairment udp.Impairment `json:"impairment"`
+	}{}
+
+	c.BindJSON(&data)
+
+	code, err := simulatorController.SetImpairmentGateway(data.Id, data.Impairment)
+	errString := fmt.Sprintf("%v", err)
+
+	c.JSON(http.StatusOK, gin.H{"status": errString, "code": code})
+
+}
+
+func importConcentratorGateway(c *gin.Context) {
+
+	data := struct {
+		Id         int             `json:"id"`
+		GlobalConf json.RawMessage `json:"globalConf"` // content of the packet forwarder's global_conf.json
+	}{}
+
+	c.BindJSON(&data)
+
+	code, err := simulatorController.ImportConcentratorGateway(data.Id, data.GlobalConf)
+	errString := fmt.Sprintf("%v", err)
+
+	c.JSON(http.StatusOK, gin.H{"status": errString, "code": code})
+
+}
+
+func getPcap(c *gin.Context) {
+	c.JSON(http.StatusOK, simulatorController.GetPcap())
+}
+
+func setPcap(c *gin.Context) {
+
+	var pcap models.Pcap
+	c.BindJSON(&pcap)
+
+	err := simulatorController.SetPcap(pcap.Enable)
+	errString := fmt.Sprintf("%v", err)
+
+	c.JSON(http.StatusOK, gin.H{"status": errString})
+
+}
+
+func downloadPcap(c *gin.Context) {
+
+	pcap := simulatorController.GetPcap()
+	if pcap.File == "" {
+		c.JSON(http.StatusNotFound, gin.H{"status": "No capture"})
+		return
+	}
+
+	c.FileAttachment(pcap.File, filepath.Base(pcap.File))
+
+}
+
+// getFrames returns the frame log, filtered by devEUI, type (uplink or downlink),
+// from and to (RFC3339) and limit (the last records)
+func getFrames(c *gin.Context) {
+
+	filter, field := parseFrameFilter(c)
+	if field != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Invalid " + field.Field})
+		return
+	}
+
+	frames, err := simulatorController.GetFrames(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, frames)
+}
+
+// parseFrameFilter returns the filter of the query, or its invalid parameter
+func parseFrameFilter(c *gin.Context) (framelog.Filter, *fieldError) {
+
+	var filter framelog.Filter
+	var err error
+
+	if devEUI := c.Query("devEUI"); devEUI != "" {
+
+		var eui lorawan.EUI64
+		if err = eui.UnmarshalText([]byte(devEUI)); err != nil {
+			return filter, &fieldError{"devEUI", "EUI64 expected"}
+		}
+
+		filter.DevEUI = &eui
+	}
+
+	filter.Type = c.Query("type")
+	if filter.Type != "" && filter.Type != framelog.TypeUplink && filter.Type != framelog.TypeDownlink {
+		return filter, &fieldError{"type", framelog.TypeUplink + " or " + framelog.TypeDownlink + " expected"}
+	}
+
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, &fieldError{"from", "RFC3339 time expected"}
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, &fieldError{"to", "RFC3339 time expected"}
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return filter, &fieldError{"limit", "non-negative integer expected"}
+		}
+	}
 
+	return filter, nil
 }
 
 func getDevices(c *gin.Context) {
-	c.JSON(http.StatusOK, simulatorController.GetDevices())
+
+	devices := simulatorController.GetDevices()
+
+	if !canReadKeys(c) {
+		hideKeys(devices)
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+// hideKeys clears the keys of the devices
+func hideKeys(devices []dev.Device) {
+
+	for i := range devices {
+		devices[i].Info.AppKey = [16]byte{}
+		devices[i].Info.NwkSKey = [16]byte{}
+		devices[i].Info.AppSKey = [16]byte{}
+	}
+
 }
 
 func addDevice(c *gin.Context) {
@@ -207,7 +367,9 @@ func deleteDevice(c *gin.Context) {
 
 	c.BindJSON(&Identifier)
 
-	c.JSON(http.StatusOK, gin.H{"status": simulatorController.DeleteDevice(Identifier.Id)})
+	_, err := simulatorController.DeleteDevice(Identifier.Id)
+
+	c.JSON(http.StatusOK, gin.H{"status": err == nil})
 }
 
 func newServerSocket() *socketio.Server {
@@ -216,46 +378,77 @@ func newServerSocket() *socketio.Server {
 
 	serverSocket.OnConnect("/", func(s socketio.Conn) error {
 
+		address := s.URL()
+
+		role, ok := authenticate(s.RemoteHeader(), address.Query())
+		if !ok {
+			return errors.New("Unauthorized")
+		}
+
 		log.Println("[WS]: Socket connected")
 
-		s.SetContext("")
-		simulatorController.AddWebSocket(&s)
+		s.SetContext(role)
+		simulatorController.AddWebSocket(&s, allowed(role, models.RoleAdmin))
 
 		return nil
 
 	})
 
 	serverSocket.OnDisconnect("/", func(s socketio.Conn, reason string) {
+		simulatorController.RemoveWebSocket(s.ID())
 		s.Close()
 	})
 
+	serverSocket.OnEvent("/", socket.EventSubscribe, func(s socketio.Conn, sub socket.Subscription) bool {
+		return simulatorController.SubscribeWebSocket(s.ID(), sub)
+	})
+
 	serverSocket.OnEvent("/", socket.EventToggleStateDevice, func(s socketio.Conn, Id int) {
+
+		if !authorizeSocket(s, models.RoleOperator) {
+			return
+		}
+
 		simulatorController.ToggleStateDevice(Id)
 	})
 
 	serverSocket.OnEvent("/", socket.EventToggleStateGateway, func(s socketio.Conn, Id int) {
+
+		if !authorizeSocket(s, models.RoleOperator) {
+			return
+		}
+
 		simulatorController.ToggleStateGateway(Id)
 	})
 
 	serverSocket.OnEvent("/", socket.EventMacCommand, func(s socketio.Conn, data socket.MacCommand) {
 
-		switch data.CID {
-		case "DeviceTimeReq":
-			simulatorController.SendMACCommand(lorawan.DeviceTimeReq, data)
-		case "LinkCheckReq":
-			simulatorController.SendMACCommand(lorawan.LinkCheckReq, data)
-		case "PingSlotInfoReq":
-			simulatorController.SendMACCommand(lorawan.PingSlotInfoReq, data)
+		if !authorizeSocket(s, models.RoleOperator) {
+			return
+		}
 
+		if cid, _, err := mac.ParseCID(data.CID); err == nil {
+			simulatorController.SendMACCommand(cid, data)
 		}
 
 	})
 
 	serverSocket.OnEvent("/", socket.EventChangePayload, func(s socketio.Conn, data socket.NewPayload) (string, bool) {
-		return simulatorController.ChangePayload(data)
+
+		if !authorizeSocket(s, models.RoleOperator) {
+			return "", false
+		}
+
+		devEUI, _, err := simulatorController.ChangePayload(data)
+		return devEUI, err == nil
 	})
 
 	serverSocket.OnEvent("/", socket.EventSendUplink, func(s socketio.Conn, data socket.NewPayload) {
+
+		if !authorizeSocket(s, models.RoleOperator) {
+			return
+		}
+
 		simulatorController.SendUplink(data)
 	})
 
@@ -264,7 +457,23 @@ func newServerSocket() *socketio.Server {
 	})
 
 	serverSocket.OnEvent("/", socket.EventChangeLocation, func(s socketio.Conn, info socket.NewLocation) bool {
-		return simulatorController.ChangeLocation(info)
+
+		if !authorizeSocket(s, models.RoleOperator) {
+			return false
+		}
+
+		_, err := simulatorController.ChangeLocation(info)
+		return err == nil
+	})
+
+	serverSocket.OnEvent("/", socket.EventChangeLocationGw, func(s socketio.Conn, info socket.NewLocation) bool {
+
+		if !authorizeSocket(s, models.RoleOperator) {
+			return false
+		}
+
+		_, err := simulatorController.ChangeLocationGateway(info)
+		return err == nil
 	})
 
 	return serverSocket
@@ -272,9 +481,77 @@ func newServerSocket() *socketio.Server {
 
 func (ws *WebServer) Run() {
 
-	log.Println("[WS]: Listen [", ws.Address+":"+strconv.Itoa(ws.Port), "]")
+	address := ws.Address + ":" + strconv.Itoa(ws.Port)
+
+	if configuration.GRPC.Enable {
+		go ws.runGRPC()
+	}
+
+	if !configuration.TLS.Enable {
+
+		log.Println("[WS]: Listen [", address, "]")
+
+		err := ws.Router.Run(address)
+		if err != nil {
+			log.Println("[WS] [ERROR]:", err.Error())
+		}
+
+		return
+	}
+
+	tlsConfig, err := configuration.TLS.Config()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if configuration.TLS.RedirectPort != 0 {
+		go ws.redirect()
+	}
+
+	server := http.Server{
+		Addr:      address,
+		Handler:   ws.Router,
+		TLSConfig: tlsConfig,
+	}
+
+	log.Println("[WS]: Listen HTTPS [", address, "]")
+
+	err = server.ListenAndServeTLS(configuration.TLS.CertFile, configuration.TLS.KeyFile)
+	if err != nil {
+		log.Println("[WS] [ERROR]:", err.Error())
+	}
+
+}
+
+// redirect answers the HTTP requests with the same URL over HTTPS
+func (ws *WebServer) redirect() {
+
+	address := ws.Address + ":" + strconv.Itoa(configuration.TLS.RedirectPort)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if ws.Port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(ws.Port))
+		}
+
+		target := url.URL{
+			Scheme:   "https",
+			Host:     host,
+			Path:     r.URL.Path,
+			RawQuery: r.URL.RawQuery,
+		}
+
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+
+	log.Println("[WS]: Redirect HTTP [", address, "] to HTTPS")
 
-	err := ws.Router.Run(ws.Address + ":" + strconv.Itoa(ws.Port))
+	err := http.ListenAndServe(address, handler)
 	if err != nil {
 		log.Println("[WS] [ERROR]:", err.Error())
 	}